@@ -8,6 +8,9 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	// the runtime image has no zoneinfo, timezones of reports and stats rely on the embedded database
+	_ "time/tzdata"
 )
 
 func main() {
@@ -37,7 +40,7 @@ func main() {
 		panic(err)
 	}
 
-	var router http.Handler = router.NewRouter(conn, &cfg.Auth)
+	var router http.Handler = router.NewRouter(conn, cfg)
 
 	if cfg.Server.Cors {
 		router = middleware.CorsMiddleware(router)
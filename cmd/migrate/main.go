@@ -278,7 +278,7 @@ func parseVersion(filename string) (string, error) {
 
 func createUser(conn *pgxpool.Pool, username, password string, config *config.Config) error {
 	userRepo := core.NewUserRepository(conn)
-	user, err := userRepo.GetByUsername(username)
+	user, err := userRepo.GetByUsername(context.Background(), username)
 	if user != nil {
 		fmt.Println("User already exists")
 		return nil
@@ -290,7 +290,7 @@ func createUser(conn *pgxpool.Pool, username, password string, config *config.Co
 		return err
 	}
 
-	user, err = userRepo.Create(user.Username, user.Password)
+	user, err = userRepo.Create(context.Background(), user.Username, user.Password)
 	if err != nil {
 		fmt.Println("Error creating user:", err)
 		return err
@@ -303,13 +303,13 @@ func createUser(conn *pgxpool.Pool, username, password string, config *config.Co
 func createPrivateTag(conn *pgxpool.Pool) error {
 	tagRepo := core.NewTagRepository(conn)
 
-	tag, err := tagRepo.GetTag("private")
+	tag, err := tagRepo.GetTag(context.Background(), "private", core.FullVisibility)
 	if tag != nil {
 		fmt.Println("Private tag already exists")
 		return nil
 	}
 
-	tag, err = tagRepo.CreateTag(&core.Tag{
+	tag, err = tagRepo.CreateTag(context.Background(), &core.Tag{
 		Tag:     "private",
 		Private: true,
 	})
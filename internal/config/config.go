@@ -3,21 +3,38 @@ package config
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Auth     AuthConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Auth        AuthConfig
+	Trash       TrashConfig
+	Attachments AttachmentsConfig
 }
 
 type ServerConfig struct {
 	Port string
 	Cors bool
 	SSL  bool
+	// default timeout applied to every request context, 0 disables it
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
+	// per-route overrides keyed by the lowercase route pattern, e.g. "get /api/raw/{$}"
+	RouteTimeouts map[string]time.Duration `mapstructure:"route_timeouts"`
+}
+
+// GetRouteTimeout returns the timeout configured for the route pattern, falling back to QueryTimeout
+func (c *ServerConfig) GetRouteTimeout(pattern string) time.Duration {
+	timeout, ok := c.RouteTimeouts[strings.ToLower(pattern)]
+	if ok {
+		return timeout
+	}
+
+	return c.QueryTimeout
 }
 
 type DatabaseConfig struct {
@@ -40,6 +57,32 @@ type AuthConfig struct {
 	RefreshExpiration time.Duration `mapstructure:"refresh_expiration"`
 }
 
+type TrashConfig struct {
+	// trashed events older than this number of days are purged, 0 disables the auto-purge
+	PurgeAfter int `mapstructure:"purge_after"`
+}
+
+type AttachmentsConfig struct {
+	// "fs" or "s3"
+	Storage string
+	// root directory of the fs storage
+	Path string
+	S3   S3Config
+	// maximum size of a file in bytes
+	MaxSize int64 `mapstructure:"max_size"`
+	// prefixes of the accepted content types, e.g. "image/", empty accepts every type
+	AllowedTypes []string `mapstructure:"allowed_types"`
+}
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	PathStyle bool   `mapstructure:"path_style"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAttachmentTooLarge = errors.New("attachment exceeds the maximum size")
+	ErrAttachmentType     = errors.New("attachment content type is not allowed")
+)
+
+// attachmentSniffLength is the number of bytes inspected by http.DetectContentType
+const attachmentSniffLength = 512
+
+// inlineTypePrefixes are the media types a browser may display inline, types that can run script like
+// image/svg+xml are always downloaded
+var inlineTypePrefixes = []string{"image/", "audio/", "video/", "application/pdf"}
+
+// isInlineType tells whether the attachment may be displayed inline on the API origin
+func isInlineType(contentType string) bool {
+	if contentType == "image/svg+xml" {
+		return false
+	}
+
+	for _, prefix := range inlineTypePrefixes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Attachment struct {
+	ID      int64
+	EventID int64
+	// key of the blob in the storage
+	Key         string
+	Filename    string
+	ContentType string
+	Size        int64
+	SHA256      string
+	Created     time.Time
+}
+
+func (a *Attachment) ToAttachmentResponse() *AttachmentResponse {
+	return &AttachmentResponse{
+		ID:          a.ID,
+		EventID:     a.EventID,
+		Filename:    a.Filename,
+		ContentType: a.ContentType,
+		Size:        a.Size,
+		SHA256:      a.SHA256,
+		Created:     a.Created,
+	}
+}
+
+type AttachmentResponse struct {
+	ID          int64     `json:"id"`
+	EventID     int64     `json:"eventId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Created     time.Time `json:"created"`
+}
+
+// AttachmentUpload is a file spooled to disk by AttachmentService.Spool, not stored yet
+type AttachmentUpload struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	SHA256      string
+
+	file *os.File
+}
+
+// Open returns the content from its beginning, the upload may be read several times
+func (u *AttachmentUpload) Open() (io.ReadSeeker, error) {
+	_, err := u.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.file, nil
+}
+
+// Close removes the temporary file
+func (u *AttachmentUpload) Close() error {
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+type AttachmentHandler struct {
+	handler.BaseHandler
+
+	service *AttachmentService
+}
+
+func NewAttachmentHandler(service *AttachmentService) *AttachmentHandler {
+	return &AttachmentHandler{service: service}
+}
+
+func (h *AttachmentHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/events/{id}/attachments", h.ListAttachments, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/events/{id}/attachments/{attachmentId}", h.DownloadAttachment, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/events/{id}/attachments", h.UploadAttachment, handler.RouteProviderRole),
+		handler.NewRoute("DELETE /api/core/events/{id}/attachments/{attachmentId}", h.DeleteAttachment, handler.RouteOwnerRole),
+	}
+}
+
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListAttachments(r.Context(), eventId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "event not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+// UploadAttachment reads the "file" part of a multipart/form-data body, the part is streamed and not buffered in memory
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			h.SendJSON(w, http.StatusBadRequest, "missing file part")
+			return
+		}
+
+		if err != nil {
+			h.SendJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		result, err := h.service.Upload(r.Context(), eventId, VisibilityFromRequest(r), part.FileName(), part.Header.Get("Content-Type"), part)
+		part.Close()
+		if errors.Is(err, ErrAttachmentTooLarge) {
+			h.SendJSON(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+
+		if errors.Is(err, ErrAttachmentType) {
+			h.SendJSON(w, http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+
+		if err != nil {
+			h.SendJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if result == nil {
+			h.SendJSON(w, http.StatusNotFound, "event not found")
+			return
+		}
+
+		h.SendJSON(w, http.StatusCreated, result)
+		return
+	}
+}
+
+func (h *AttachmentHandler) DownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attachmentId, err := h.GetInt64FromPath(r, "attachmentId")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attachment, content, err := h.service.Open(r.Context(), eventId, attachmentId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if attachment == nil {
+		h.SendJSON(w, http.StatusNotFound, "attachment not found")
+		return
+	}
+	defer content.Close()
+
+	// other types are downloaded even with inline, uploaded HTML would run in the session of the API origin
+	disposition := "attachment"
+	if r.URL.Query().Has("inline") && isInlineType(attachment.ContentType) {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.WriteHeader(http.StatusOK)
+
+	io.Copy(w, content)
+}
+
+func (h *AttachmentHandler) DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attachmentId, err := h.GetInt64FromPath(r, "attachmentId")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.service.DeleteAttachment(r.Context(), eventId, attachmentId)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttachmentRepository struct {
+	db db.DBTX
+}
+
+func NewAttachmentRepository(db *pgxpool.Pool) *AttachmentRepository {
+	return &AttachmentRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *AttachmentRepository) WithTx(tx pgx.Tx) *AttachmentRepository {
+	return &AttachmentRepository{db: tx}
+}
+
+const attachmentColumns = "attachments.id, attachments.event_id, attachments.key, attachments.filename, attachments.content_type, attachments.size, attachments.sha256, attachments.created"
+
+func scanAttachment(row pgx.Row) (*Attachment, error) {
+	attachment := Attachment{}
+	err := row.Scan(
+		&attachment.ID, &attachment.EventID, &attachment.Key, &attachment.Filename,
+		&attachment.ContentType, &attachment.Size, &attachment.SHA256, &attachment.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &attachment, nil
+}
+
+// attachmentWhere returns the condition of the attachments of the event, the event has to be visible
+func attachmentWhere(visibility Visibility) string {
+	where := "WHERE attachments.event_id = $1"
+	if condition := visibility.EventCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	return where
+}
+
+func (r *AttachmentRepository) ListAttachments(ctx context.Context, eventID int64, visibility Visibility) ([]Attachment, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		INNER JOIN events ON attachments.event_id = events.id
+		`+attachmentWhere(visibility)+`
+		ORDER BY attachments.id ASC
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]Attachment, 0)
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		attachments = append(attachments, *attachment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func (r *AttachmentRepository) GetAttachment(ctx context.Context, eventID, id int64, visibility Visibility) (*Attachment, error) {
+	attachment, err := scanAttachment(r.db.QueryRow(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		INNER JOIN events ON attachments.event_id = events.id
+		`+attachmentWhere(visibility)+` AND attachments.id = $2
+	`, eventID, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// GetAttachmentByHash returns the oldest attachment with the content hash, also of events in the trash
+func (r *AttachmentRepository) GetAttachmentByHash(ctx context.Context, sha256 string) (*Attachment, error) {
+	attachment, err := scanAttachment(r.db.QueryRow(ctx, `
+		SELECT `+attachmentColumns+`
+		FROM attachments
+		WHERE attachments.sha256 = $1
+		ORDER BY attachments.id ASC
+		LIMIT 1
+	`, sha256))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// LockHash waits for other transactions holding the lock of the content hash, the lock is held until the transaction ends
+func (r *AttachmentRepository) LockHash(ctx context.Context, sha256 string) error {
+	_, err := r.db.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtext('attachments_sha256:' || $1))
+	`, sha256)
+
+	return err
+}
+
+func (r *AttachmentRepository) CreateAttachment(ctx context.Context, attachment *Attachment) (*Attachment, error) {
+	return scanAttachment(r.db.QueryRow(ctx, `
+		INSERT INTO attachments (event_id, key, filename, content_type, size, sha256)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+attachmentColumns,
+		attachment.EventID, attachment.Key, attachment.Filename, attachment.ContentType, attachment.Size, attachment.SHA256,
+	))
+}
+
+// DeleteAttachment removes the metadata, the blob is queued for deletion by a trigger
+func (r *AttachmentRepository) DeleteAttachment(ctx context.Context, eventID, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM attachments
+		WHERE event_id = $1 AND id = $2
+	`, eventID, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("AttachmentRepository.DeleteAttachment: no rows affected")
+	}
+
+	return nil
+}
+
+// QueueDeletion queues the blob for deletion once the time has passed, see AttachmentService.Store
+func (r *AttachmentRepository) QueueDeletion(ctx context.Context, key string, notBefore time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO attachment_deletions (key, not_before)
+		VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE
+		SET not_before = EXCLUDED.not_before
+	`, key, notBefore)
+
+	return err
+}
+
+// ClaimDeletions returns due blob keys and moves their time by the lease, so other workers skip them while
+// they are deleted. Rows locked by another transaction are skipped.
+func (r *AttachmentRepository) ClaimDeletions(ctx context.Context, limit int, lease time.Duration) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE attachment_deletions
+		SET not_before = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+		WHERE key IN (
+			SELECT key
+			FROM attachment_deletions
+			WHERE not_before <= CURRENT_TIMESTAMP
+			ORDER BY not_before ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING key
+	`, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+func (r *AttachmentRepository) RemoveDeletions(ctx context.Context, keys []string) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM attachment_deletions
+		WHERE key = ANY($1)
+	`, keys)
+
+	return err
+}
@@ -0,0 +1,345 @@
+package core
+
+import (
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/pkg/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	attachmentCleanupBatchSize = 100
+	// time a blob is reserved for its deletion by one worker
+	attachmentCleanupLease = 5 * time.Minute
+	// blobs whose transaction did not commit by then are considered rolled back and deleted
+	attachmentUploadGrace = time.Hour
+)
+
+type AttachmentService struct {
+	txManager      *db.TxManager
+	attachmentRepo *AttachmentRepository
+	eventRepo      *EventRepository
+	storage        storage.Storage
+	config         *config.AttachmentsConfig
+}
+
+func NewAttachmentService(txManager *db.TxManager, attachmentRepo *AttachmentRepository, eventRepo *EventRepository, storage storage.Storage, config *config.AttachmentsConfig) *AttachmentService {
+	return &AttachmentService{
+		txManager:      txManager,
+		attachmentRepo: attachmentRepo,
+		eventRepo:      eventRepo,
+		storage:        storage,
+		config:         config,
+	}
+}
+
+// ListAttachments returns nil when the caller may not see the event
+func (s *AttachmentService) ListAttachments(ctx context.Context, eventID int64, visibility Visibility) ([]AttachmentResponse, error) {
+	event, err := s.eventRepo.GetEvent(ctx, eventID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.ListAttachments: %v", err)
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	data, err := s.attachmentRepo.ListAttachments(ctx, eventID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.ListAttachments: %v", err)
+	}
+
+	result := make([]AttachmentResponse, len(data))
+	for i, attachment := range data {
+		result[i] = *attachment.ToAttachmentResponse()
+	}
+
+	return result, nil
+}
+
+// Upload stores the file of the event, returns nil when the caller may not see the event.
+// The content type is sniffed from the content, the declared one is used only when sniffing finds nothing specific.
+func (s *AttachmentService) Upload(ctx context.Context, eventID int64, visibility Visibility, filename, declaredType string, body io.Reader) (*AttachmentResponse, error) {
+	event, err := s.eventRepo.GetEvent(ctx, eventID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Upload: %v", err)
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	upload, err := s.Spool(body, filename, declaredType)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	var attachment *Attachment
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		attachment, err = s.Store(ctx, tx, eventID, upload)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Upload: %v", err)
+	}
+
+	return attachment.ToAttachmentResponse(), nil
+}
+
+// Spool checks the type of the content and copies it to a temporary file, the storage needs the size up front.
+// The caller closes the upload, which removes the temporary file.
+func (s *AttachmentService) Spool(body io.Reader, filename, declaredType string) (*AttachmentUpload, error) {
+	head := make([]byte, attachmentSniffLength)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("AttachmentService.Spool: %v", err)
+	}
+	head = head[:n]
+
+	contentType := detectContentType(head, declaredType)
+	if !s.isAllowedType(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, contentType)
+	}
+
+	file, err := os.CreateTemp("", "attachment-*")
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Spool: %v", err)
+	}
+
+	upload := &AttachmentUpload{
+		Filename:    sanitizeFilename(filename),
+		ContentType: contentType,
+		file:        file,
+	}
+
+	content := io.MultiReader(bytes.NewReader(head), body)
+	if s.config.MaxSize > 0 {
+		// one byte more tells that the limit was exceeded
+		content = io.LimitReader(content, s.config.MaxSize+1)
+	}
+
+	hash := sha256.New()
+	upload.Size, err = io.Copy(io.MultiWriter(file, hash), content)
+	if err != nil {
+		upload.Close()
+		return nil, fmt.Errorf("AttachmentService.Spool: %v", err)
+	}
+
+	if s.config.MaxSize > 0 && upload.Size > s.config.MaxSize {
+		upload.Close()
+		return nil, ErrAttachmentTooLarge
+	}
+	upload.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return upload, nil
+}
+
+// Store puts the spooled file into the storage and adds it to the event within the transaction.
+// The blob is uploaded before the transaction commits, so it is queued for deletion outside the transaction first
+// and the transaction removes the entry. When the transaction rolls back, the cleanup deletes the blob after
+// attachmentUploadGrace.
+func (s *AttachmentService) Store(ctx context.Context, tx pgx.Tx, eventID int64, upload *AttachmentUpload) (*Attachment, error) {
+	content, err := upload.Open()
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Store: %v", err)
+	}
+
+	key := fmt.Sprintf("events/%d/%s", eventID, uuid.NewString())
+	err = s.attachmentRepo.QueueDeletion(ctx, key, time.Now().Add(attachmentUploadGrace))
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Store: %v", err)
+	}
+
+	err = s.storage.Put(ctx, key, content, upload.Size, upload.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Store: failed to store blob, %v", err)
+	}
+
+	repo := s.attachmentRepo.WithTx(tx)
+	attachment, err := repo.CreateAttachment(ctx, &Attachment{
+		EventID:     eventID,
+		Key:         key,
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		SHA256:      upload.SHA256,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Store: %v", err)
+	}
+
+	err = repo.RemoveDeletions(ctx, []string{key})
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.Store: %v", err)
+	}
+
+	return attachment, nil
+}
+
+// FindByHash returns an attachment with the content hash, nil when there is none
+func (s *AttachmentService) FindByHash(ctx context.Context, sha256 string) (*Attachment, error) {
+	attachment, err := s.attachmentRepo.GetAttachmentByHash(ctx, sha256)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.FindByHash: %v", err)
+	}
+
+	return attachment, nil
+}
+
+// LockHash serialises the transactions storing the content hash and returns an attachment with it committed
+// before the lock was taken, nil when there is none
+func (s *AttachmentService) LockHash(ctx context.Context, tx pgx.Tx, sha256 string) (*Attachment, error) {
+	repo := s.attachmentRepo.WithTx(tx)
+
+	err := repo.LockHash(ctx, sha256)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.LockHash: %v", err)
+	}
+
+	attachment, err := repo.GetAttachmentByHash(ctx, sha256)
+	if err != nil {
+		return nil, fmt.Errorf("AttachmentService.LockHash: %v", err)
+	}
+
+	return attachment, nil
+}
+
+// Open returns the attachment with its content, nil when the caller may not see it. The caller closes the content.
+func (s *AttachmentService) Open(ctx context.Context, eventID, id int64, visibility Visibility) (*AttachmentResponse, io.ReadCloser, error) {
+	attachment, err := s.attachmentRepo.GetAttachment(ctx, eventID, id, visibility)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AttachmentService.Open: %v", err)
+	}
+
+	if attachment == nil {
+		return nil, nil, nil
+	}
+
+	content, err := s.storage.Get(ctx, attachment.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AttachmentService.Open: %v", err)
+	}
+
+	return attachment.ToAttachmentResponse(), content, nil
+}
+
+func (s *AttachmentService) DeleteAttachment(ctx context.Context, eventID, id int64) error {
+	return s.attachmentRepo.DeleteAttachment(ctx, eventID, id)
+}
+
+// RunCleanup removes the blobs of deleted attachments from the storage until the context is cancelled
+func (s *AttachmentService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.cleanup(ctx)
+		if err != nil {
+			fmt.Println("AttachmentService.RunCleanup:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cleanup claims the due deletions and removes the blobs without holding any lock,
+// deletions which fail are retried once their lease expired
+func (s *AttachmentService) cleanup(ctx context.Context) error {
+	for {
+		keys, err := s.attachmentRepo.ClaimDeletions(ctx, attachmentCleanupBatchSize, attachmentCleanupLease)
+		if err != nil {
+			return err
+		}
+
+		deleted := make([]string, 0, len(keys))
+		for _, key := range keys {
+			err = s.storage.Delete(ctx, key)
+			if err != nil {
+				fmt.Println("AttachmentService.cleanup: failed to delete", key+",", err)
+				continue
+			}
+
+			deleted = append(deleted, key)
+		}
+
+		if len(deleted) > 0 {
+			err = s.attachmentRepo.RemoveDeletions(ctx, deleted)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(keys) < attachmentCleanupBatchSize || len(deleted) == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *AttachmentService) isAllowedType(contentType string) bool {
+	if len(s.config.AllowedTypes) == 0 {
+		return true
+	}
+
+	for _, allowed := range s.config.AllowedTypes {
+		if strings.HasPrefix(contentType, allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// heifBrands maps the major brands of the ISOBMFF ftyp box to the HEIF media types, not sniffed by the http package
+var heifBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"mif1": "image/heif",
+	"msf1": "image/heif",
+}
+
+// detectContentType sniffs the media type, parameters like the charset are dropped
+func detectContentType(head []byte, declared string) string {
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		if detected, ok := heifBrands[string(head[8:12])]; ok {
+			return detected
+		}
+	}
+
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+
+	if detected == "application/octet-stream" {
+		if declared, _, err := mime.ParseMediaType(declared); err == nil {
+			return declared
+		}
+	}
+
+	return detected
+}
+
+func sanitizeFilename(filename string) string {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "." || filename == "/" || len(filename) == 0 {
+		return "attachment"
+	}
+
+	return filename
+}
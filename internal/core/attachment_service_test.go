@@ -0,0 +1,82 @@
+package core
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		declared string
+		expected string
+	}{
+		{"heic brand", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), "", "image/heic"},
+		{"heix brand", []byte("\x00\x00\x00\x18ftypheix\x00\x00\x00\x00"), "application/octet-stream", "image/heic"},
+		{"mif1 brand", []byte("\x00\x00\x00\x18ftypmif1\x00\x00\x00\x00"), "image/heic", "image/heif"},
+		{"msf1 brand", []byte("\x00\x00\x00\x18ftypmsf1\x00\x00\x00\x00"), "", "image/heif"},
+		{"other brand falls back to declared", []byte("\x00\x00\x00\x18ftypavif\x00\x00\x00\x00"), "image/avif", "image/avif"},
+		{"short ftyp", []byte("\x00\x00\x00\x18ftyp"), "image/heic", "image/heic"},
+		{"jpeg over declared", []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00"), "text/html", "image/jpeg"},
+		{"html over declared", []byte("<!DOCTYPE html><html>"), "image/png", "text/html"},
+		{"charset dropped", []byte("plain text"), "", "text/plain"},
+		{"octet stream falls back to declared", []byte("\x00\x01\x02\x03"), "application/x-custom; charset=binary", "application/x-custom"},
+		{"octet stream without declared", []byte("\x00\x01\x02\x03"), "", "application/octet-stream"},
+		{"octet stream with invalid declared", []byte("\x00\x01\x02\x03"), "not a type;", "application/octet-stream"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if detected := detectContentType(test.head, test.declared); detected != test.expected {
+				t.Fatalf("content type %s, expected %s", detected, test.expected)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{"photo.jpg", "photo.jpg"},
+		{"dir/photo.jpg", "photo.jpg"},
+		{"../../etc/passwd", "passwd"},
+		{"/etc/passwd", "passwd"},
+		{`C:\Users\me\photo.jpg`, "photo.jpg"},
+		{`..\..\photo.jpg`, "photo.jpg"},
+		{"dir/", "dir"},
+		{"", "attachment"},
+		{".", "attachment"},
+		{"/", "attachment"},
+		{`\`, "attachment"},
+		{"..", ".."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.filename, func(t *testing.T) {
+			if filename := sanitizeFilename(test.filename); filename != test.expected {
+				t.Fatalf("filename %q, expected %q", filename, test.expected)
+			}
+		})
+	}
+}
+
+func TestIsInlineType(t *testing.T) {
+	tests := map[string]bool{
+		"image/jpeg":               true,
+		"image/heic":               true,
+		"audio/mpeg":               true,
+		"video/mp4":                true,
+		"application/pdf":          true,
+		"image/svg+xml":            false,
+		"text/html":                false,
+		"application/xhtml+xml":    false,
+		"application/octet-stream": false,
+		"text/plain":               false,
+	}
+
+	for contentType, expected := range tests {
+		if inline := isInlineType(contentType); inline != expected {
+			t.Errorf("inline %s %v, expected %v", contentType, inline, expected)
+		}
+	}
+}
@@ -59,7 +59,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, accessToken, err := h.service.Login(loginRequest.Username, loginRequest.Password)
+	refreshToken, accessToken, err := h.service.Login(r.Context(), loginRequest.Username, loginRequest.Password)
 	if err != nil {
 		h.removeCookies(w)
 		http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -87,7 +87,7 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	refreshToken, accessToken, err := h.service.ValidateRefreshToken(cookie.Value)
+	refreshToken, accessToken, err := h.service.ValidateRefreshToken(r.Context(), cookie.Value)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
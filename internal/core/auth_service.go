@@ -3,6 +3,7 @@ package core
 import (
 	"backend/internal/config"
 	pkgjwt "backend/pkg/jwt"
+	"context"
 	"errors"
 	"fmt"
 	"time"
@@ -28,8 +29,8 @@ func NewAuthService(userRepo *UserRepository, providerRepo *ProviderRepository,
 }
 
 // Login = find user, verify password, create JWT refresh token
-func (s *AuthService) Login(username, password string) (string, string, error) {
-	user, err := s.userRepo.GetByUsername(username)
+func (s *AuthService) Login(ctx context.Context, username, password string) (string, string, error) {
+	user, err := s.userRepo.GetByUsername(ctx, username)
 	if err != nil {
 		return "", "", err
 	}
@@ -40,7 +41,7 @@ func (s *AuthService) Login(username, password string) (string, string, error) {
 
 	// create new tokens
 	accessClaims := s.createClaims(user.ID, pkgjwt.UserClaim, s.config.AccessExpiration)
-	return s.createJWTTokens(accessClaims)
+	return s.createJWTTokens(ctx, accessClaims)
 }
 
 func (s *AuthService) ValidateToken(receivedToken string) (pkgjwt.Claims, error) {
@@ -71,7 +72,7 @@ func (s *AuthService) ValidateToken(receivedToken string) (pkgjwt.Claims, error)
 	return claims, nil
 }
 
-func (s *AuthService) ValidateRefreshToken(token string) (string, string, error) {
+func (s *AuthService) ValidateRefreshToken(ctx context.Context, token string) (string, string, error) {
 	// validate JWT token
 	claims, err := s.ValidateToken(token)
 	if err != nil {
@@ -79,7 +80,7 @@ func (s *AuthService) ValidateRefreshToken(token string) (string, string, error)
 	}
 
 	// find stored token
-	t, err := s.tokenRepo.Get(claims.JTI)
+	t, err := s.tokenRepo.Get(ctx, claims.JTI)
 	if err != nil {
 		return "", "", err
 	}
@@ -88,7 +89,7 @@ func (s *AuthService) ValidateRefreshToken(token string) (string, string, error)
 		return "", "", errors.New("token blocked")
 	}
 
-	user, err := s.userRepo.GetUser(claims.UserID)
+	user, err := s.userRepo.GetUser(ctx, claims.UserID)
 	if err != nil {
 		return "", "", errors.New("ValidateRefreshToken: failed to retrieve user")
 	}
@@ -96,13 +97,13 @@ func (s *AuthService) ValidateRefreshToken(token string) (string, string, error)
 	accessClaims := s.createClaims(user.ID, pkgjwt.UserClaim, s.config.AccessExpiration)
 
 	// rotate refresh token (remove old)
-	refresh, access, err := s.createJWTTokens(accessClaims)
+	refresh, access, err := s.createJWTTokens(ctx, accessClaims)
 	if err != nil {
 		return "", "", err
 	}
 
 	// remove old token
-	err = s.tokenRepo.Delete(claims.JTI)
+	err = s.tokenRepo.Delete(ctx, claims.JTI)
 	if err != nil {
 		return "", "", err
 	}
@@ -128,14 +129,14 @@ func (s *AuthService) CreateJWTToken(claims pkgjwt.Claims) (string, error) {
 	return tokenString, nil
 }
 
-func (s *AuthService) createJWTTokens(claims pkgjwt.Claims) (refreshToken string, accessToken string, err error) {
+func (s *AuthService) createJWTTokens(ctx context.Context, claims pkgjwt.Claims) (refreshToken string, accessToken string, err error) {
 	refreshClaims := s.createClaims(claims.UserID, claims.Type, s.config.RefreshExpiration)
 	refreshToken, err = s.CreateJWTToken(refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
 
-	err = s.tokenRepo.Create(Token{
+	err = s.tokenRepo.Create(ctx, Token{
 		UserID:     refreshClaims.UserID,
 		Expiration: refreshClaims.Expiration,
 		JTI:        refreshClaims.JTI,
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const MaxBatchSize = 1000
+
+type BatchItemStatus string
+
+const (
+	BatchItemCreated BatchItemStatus = "created"
+	BatchItemFailed  BatchItemStatus = "failed"
+	// atomic batches only, the item was valid but the batch was rolled back
+	BatchItemRolledBack BatchItemStatus = "rolledBack"
+	// atomic batches only, the item was not processed because an earlier one failed.
+	// Photo imports also skip photos which were imported before.
+	BatchItemSkipped BatchItemStatus = "skipped"
+)
+
+type BatchItemResult[T any] struct {
+	Index  int             `json:"index"`
+	Status BatchItemStatus `json:"status"`
+	Data   *T              `json:"data,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+type BatchResponse[T any] struct {
+	Atomic  bool                 `json:"atomic"`
+	Created int                  `json:"created"`
+	Failed  int                  `json:"failed"`
+	Items   []BatchItemResult[T] `json:"items"`
+}
+
+// StatusCode returns 201 when every item was created, 207 for partial success and 422 when nothing was created
+func (b *BatchResponse[T]) StatusCode() int {
+	if b.Failed == 0 {
+		return http.StatusCreated
+	}
+
+	if b.Created > 0 {
+		return http.StatusMultiStatus
+	}
+
+	return http.StatusUnprocessableEntity
+}
+
+// BatchFromRequest parses the array of items from the request body and the atomic flag from the query
+func BatchFromRequest[R any](r *http.Request) ([]R, bool, error) {
+	atomic := r.URL.Query().Get("atomic") == "true"
+
+	items := make([]R, 0)
+	err := json.NewDecoder(r.Body).Decode(&items)
+	if err != nil {
+		return nil, atomic, fmt.Errorf("BatchFromRequest: invalid body, %v", err)
+	}
+
+	if len(items) == 0 {
+		return nil, atomic, errors.New("BatchFromRequest: empty batch")
+	}
+
+	if len(items) > MaxBatchSize {
+		return nil, atomic, fmt.Errorf("BatchFromRequest: batch exceeds %d items", MaxBatchSize)
+	}
+
+	return items, atomic, nil
+}
+
+var errBatchAborted = errors.New("batch aborted")
+
+// BatchItemError is returned by the create function of RunBatch for the item whose write failed,
+// Index is the position of the item in the slice given to the function
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// RunBatch creates every item in a single transaction. prepare validates and completes each item before anything
+// is written, create then writes the prepared items at once and returns their results in order.
+// In atomic mode the first failure rolls back the whole batch and the remaining items are skipped.
+// Otherwise the items are written in a savepoint. When it fails, the item reported by create with BatchItemError
+// fails and the others are split in halves, each written in its own savepoint. Failures create does not tie to an
+// item are split the same way down to the single item, so every item is written at most about log2(n) + 1 times.
+func RunBatch[R, T any](ctx context.Context, txManager *db.TxManager, items []R, atomic bool, prepare func(item *R) error, create func(tx pgx.Tx, items []*R) ([]*T, error)) (*BatchResponse[T], error) {
+	response, pending := prepareBatch[R, T](items, atomic, prepare)
+	if len(pending) == 0 {
+		return response, nil
+	}
+
+	err := txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		return writeBatch(ctx, tx, items, pending, response, create)
+	})
+
+	if errors.Is(err, errBatchAborted) {
+		return response, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("RunBatch: %v", err)
+	}
+
+	return response, nil
+}
+
+// prepareBatch runs prepare on every item and returns the positions of the items to write,
+// none when an atomic batch has an invalid item
+func prepareBatch[R, T any](items []R, atomic bool, prepare func(item *R) error) (*BatchResponse[T], []int) {
+	response := &BatchResponse[T]{
+		Atomic: atomic,
+		Items:  make([]BatchItemResult[T], len(items)),
+	}
+
+	pending := make([]int, 0, len(items))
+	for i := range items {
+		response.Items[i] = BatchItemResult[T]{Index: i}
+
+		err := prepare(&items[i])
+		if err != nil {
+			response.fail(i, err)
+
+			if atomic {
+				response.abort(pending, i)
+				return response, nil
+			}
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	return response, pending
+}
+
+// writeBatch writes the items at the pending positions, an atomic batch returns errBatchAborted when an item failed
+func writeBatch[R, T any](ctx context.Context, tx pgx.Tx, items []R, pending []int, response *BatchResponse[T], create func(tx pgx.Tx, items []*R) ([]*T, error)) error {
+	if !response.Atomic {
+		return writeBatchPart(ctx, tx, items, pending, response, create)
+	}
+
+	results, err := create(tx, batchItems(items, pending))
+
+	var itemErr *BatchItemError
+	if errors.As(err, &itemErr) && itemErr.Index >= 0 && itemErr.Index < len(pending) {
+		failed := pending[itemErr.Index]
+		response.fail(failed, itemErr.Err)
+		response.abort(pending[:itemErr.Index], failed)
+		return errBatchAborted
+	}
+
+	if err != nil {
+		return err
+	}
+
+	response.created(pending, results)
+	return nil
+}
+
+// writeBatchPart writes the items at the pending positions in a savepoint and bisects them when it fails,
+// only errors of the savepoint itself are returned
+func writeBatchPart[R, T any](ctx context.Context, tx pgx.Tx, items []R, pending []int, response *BatchResponse[T], create func(tx pgx.Tx, items []*R) ([]*T, error)) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// nested transaction is a savepoint
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	results, createErr := create(savepoint, batchItems(items, pending))
+	if createErr == nil {
+		err = savepoint.Commit(ctx)
+		if err != nil {
+			return err
+		}
+
+		response.created(pending, results)
+		return nil
+	}
+
+	err = savepoint.Rollback(ctx)
+	if err != nil {
+		return err
+	}
+
+	var itemErr *BatchItemError
+	switch {
+	case errors.As(createErr, &itemErr) && itemErr.Index >= 0 && itemErr.Index < len(pending):
+		response.fail(pending[itemErr.Index], itemErr.Err)
+		pending = slices.Delete(slices.Clone(pending), itemErr.Index, itemErr.Index+1)
+
+		if len(pending) < 2 {
+			return writeBatchPart(ctx, tx, items, pending, response, create)
+		}
+	case len(pending) == 1:
+		response.fail(pending[0], createErr)
+		return nil
+	}
+
+	middle := len(pending) / 2
+	err = writeBatchPart(ctx, tx, items, pending[:middle], response, create)
+	if err != nil {
+		return err
+	}
+
+	return writeBatchPart(ctx, tx, items, pending[middle:], response, create)
+}
+
+func batchItems[R any](items []R, pending []int) []*R {
+	batch := make([]*R, len(pending))
+	for j, i := range pending {
+		batch[j] = &items[i]
+	}
+
+	return batch
+}
+
+func (b *BatchResponse[T]) created(pending []int, results []*T) {
+	for j, i := range pending {
+		b.Items[i].Status = BatchItemCreated
+		b.Items[i].Data = results[j]
+		b.Created++
+	}
+}
+
+func (b *BatchResponse[T]) fail(index int, err error) {
+	b.Items[index].Status = BatchItemFailed
+	b.Items[index].Error = err.Error()
+	b.Failed++
+}
+
+// abort marks the valid items before the failed one as rolled back and the items after it as skipped
+func (b *BatchResponse[T]) abort(valid []int, failed int) {
+	for _, i := range valid {
+		b.Items[i].Status = BatchItemRolledBack
+	}
+
+	for i := failed + 1; i < len(b.Items); i++ {
+		b.Items[i] = BatchItemResult[T]{Index: i, Status: BatchItemSkipped}
+	}
+}
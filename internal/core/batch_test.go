@@ -0,0 +1,247 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// batchTx keeps the items written in it, a savepoint hands them to its parent on commit.
+// Other methods of pgx.Tx are not used by the batch and panic.
+type batchTx struct {
+	pgx.Tx
+	parent  *batchTx
+	written []int
+	closed  bool
+}
+
+func (b *batchTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &batchTx{parent: b}, nil
+}
+
+func (b *batchTx) Commit(ctx context.Context) error {
+	if b.closed {
+		return pgx.ErrTxClosed
+	}
+	b.closed = true
+
+	if b.parent != nil {
+		b.parent.written = append(b.parent.written, b.written...)
+	}
+	return nil
+}
+
+func (b *batchTx) Rollback(ctx context.Context) error {
+	if b.closed {
+		return pgx.ErrTxClosed
+	}
+	b.closed = true
+
+	return nil
+}
+
+// batchWriter writes the items like a create function of RunBatch, failing on the given values
+type batchWriter struct {
+	// the failure is reported with BatchItemError
+	itemErrors []int
+	// the failure is not tied to an item, like a failed COPY
+	batchErrors []int
+	// items given to create over all calls
+	attempts int
+}
+
+func (w *batchWriter) create(tx pgx.Tx, items []*int) ([]*int, error) {
+	w.attempts += len(items)
+
+	for j, item := range items {
+		if slices.Contains(w.itemErrors, *item) {
+			return nil, &BatchItemError{Index: j, Err: errors.New("invalid item")}
+		}
+
+		if slices.Contains(w.batchErrors, *item) {
+			return nil, errors.New("copy failed")
+		}
+	}
+
+	results := make([]*int, len(items))
+	for j, item := range items {
+		tx.(*batchTx).written = append(tx.(*batchTx).written, *item)
+		result := *item * 10
+		results[j] = &result
+	}
+
+	return results, nil
+}
+
+// runTestBatch runs the batch like RunBatch within a fake transaction and returns the committed items
+func runTestBatch(t *testing.T, items []int, atomic bool, writer *batchWriter) (*BatchResponse[int], []int) {
+	response, pending := prepareBatch[int, int](items, atomic, func(item *int) error {
+		if *item < 0 {
+			return errors.New("negative item")
+		}
+		return nil
+	})
+
+	tx := &batchTx{}
+	if len(pending) > 0 {
+		err := writeBatch(context.Background(), tx, items, pending, response, writer.create)
+		if err != nil && !errors.Is(err, errBatchAborted) {
+			t.Fatal(err)
+		}
+
+		if err != nil {
+			tx.written = nil
+		}
+	}
+
+	slices.Sort(tx.written)
+	return response, tx.written
+}
+
+func batchStatuses(response *BatchResponse[int]) []BatchItemStatus {
+	statuses := make([]BatchItemStatus, len(response.Items))
+	for i, item := range response.Items {
+		statuses[i] = item.Status
+	}
+
+	return statuses
+}
+
+func TestRunBatch(t *testing.T) {
+	const (
+		created    = BatchItemCreated
+		failed     = BatchItemFailed
+		rolledBack = BatchItemRolledBack
+		skipped    = BatchItemSkipped
+	)
+
+	tests := []struct {
+		name     string
+		items    []int
+		atomic   bool
+		writer   batchWriter
+		statuses []BatchItemStatus
+		written  []int
+		code     int
+	}{
+		{
+			name:     "every item created",
+			items:    []int{1, 2, 3},
+			statuses: []BatchItemStatus{created, created, created},
+			written:  []int{1, 2, 3},
+			code:     http.StatusCreated,
+		},
+		{
+			name:     "invalid item",
+			items:    []int{1, -2, 3},
+			statuses: []BatchItemStatus{created, failed, created},
+			written:  []int{1, 3},
+			code:     http.StatusMultiStatus,
+		},
+		{
+			name:     "item error retried without the item",
+			items:    []int{1, 2, 3, 4, 5},
+			writer:   batchWriter{itemErrors: []int{3}},
+			statuses: []BatchItemStatus{created, created, failed, created, created},
+			written:  []int{1, 2, 4, 5},
+			code:     http.StatusMultiStatus,
+		},
+		{
+			name:     "batch error bisected",
+			items:    []int{1, 2, 3, 4, 5, 6, 7, 8},
+			writer:   batchWriter{batchErrors: []int{2, 7}},
+			statuses: []BatchItemStatus{created, failed, created, created, created, created, failed, created},
+			written:  []int{1, 3, 4, 5, 6, 8},
+			code:     http.StatusMultiStatus,
+		},
+		{
+			name:     "nothing created",
+			items:    []int{-1, 2},
+			writer:   batchWriter{itemErrors: []int{2}},
+			statuses: []BatchItemStatus{failed, failed},
+			code:     http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "atomic created",
+			items:    []int{1, 2},
+			atomic:   true,
+			statuses: []BatchItemStatus{created, created},
+			written:  []int{1, 2},
+			code:     http.StatusCreated,
+		},
+		{
+			name:     "atomic invalid item",
+			items:    []int{1, -2, 3},
+			atomic:   true,
+			statuses: []BatchItemStatus{rolledBack, failed, skipped},
+			code:     http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "atomic item error",
+			items:    []int{1, 2, 3, 4},
+			atomic:   true,
+			writer:   batchWriter{itemErrors: []int{3}},
+			statuses: []BatchItemStatus{rolledBack, rolledBack, failed, skipped},
+			code:     http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, written := runTestBatch(t, test.items, test.atomic, &test.writer)
+
+			if statuses := batchStatuses(response); !slices.Equal(statuses, test.statuses) {
+				t.Fatalf("statuses %v, expected %v", statuses, test.statuses)
+			}
+
+			if !slices.Equal(written, test.written) {
+				t.Fatalf("written %v, expected %v", written, test.written)
+			}
+
+			if code := response.StatusCode(); code != test.code {
+				t.Fatalf("status code %d, expected %d", code, test.code)
+			}
+
+			for i, item := range response.Items {
+				if item.Index != i {
+					t.Fatalf("item %d has index %d", i, item.Index)
+				}
+
+				if item.Status == created && (item.Data == nil || *item.Data != test.items[i]*10) {
+					t.Fatalf("item %d has data %v", i, item.Data)
+				}
+
+				if item.Status == failed && len(item.Error) == 0 {
+					t.Fatalf("item %d failed without error", i)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBatchBisectionCost(t *testing.T) {
+	items := make([]int, MaxBatchSize)
+	for i := range items {
+		items[i] = i + 1
+	}
+
+	// every tenth item fails without being tied to it
+	writer := &batchWriter{}
+	for i := 10; i <= MaxBatchSize; i += 10 {
+		writer.batchErrors = append(writer.batchErrors, i)
+	}
+
+	response, written := runTestBatch(t, items, false, writer)
+	if response.Failed != len(writer.batchErrors) || len(written) != MaxBatchSize-len(writer.batchErrors) {
+		t.Fatalf("%d failed and %d written", response.Failed, len(written))
+	}
+
+	// every item is written once per halving at most, instead of once per failing item before it
+	if writer.attempts > MaxBatchSize*11 {
+		t.Fatalf("%d item writes", writer.attempts)
+	}
+}
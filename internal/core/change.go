@@ -0,0 +1,30 @@
+package core
+
+const (
+	DefaultChangeLimit = 1000
+	MaxChangeLimit     = 10000
+)
+
+// Change is an entry of the change feed, the event is either alive or deleted
+type Change struct {
+	Seq     int64
+	EventID int64
+	// trashed or purged
+	Deleted bool
+	// the caller may not see the event, it is reported as deleted
+	Hidden bool
+}
+
+type ChangeResponse struct {
+	Seq     int64          `json:"seq"`
+	EventID int64          `json:"eventId"`
+	Deleted bool           `json:"deleted"`
+	Event   *EventSnapshot `json:"event,omitempty"`
+}
+
+type ChangeFeed struct {
+	Changes []ChangeResponse `json:"changes"`
+	// sequence to pass as since in the next request
+	Next    int64 `json:"next"`
+	HasMore bool  `json:"hasMore"`
+}
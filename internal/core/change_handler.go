@@ -0,0 +1,54 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+	"strconv"
+)
+
+type ChangeHandler struct {
+	handler.BaseHandler
+
+	service *ChangeService
+}
+
+func NewChangeHandler(service *ChangeService) *ChangeHandler {
+	return &ChangeHandler{service: service}
+}
+
+func (h *ChangeHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/changes", h.ListChanges, handler.RouteOwnerRole),
+	}
+}
+
+func (h *ChangeHandler) ListChanges(w http.ResponseWriter, r *http.Request) {
+	var since int64 = 0
+	if r.URL.Query().Has("since") {
+		var err error
+		since, err = strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+		if err != nil || since < 0 {
+			h.SendJSON(w, http.StatusBadRequest, "invalid since")
+			return
+		}
+	}
+
+	limit := DefaultChangeLimit
+	if r.URL.Query().Has("limit") {
+		var err error
+		limit, err = strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			h.SendJSON(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = min(limit, MaxChangeLimit)
+	}
+
+	data, err := h.service.ListChanges(r.Context(), since, limit, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
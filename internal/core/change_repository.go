@@ -0,0 +1,69 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ChangeRepository struct {
+	db db.DBTX
+}
+
+func NewChangeRepository(db *pgxpool.Pool) *ChangeRepository {
+	return &ChangeRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *ChangeRepository) WithTx(tx pgx.Tx) *ChangeRepository {
+	return &ChangeRepository{db: tx}
+}
+
+// ListChanges returns the changes after the sequence ordered by sequence, limit is not applied when 0
+func (r *ChangeRepository) ListChanges(ctx context.Context, since int64, limit int, visibility Visibility) ([]Change, error) {
+	hidden := "FALSE"
+	if condition := visibility.TagsCondition("events.tags"); len(condition) > 0 {
+		hidden = "NOT (" + condition + ")"
+	}
+
+	query := `
+		SELECT change_seq, id, deleted_at IS NOT NULL, ` + hidden + `
+		FROM events
+		WHERE change_seq > $1
+		UNION ALL
+		SELECT change_seq, event_id, TRUE, FALSE
+		FROM event_tombstones
+		WHERE change_seq > $1
+		ORDER BY 1
+	`
+	params := []any{since}
+	if limit > 0 {
+		query += " LIMIT $2"
+		params = append(params, limit)
+	}
+
+	rows, err := r.db.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]Change, 0)
+	for rows.Next() {
+		change := Change{}
+		err = rows.Scan(&change.Seq, &change.EventID, &change.Deleted, &change.Hidden)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type ChangeService struct {
+	txManager  *db.TxManager
+	changeRepo *ChangeRepository
+	journal    *EventJournal
+}
+
+func NewChangeService(txManager *db.TxManager, changeRepo *ChangeRepository, journal *EventJournal) *ChangeService {
+	return &ChangeService{
+		txManager:  txManager,
+		changeRepo: changeRepo,
+		journal:    journal,
+	}
+}
+
+// ListChanges returns the changes after the sequence, alive events come with their full state including module extras.
+// Events the caller may not see are reported as deleted.
+func (s *ChangeService) ListChanges(ctx context.Context, since int64, limit int, visibility Visibility) (*ChangeFeed, error) {
+	feed := &ChangeFeed{
+		Changes: make([]ChangeResponse, 0),
+		Next:    since,
+	}
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		// one extra row tells whether there are more changes
+		changes, err := s.changeRepo.WithTx(tx).ListChanges(ctx, since, limit+1, visibility)
+		if err != nil {
+			return fmt.Errorf("ChangeService.ListChanges: %v", err)
+		}
+
+		if len(changes) > limit {
+			changes = changes[:limit]
+			feed.HasMore = true
+		}
+
+		// alive events are loaded at once
+		ids := make([]int64, 0, len(changes))
+		for _, change := range changes {
+			if !change.Deleted && !change.Hidden {
+				ids = append(ids, change.EventID)
+			}
+		}
+
+		snapshots, err := s.journal.Snapshots(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range changes {
+			response := ChangeResponse{
+				Seq:     change.Seq,
+				EventID: change.EventID,
+				Deleted: change.Deleted || change.Hidden,
+			}
+
+			if !response.Deleted {
+				response.Event = snapshots[change.EventID]
+			}
+
+			feed.Changes = append(feed.Changes, response)
+			feed.Next = change.Seq
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
@@ -1,10 +1,15 @@
 package core
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,6 +31,62 @@ type Event struct {
 	Note       string
 	Reference  string
 	ProviderID *int64
+	ClientID   *string
+	Timezone   *string
+	// materialized occurrence of a series
+	SeriesID   *int64
+	Occurrence *time.Time
+	// occurrence of a series expanded on read, it has no ID
+	Virtual   bool
+	DeletedAt *time.Time
+	// search results only
+	Rank    *float32
+	Snippet *string
+}
+
+// IsRunning reports whether the event is an interval which was started but not stopped yet
+func (e *Event) IsRunning() bool {
+	return e.Type == EventTypeInterval && e.Timestamp != nil && e.Until == nil
+}
+
+var timezoneLocations sync.Map
+
+// LoadLocation returns the location of the IANA timezone name like time.LoadLocation,
+// locations are cached as every call reads the timezone database
+func LoadLocation(name string) (*time.Location, error) {
+	if location, ok := timezoneLocations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	timezoneLocations.Store(name, location)
+
+	return location, nil
+}
+
+// LocalTime returns the timestamps in the timezone of the event, or nil when the event has none
+func (e *Event) LocalTime() *LocalTime {
+	if e.Timezone == nil {
+		return nil
+	}
+
+	location, err := LoadLocation(*e.Timezone)
+	if err != nil {
+		return nil
+	}
+
+	local := &LocalTime{}
+	if e.Timestamp != nil {
+		local.Timestamp = e.Timestamp.In(location).Format(time.RFC3339)
+	}
+	if e.Until != nil {
+		local.Until = e.Until.In(location).Format(time.RFC3339)
+	}
+
+	return local
 }
 
 func (e *Event) ToEventResponse() *EventResponse {
@@ -38,6 +99,16 @@ func (e *Event) ToEventResponse() *EventResponse {
 		Note:       e.Note,
 		Reference:  e.Reference,
 		ProviderID: e.ProviderID,
+		ClientID:   e.ClientID,
+		Timezone:   e.Timezone,
+		Local:      e.LocalTime(),
+		SeriesID:   e.SeriesID,
+		Occurrence: e.Occurrence,
+		Virtual:    e.Virtual,
+		DeletedAt:  e.DeletedAt,
+		Running:    e.IsRunning(),
+		Rank:       e.Rank,
+		Snippet:    e.Snippet,
 	}
 }
 
@@ -49,9 +120,49 @@ type EventRequest struct {
 	Note       string     `json:"note,omitempty"`
 	Reference  string     `json:"-"`
 	ProviderID *int64     `json:"-"`
+	// key generated by the client, retried submissions with the same key return the original event
+	ClientID *string `json:"clientId,omitempty"`
+	// IANA timezone the event happened in, e.g. "Europe/Rome"
+	Timezone *string `json:"timezone,omitempty"`
+	// set when an occurrence of a series is materialized
+	SeriesID   *int64     `json:"-"`
+	Occurrence *time.Time `json:"-"`
+}
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const maxClientIDLength = 255
+
+// SetIdempotencyKey uses the Idempotency-Key header as the client ID when present
+func (e *EventRequest) SetIdempotencyKey(r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if len(key) > 0 {
+		e.ClientID = &key
+	}
+}
+
+// SetBatchIdempotencyKey derives the client ID of the item at index of a batch from the Idempotency-Key header,
+// so a retried batch returns the original events. Items with their own client ID keep it.
+func (e *EventRequest) SetBatchIdempotencyKey(r *http.Request, index int) {
+	key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if len(key) > 0 && e.ClientID == nil {
+		clientID := key + ":" + strconv.Itoa(index)
+		e.ClientID = &clientID
+	}
 }
 
 func (e *EventRequest) Validate() error {
+	if e.ClientID != nil && (len(*e.ClientID) == 0 || len(*e.ClientID) > maxClientIDLength) {
+		return errors.New("EventRequest.Validate: invalid client id")
+	}
+
+	if e.Timezone != nil {
+		_, err := LoadLocation(*e.Timezone)
+		if err != nil || len(*e.Timezone) == 0 {
+			return errors.New("EventRequest.Validate: invalid timezone " + *e.Timezone)
+		}
+	}
+
 	if e.Type == EventTypeInterval {
 		if e.Timestamp == nil && e.Until == nil {
 			return errors.New("EventRequest.Validate: missing timestamp or until")
@@ -79,6 +190,10 @@ func (e *EventRequest) ToEvent() *Event {
 		Note:       e.Note,
 		Reference:  e.Reference,
 		ProviderID: e.ProviderID,
+		ClientID:   e.ClientID,
+		Timezone:   e.Timezone,
+		SeriesID:   e.SeriesID,
+		Occurrence: e.Occurrence,
 	}
 }
 
@@ -98,6 +213,48 @@ func (e *UpdateEventRequest) ToEvent() *Event {
 	return event
 }
 
+// StartEventRequest opens an interval, the timestamp defaults to now
+type StartEventRequest struct {
+	Timestamp  *time.Time `json:"timestamp,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Note       string     `json:"note,omitempty"`
+	ProviderID *int64     `json:"-"`
+	ClientID   *string    `json:"clientId,omitempty"`
+	Timezone   *string    `json:"timezone,omitempty"`
+}
+
+// SetIdempotencyKey uses the Idempotency-Key header as the client ID when present, see EventRequest.SetIdempotencyKey
+func (e *StartEventRequest) SetIdempotencyKey(r *http.Request) {
+	key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	if len(key) > 0 {
+		e.ClientID = &key
+	}
+}
+
+func (e *StartEventRequest) ToCreateEventRequest() *CreateEventRequest {
+	timestamp := time.Now()
+	if e.Timestamp != nil {
+		timestamp = *e.Timestamp
+	}
+
+	return &CreateEventRequest{
+		EventRequest: EventRequest{
+			Type:       EventTypeInterval,
+			Timestamp:  &timestamp,
+			Tags:       e.Tags,
+			Note:       e.Note,
+			ProviderID: e.ProviderID,
+			ClientID:   e.ClientID,
+			Timezone:   e.Timezone,
+		},
+	}
+}
+
+// StopEventRequest closes a running interval, the until defaults to now
+type StopEventRequest struct {
+	Until *time.Time `json:"until,omitempty"`
+}
+
 type EventResponse struct {
 	ID         int64      `json:"id"`
 	Type       EventType  `json:"type"`
@@ -107,76 +264,299 @@ type EventResponse struct {
 	Note       string     `json:"note,omitempty"`
 	Reference  string     `json:"reference"`
 	ProviderID *int64     `json:"providerId,omitempty"`
+	ClientID   *string    `json:"clientId,omitempty"`
+	Timezone   *string    `json:"timezone,omitempty"`
+	Local      *LocalTime `json:"local,omitempty"`
+	SeriesID   *int64     `json:"seriesId,omitempty"`
+	Occurrence *time.Time `json:"occurrence,omitempty"`
+	Virtual    bool       `json:"virtual,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+	Running    bool       `json:"running,omitempty"`
+	Rank       *float32   `json:"rank,omitempty"`
+	Snippet    *string    `json:"snippet,omitempty"`
+	// only when requested with ?links
+	Links []EventLinkResponse `json:"links,omitempty"`
+}
+
+// LocalTime holds the timestamps of an event as wall clock time of its timezone
+type LocalTime struct {
+	Timestamp string `json:"timestamp,omitempty"`
+	Until     string `json:"until,omitempty"`
+}
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "asc"
+	SortOrderDesc SortOrder = "desc"
+)
+
+const (
+	// page size of a request without limit
+	DefaultEventLimit = 1000
+	MaxEventLimit     = 10000
+)
+
+// EventCursor points at the last event of a page, the next page starts right after it
+type EventCursor struct {
+	Timestamp time.Time `json:"t"`
+	ID        int64     `json:"id"`
+	// search results are sorted by rank instead of timestamp
+	Rank *float32 `json:"r,omitempty"`
+}
+
+func NewEventCursor(e *EventResponse) *EventCursor {
+	cursor := &EventCursor{ID: e.ID, Rank: e.Rank}
+	if e.Timestamp != nil {
+		cursor.Timestamp = *e.Timestamp
+	} else if e.Until != nil {
+		cursor.Timestamp = *e.Until
+	}
+
+	return cursor
+}
+
+// Encode returns the opaque representation of the cursor used in the API
+func (c *EventCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeEventCursor(value string) (*EventCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("DecodeEventCursor: invalid cursor")
+	}
+
+	cursor := &EventCursor{}
+	err = json.Unmarshal(data, cursor)
+	if err != nil {
+		return nil, errors.New("DecodeEventCursor: invalid cursor")
+	}
+
+	return cursor, nil
 }
 
 type EventQueryBuilder struct {
-	Type    EventType
-	From    time.Time
-	To      time.Time
-	Private bool
-	Tags    []string
+	// restrict the query to the given events
+	IDs  []int64
+	Type EventType
+	// restrict the query to the events of a module, e.g. "locations_history" or "raw"
+	Reference string
+	From      time.Time
+	To        time.Time
+	// timezone of date-only ranges and of buckets, UTC by default
+	Location *time.Location
+	Tags     []string
+	// private and trashed events are filtered out unless the visibility allows them
+	Visibility Visibility
+	// list only the events in the trash
+	Trash bool
+	// list only the running intervals, see Event.IsRunning
+	Running bool
+	// merge the occurrences of the series in the range, requires from and to
+	Expand bool
+	// include the links of the events in the response
+	Links bool
+	// match events tagged with any descendant of the requested tags as well
+	TagsDeep bool
+	// full-text query, see websearch_to_tsquery for the syntax
+	Search string
+	// search also in string values of raw payloads
+	SearchRaw bool
+	// maximum number of events in a page, 0 means no limit
+	Limit  int
+	Cursor *EventCursor
+	Order  SortOrder
 }
 
 func (b *EventQueryBuilder) FromRequest(r *http.Request) error {
-	b.Private = r.URL.Query().Has("private")
+	b.Visibility = VisibilityFromRequest(r)
+
+	b.Order = SortOrderAsc
+	if r.URL.Query().Has("order") {
+		b.Order = SortOrder(strings.ToLower(r.URL.Query().Get("order")))
+		if b.Order != SortOrderAsc && b.Order != SortOrderDesc {
+			return errors.New("EventQueryBuilder.FromRequest: invalid order " + string(b.Order))
+		}
+	}
+
+	b.Limit = DefaultEventLimit
+	if r.URL.Query().Has("limit") {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 {
+			return errors.New("EventQueryBuilder.FromRequest: invalid limit")
+		}
+		b.Limit = min(limit, MaxEventLimit)
+	}
+
+	if r.URL.Query().Has("cursor") {
+		cursor, err := DecodeEventCursor(r.URL.Query().Get("cursor"))
+		if err != nil {
+			return err
+		}
+		b.Cursor = cursor
+	}
+
+	b.Search = strings.TrimSpace(r.URL.Query().Get("q"))
+	b.SearchRaw = r.URL.Query().Has("searchRaw")
 
 	if r.URL.Query().Has("type") {
 		b.Type = EventType(r.URL.Query().Get("type"))
 	}
 
+	b.Reference = r.URL.Query().Get("reference")
+	b.Expand = r.URL.Query().Get("expand") == "true"
+	b.Links = r.URL.Query().Has("links")
+
 	b.Tags = []string{}
 	if r.URL.Query().Has("tags") {
 		query := r.URL.Query().Get("tags")
 		b.Tags = strings.Split(query, ",")
 	}
+	b.TagsDeep = r.URL.Query().Has("tagsDeep")
+
+	b.Location = time.UTC
+	if r.URL.Query().Has("tz") {
+		location, err := LoadLocation(r.URL.Query().Get("tz"))
+		if err != nil {
+			return errors.New("EventQueryBuilder.FromRequest: invalid tz")
+		}
+		b.Location = location
+	}
 
 	var err error = nil
 	if r.URL.Query().Has("from") {
-		b.From, err = time.Parse(time.RFC3339, r.URL.Query().Get("from"))
+		b.From, err = parseQueryTime(r.URL.Query().Get("from"), b.Location, false)
 		if err != nil {
 			return err
 		}
 	}
 
 	if r.URL.Query().Has("to") {
-		b.To, err = time.Parse(time.RFC3339, r.URL.Query().Get("to"))
+		b.To, err = parseQueryTime(r.URL.Query().Get("to"), b.Location, true)
 		if err != nil {
 			return err
 		}
 	}
 
+	// series have no end, their occurrences are expanded within the range only
+	if b.Expand && (b.From.IsZero() || b.To.IsZero()) {
+		return errors.New("EventQueryBuilder.FromRequest: expand requires from and to")
+	}
+
 	return nil
 }
 
+// parseQueryTime accepts RFC3339 timestamps or dates in the given timezone.
+// A date used as end of a range includes the whole day.
+func parseQueryTime(value string, location *time.Location, end bool) (time.Time, error) {
+	if len(value) == len(time.DateOnly) {
+		date, err := time.ParseInLocation(time.DateOnly, value, location)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if end {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetLocation returns the timezone of the query, UTC when none was requested
+func (b *EventQueryBuilder) GetLocation() *time.Location {
+	if b.Location == nil {
+		return time.UTC
+	}
+
+	return b.Location
+}
+
 func (b *EventQueryBuilder) Build() (string, []any) {
 	params := make([]any, 0)
 	and := make([]string, 0)
 
+	// the search query is always the first parameter, see searchQuery
+	if len(b.Search) > 0 {
+		params = append(params, b.Search)
+		where := "events.search @@ " + searchQuery
+		if b.SearchRaw {
+			where += " OR EXISTS (SELECT 1 FROM raw AS raw_search WHERE raw_search.event_id = events.id AND raw_search.search @@ " + searchQuery + ")"
+		}
+		and = append(and, "("+where+")")
+	}
+
+	if len(b.IDs) > 0 {
+		params = append(params, b.IDs)
+		where := fmt.Sprintf("events.id = ANY($%v)", len(params))
+		and = append(and, "("+where+")")
+	}
+
 	if len(b.Type) > 0 {
 		params = append(params, b.Type)
 		where := fmt.Sprintf("events.type = $%v", len(params))
 		and = append(and, "("+where+")")
 	}
 
+	if len(b.Reference) > 0 {
+		params = append(params, b.Reference)
+		where := fmt.Sprintf("events.reference = $%v", len(params))
+		and = append(and, "("+where+")")
+	}
+
 	if !b.From.IsZero() && !b.To.IsZero() {
 		params = append(params, b.From, b.To)
-		where := fmt.Sprintf("(events.type = 'interval' AND events.timestamp <= $%[2]v AND events.until >= $%[1]v) OR (events.type = 'moment' AND events.timestamp >= $%[1]v AND events.timestamp < $%[2]v)", len(params)-1, len(params))
+		// open ends of intervals reach to infinity, so running intervals overlap every range after their start
+		where := fmt.Sprintf("(events.type = 'interval' AND COALESCE(events.timestamp, '-infinity') <= $%[2]v AND COALESCE(events.until, 'infinity') >= $%[1]v) OR (events.type = 'moment' AND events.timestamp >= $%[1]v AND events.timestamp < $%[2]v)", len(params)-1, len(params))
 		and = append(and, "("+where+")")
 	}
 
-	if !b.Private {
-		where := `NOT EXISTS (
-			SELECT 1 FROM UNNEST(events.tags) AS event_tag JOIN tags ON event_tag = tags.tag WHERE tags.private = TRUE
-		)`
+	if b.Running {
+		and = append(and, "("+runningCondition+")")
+	}
+
+	if b.Trash {
+		b.Visibility.Trashed = true
+		and = append(and, "(events.deleted_at IS NOT NULL)")
+	}
+
+	if where := b.Visibility.EventCondition(); len(where) > 0 {
 		and = append(and, "("+where+")")
 	}
 
-	if len(b.Tags) > 0 {
+	if len(b.Tags) > 0 && b.TagsDeep {
+		for _, tag := range b.Tags {
+			params = append(params, tag)
+			where := "events.tags && " + tagSubtree(fmt.Sprintf("$%v", len(params)))
+			and = append(and, "("+where+")")
+		}
+	} else if len(b.Tags) > 0 {
 		params = append(params, b.Tags)
 		where := fmt.Sprintf("events.tags @> $%v", len(params))
 		and = append(and, "("+where+")")
 	}
 
+	if b.Cursor != nil && len(b.Search) > 0 {
+		var rank float32
+		if b.Cursor.Rank != nil {
+			rank = *b.Cursor.Rank
+		}
+		params = append(params, rank, b.Cursor.ID)
+		where := fmt.Sprintf("(%s, events.id) < ($%v::REAL, $%v)", b.rank(), len(params)-1, len(params))
+		and = append(and, "("+where+")")
+	} else if b.Cursor != nil {
+		params = append(params, b.Cursor.Timestamp, b.Cursor.ID)
+		operator := ">"
+		if b.Order == SortOrderDesc {
+			operator = "<"
+		}
+		where := fmt.Sprintf("(%s, events.id) %s ($%v, $%v)", eventSortKey, operator, len(params)-1, len(params))
+		and = append(and, "("+where+")")
+	}
+
 	if len(and) == 0 {
 		return "", []any{}
 	} else {
@@ -186,3 +566,130 @@ func (b *EventQueryBuilder) Build() (string, []any) {
 	}
 
 }
+
+// Matches evaluates the filters of Build in memory, the full-text search is not supported and ignored
+func (b *EventQueryBuilder) Matches(event *EventResponse, tags *TagIndex) bool {
+	if len(b.IDs) > 0 && !slices.Contains(b.IDs, event.ID) {
+		return false
+	}
+
+	if len(b.Type) > 0 && event.Type != b.Type {
+		return false
+	}
+
+	if len(b.Reference) > 0 && event.Reference != b.Reference {
+		return false
+	}
+
+	if !b.From.IsZero() && !b.To.IsZero() {
+		switch event.Type {
+		case EventTypeInterval:
+			// open ends of intervals reach to infinity
+			if (event.Timestamp != nil && event.Timestamp.After(b.To)) || (event.Until != nil && event.Until.Before(b.From)) {
+				return false
+			}
+		case EventTypeMoment:
+			if event.Timestamp == nil || event.Timestamp.Before(b.From) || !event.Timestamp.Before(b.To) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	if b.Running && (event.Type != EventTypeInterval || event.Timestamp == nil || event.Until != nil) {
+		return false
+	}
+
+	if b.Trash && event.DeletedAt == nil {
+		return false
+	}
+
+	if !b.Trash && !b.Visibility.Trashed && event.DeletedAt != nil {
+		return false
+	}
+
+	if !b.Visibility.Private && tags.Hidden(event.Tags) {
+		return false
+	}
+
+	for _, tag := range b.Tags {
+		if !b.TagsDeep && !slices.Contains(event.Tags, tag) {
+			return false
+		}
+
+		if b.TagsDeep {
+			subtree := tags.Subtree(tag)
+			if !slices.ContainsFunc(event.Tags, func(t string) bool { return subtree[t] }) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// tagSubtree returns the array of the tag and all its descendants following tags.parent
+func tagSubtree(tag string) string {
+	return `ARRAY(
+		WITH RECURSIVE subtree AS (
+			SELECT ` + tag + `::TEXT AS tag
+			UNION
+			SELECT tags.tag FROM tags INNER JOIN subtree ON tags.parent = subtree.tag
+		)
+		SELECT tag FROM subtree
+	)`
+}
+
+const runningCondition = "events.type = 'interval' AND events.timestamp IS NOT NULL AND events.until IS NULL"
+
+// events are sorted by timestamp, intervals without start fall back to their end
+const eventSortKey = "COALESCE(events.timestamp, events.until)"
+
+const searchQuery = "websearch_to_tsquery('simple', $1)"
+
+const searchHeadlineOptions = "'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'"
+
+func (b *EventQueryBuilder) rank() string {
+	rank := "ts_rank(events.search, " + searchQuery + ")"
+	if b.SearchRaw {
+		rank += " + COALESCE((SELECT ts_rank(raw_search.search, " + searchQuery + ") FROM raw AS raw_search WHERE raw_search.event_id = events.id), 0)"
+	}
+
+	return "(" + rank + ")"
+}
+
+// BuildColumns returns the rank and snippet columns of search results, or NULLs when not searching
+func (b *EventQueryBuilder) BuildColumns() string {
+	if len(b.Search) == 0 {
+		return "NULL::REAL AS rank, NULL::TEXT AS snippet"
+	}
+
+	snippet := "ts_headline('simple', COALESCE(events.note, ''), " + searchQuery + ", " + searchHeadlineOptions + ")"
+	if b.SearchRaw {
+		snippet = fmt.Sprintf(`CASE WHEN events.search @@ %s THEN %s
+			ELSE (SELECT ts_headline('simple', raw_search.data, %s, %s)::TEXT FROM raw AS raw_search WHERE raw_search.event_id = events.id)
+			END`, searchQuery, snippet, searchQuery, searchHeadlineOptions)
+	}
+
+	return b.rank() + " AS rank, " + snippet + " AS snippet"
+}
+
+// BuildOrder returns the ORDER BY and LIMIT clause matching the keyset condition from Build.
+// One extra row is requested so NewEventPage can tell whether there is a next page.
+func (b *EventQueryBuilder) BuildOrder() string {
+	direction := "ASC"
+	if b.Order == SortOrderDesc {
+		direction = "DESC"
+	}
+
+	order := fmt.Sprintf("ORDER BY %[1]s %[2]s, events.id %[2]s", eventSortKey, direction)
+	if len(b.Search) > 0 {
+		order = fmt.Sprintf("ORDER BY %s DESC, events.id DESC", b.rank())
+	}
+	if b.Limit > 0 {
+		order += fmt.Sprintf(" LIMIT %d", b.Limit+1)
+	}
+
+	return order
+}
@@ -0,0 +1,199 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EventChangesChannel is the PostgreSQL notification channel of event revisions, see RevisionRepository.QueueNotifyRevision
+const EventChangesChannel = "event_changes"
+
+type EventNotification struct {
+	RevisionID int64          `json:"revision"`
+	EventID    int64          `json:"eventId"`
+	Action     RevisionAction `json:"action"`
+}
+
+const (
+	subscriptionBuffer = 64
+	listenRetryDelay   = 5 * time.Second
+)
+
+// EventChange is a notified revision together with the state it stored, loaded once for all subscribers
+type EventChange struct {
+	RevisionID int64
+	// position of the revision in commit order, see Revision.StreamSeq
+	StreamSeq int64
+	Action    RevisionAction
+	// state after the change, for deletes the state before it moved to the trash
+	Event *EventSnapshot
+	// tags at the time the change was loaded, to filter the event in memory
+	Tags *TagIndex
+}
+
+// NewEventChange returns the change of the revision
+func NewEventChange(revision *Revision, tags *TagIndex) (*EventChange, error) {
+	snapshot, err := revision.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("NewEventChange: invalid revision %d, %v", revision.ID, err)
+	}
+
+	if revision.Action == RevisionActionDelete && snapshot.DeletedAt == nil {
+		snapshot.DeletedAt = &revision.Created
+	}
+
+	return &EventChange{
+		RevisionID: revision.ID,
+		StreamSeq:  revision.StreamSeq,
+		Action:     revision.Action,
+		Event:      snapshot,
+		Tags:       tags,
+	}, nil
+}
+
+// EventBroker listens on EventChangesChannel and fans the changes out to the subscribers of this instance.
+// Every API instance runs its own broker, so all of them see every change.
+type EventBroker struct {
+	db           *pgxpool.Pool
+	revisionRepo *RevisionRepository
+	tagRepo      *TagRepository
+	mu           sync.Mutex
+	subscribers  map[chan *EventChange]struct{}
+}
+
+func NewEventBroker(db *pgxpool.Pool, revisionRepo *RevisionRepository, tagRepo *TagRepository) *EventBroker {
+	return &EventBroker{
+		db:           db,
+		revisionRepo: revisionRepo,
+		tagRepo:      tagRepo,
+		subscribers:  make(map[chan *EventChange]struct{}),
+	}
+}
+
+// Subscribe returns the channel of the changes, it is closed when the subscriber falls behind
+// by more than subscriptionBuffer changes
+func (b *EventBroker) Subscribe() chan *EventChange {
+	ch := make(chan *EventChange, subscriptionBuffer)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[ch] = struct{}{}
+
+	return ch
+}
+
+func (b *EventBroker) Unsubscribe(ch chan *EventChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subscribers, ch)
+}
+
+// Run listens for notifications until the context is cancelled, the connection is re-established after errors
+func (b *EventBroker) Run(ctx context.Context) {
+	for {
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		fmt.Println("EventBroker.Run:", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(listenRetryDelay):
+		}
+	}
+}
+
+func (b *EventBroker) listen(ctx context.Context) error {
+	pooled, err := b.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the listening connection must not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+EventChangesChannel)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		event := EventNotification{}
+		err = json.Unmarshal([]byte(notification.Payload), &event)
+		if err != nil {
+			fmt.Println("EventBroker.listen: invalid payload,", err)
+			continue
+		}
+
+		if !b.hasSubscribers() {
+			continue
+		}
+
+		change, err := b.load(ctx, event)
+		if err != nil {
+			fmt.Println("EventBroker.listen:", err)
+			continue
+		}
+
+		if change != nil {
+			b.publish(change)
+		}
+	}
+}
+
+func (b *EventBroker) hasSubscribers() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.subscribers) > 0
+}
+
+// load reads the notified revision and the tags, returns nil when the revision no longer exists
+func (b *EventBroker) load(ctx context.Context, event EventNotification) (*EventChange, error) {
+	revision, err := b.revisionRepo.GetRevision(ctx, event.EventID, event.RevisionID, FullVisibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventBroker.load: failed to load revision %d, %v", event.RevisionID, err)
+	}
+
+	if revision == nil {
+		return nil, nil
+	}
+
+	tags, err := b.tagRepo.ListTags(ctx, FullVisibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventBroker.load: failed to load tags, %v", err)
+	}
+
+	return NewEventChange(revision, NewTagIndex(tags))
+}
+
+// publish never blocks, a subscriber with a full buffer is dropped and its channel closed,
+// so it can resynchronize instead of silently missing changes
+func (b *EventBroker) publish(change *EventChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- change:
+		default:
+			fmt.Println("EventBroker.publish: subscriber is too slow, dropping it at revision", change.RevisionID)
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
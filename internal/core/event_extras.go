@@ -0,0 +1,42 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"encoding/json"
+)
+
+// EventExtras is implemented by modules storing additional data for their events,
+// the module is identified by Event.Reference
+type EventExtras interface {
+	// GetExtras returns the module data of the event, or nil when there is none
+	GetExtras(ctx context.Context, eventID int64) (json.RawMessage, error)
+	// ListExtras returns the module data of the events by event id, events without data are left out
+	ListExtras(ctx context.Context, eventIDs []int64) (map[int64]json.RawMessage, error)
+	// RestoreExtras creates or replaces the module data of the event
+	RestoreExtras(ctx context.Context, eventID int64, data json.RawMessage) error
+}
+
+type ExtrasFactory func(conn db.DBTX) EventExtras
+
+type ExtrasRegistry struct {
+	factories map[string]ExtrasFactory
+}
+
+func NewExtrasRegistry() *ExtrasRegistry {
+	return &ExtrasRegistry{factories: make(map[string]ExtrasFactory)}
+}
+
+func (r *ExtrasRegistry) Register(reference string, factory ExtrasFactory) {
+	r.factories[reference] = factory
+}
+
+// Get returns the extras of the module owning the reference, or nil for core events
+func (r *ExtrasRegistry) Get(conn db.DBTX, reference string) EventExtras {
+	factory, ok := r.factories[reference]
+	if !ok {
+		return nil
+	}
+
+	return factory(conn)
+}
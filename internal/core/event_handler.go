@@ -18,10 +18,15 @@ func NewEventHandler(service *EventService) *EventHandler {
 func (h *EventHandler) GetRoutes() []handler.Route {
 	return []handler.Route{
 		handler.NewRoute("GET /api/core/events/{$}", h.GetEvents, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/events/running", h.GetRunningEvents, handler.RouteOwnerRole),
 		handler.NewRoute("GET /api/core/events/{id}", h.GetEvent, handler.RouteOwnerRole),
 		handler.NewRoute("PUT /api/core/events/{id}", h.UpdateEvent, handler.RouteOwnerRole),
 		handler.NewRoute("DELETE /api/core/events/{id}", h.DeleteEvent, handler.RouteOwnerRole),
 		handler.NewRoute("POST /api/core/events", h.CreateEvent, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/core/events/batch", h.CreateEvents, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/core/events/start", h.StartEvent, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/core/events/{id}/stop", h.StopEvent, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/core/series/{id}/occurrences", h.MaterializeOccurrence, handler.RouteOwnerRole),
 	}
 }
 
@@ -30,9 +35,28 @@ func (h *EventHandler) GetEvents(w http.ResponseWriter, r *http.Request) {
 	err := query.FromRequest(r)
 	if err != nil {
 		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListEvents(r.Context(), query)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+// GetRunningEvents lists the intervals which were started but not stopped yet, the usual filters apply
+func (h *EventHandler) GetRunningEvents(w http.ResponseWriter, r *http.Request) {
+	query := &EventQueryBuilder{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
 	}
+	query.Running = true
 
-	data, err := h.service.ListEvents(query)
+	data, err := h.service.ListEvents(r.Context(), query)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -47,7 +71,7 @@ func (h *EventHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	event, err := h.service.GetEvent(eventId)
+	event, err := h.service.GetEvent(r.Context(), eventId, VisibilityFromRequest(r), r.URL.Query().Has("links"))
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -62,7 +86,7 @@ func (h *EventHandler) GetEvent(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
-	claims, err := h.GetUserClaimsFromContext(r)
+	claims, err := h.GetClaimsFromContext(r)
 	if err != nil {
 		h.SendJSON(w, http.StatusForbidden, err.Error())
 		return
@@ -76,8 +100,9 @@ func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data.ProviderID = claims.ProviderID
+	data.SetIdempotencyKey(r)
 
-	result, err := h.service.CreateEvent(&data)
+	result, err := h.service.CreateEvent(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -86,6 +111,33 @@ func (h *EventHandler) CreateEvent(w http.ResponseWriter, r *http.Request) {
 	h.SendJSON(w, http.StatusCreated, result)
 }
 
+func (h *EventHandler) CreateEvents(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	data, atomic, err := BatchFromRequest[CreateEventRequest](r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i := range data {
+		data[i].ProviderID = claims.ProviderID
+		data[i].SetBatchIdempotencyKey(r, i)
+	}
+
+	result, err := h.service.CreateEvents(r.Context(), data, atomic)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, result.StatusCode(), result)
+}
+
 func (h *EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	eventId, err := h.GetInt64FromPath(r, "id")
 	if err != nil {
@@ -109,7 +161,7 @@ func (h *EventHandler) UpdateEvent(w http.ResponseWriter, r *http.Request) {
 	data.ID = eventId
 	data.ProviderID = claims.ProviderID
 
-	result, err := h.service.UpdateEvent(&data)
+	result, err := h.service.UpdateEvent(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -125,10 +177,91 @@ func (h *EventHandler) DeleteEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.DeleteEvent(eventId)
+	err = h.service.DeleteEvent(r.Context(), eventId)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+func (h *EventHandler) StartEvent(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	var data StartEventRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data.ProviderID = claims.ProviderID
+	data.SetIdempotencyKey(r)
+
+	result, err := h.service.StartEvent(r.Context(), &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
+
+func (h *EventHandler) StopEvent(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the body is optional
+	var data StopEventRequest
+	if r.ContentLength != 0 {
+		err = h.ParseJSON(r, &data)
+		if err != nil {
+			h.SendJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	result, err := h.service.StopEvent(r.Context(), eventId, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
+
+// MaterializeOccurrence turns an occurrence of the series into an event, e.g. to mark it as done or to add a note
+func (h *EventHandler) MaterializeOccurrence(w http.ResponseWriter, r *http.Request) {
+	seriesId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data OccurrenceRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.MaterializeOccurrence(r.Context(), seriesId, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "series not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
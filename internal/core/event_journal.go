@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// EventJournal records every change of an event made through the services of core and the modules.
+// All methods run inside the transaction of the change.
+type EventJournal struct {
+	eventRepo    *EventRepository
+	revisionRepo *RevisionRepository
+	webhookRepo  *WebhookRepository
+	extras       *ExtrasRegistry
+}
+
+func NewEventJournal(eventRepo *EventRepository, revisionRepo *RevisionRepository, webhookRepo *WebhookRepository, extras *ExtrasRegistry) *EventJournal {
+	return &EventJournal{
+		eventRepo:    eventRepo,
+		revisionRepo: revisionRepo,
+		webhookRepo:  webhookRepo,
+		extras:       extras,
+	}
+}
+
+// Snapshot loads the event together with its module extras, returns nil when the event does not exist
+func (j *EventJournal) Snapshot(ctx context.Context, tx pgx.Tx, eventID int64) (*EventSnapshot, error) {
+	event, err := j.eventRepo.WithTx(tx).GetEvent(ctx, eventID, FullVisibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventJournal.Snapshot: failed to load event, %v", err)
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	snapshot := &EventSnapshot{EventResponse: *event.ToEventResponse()}
+
+	extras := j.extras.Get(tx, event.Reference)
+	if extras != nil {
+		snapshot.Extras, err = extras.GetExtras(ctx, eventID)
+		if err != nil {
+			return nil, fmt.Errorf("EventJournal.Snapshot: failed to load extras, %v", err)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// Snapshots loads the events together with their module extras, by event id.
+// It runs one query for the events and one per module, events that do not exist are left out.
+func (j *EventJournal) Snapshots(ctx context.Context, tx pgx.Tx, eventIDs []int64) (map[int64]*EventSnapshot, error) {
+	snapshots := make(map[int64]*EventSnapshot, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return snapshots, nil
+	}
+
+	events, err := j.eventRepo.WithTx(tx).ListEvents(ctx, &EventQueryBuilder{IDs: eventIDs, Visibility: FullVisibility, Order: SortOrderAsc})
+	if err != nil {
+		return nil, fmt.Errorf("EventJournal.Snapshots: failed to load events, %v", err)
+	}
+
+	// ids of the events per module reference
+	references := make(map[string][]int64)
+	for _, event := range events {
+		snapshots[event.ID] = &EventSnapshot{EventResponse: *event.ToEventResponse()}
+		references[event.Reference] = append(references[event.Reference], event.ID)
+	}
+
+	for reference, ids := range references {
+		extras := j.extras.Get(tx, reference)
+		if extras == nil {
+			continue
+		}
+
+		data, err := extras.ListExtras(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("EventJournal.Snapshots: failed to load extras, %v", err)
+		}
+
+		for id, value := range data {
+			snapshots[id].Extras = value
+		}
+	}
+
+	return snapshots, nil
+}
+
+// Record stores a revision of the event, previous is the snapshot taken before the change
+func (j *EventJournal) Record(ctx context.Context, tx pgx.Tx, action RevisionAction, eventID int64, previous *EventSnapshot) error {
+	entry := journalEntry{eventID: eventID, previous: previous, state: previous}
+
+	if action != RevisionActionDelete && action != RevisionActionPurge {
+		current, err := j.Snapshot(ctx, tx, eventID)
+		if err != nil {
+			return err
+		}
+		entry.current = current
+		entry.state = current
+	}
+
+	return j.record(ctx, tx, action, []journalEntry{entry})
+}
+
+// RecordCreated stores the revisions of events created by a batch, current holds the state of every created event
+// so they are not loaded again
+func (j *EventJournal) RecordCreated(ctx context.Context, tx pgx.Tx, current []*EventSnapshot) error {
+	entries := make([]journalEntry, len(current))
+	for i, snapshot := range current {
+		entries[i] = journalEntry{eventID: snapshot.ID, current: snapshot, state: snapshot}
+	}
+
+	return j.record(ctx, tx, RevisionActionCreate, entries)
+}
+
+// RecordUpdates stores the revisions of events updated at once, previous holds their snapshots taken before the change.
+// The events are loaded again with Snapshots.
+func (j *EventJournal) RecordUpdates(ctx context.Context, tx pgx.Tx, eventIDs []int64, previous map[int64]*EventSnapshot) error {
+	current, err := j.Snapshots(ctx, tx, eventIDs)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]journalEntry, len(eventIDs))
+	for i, id := range eventIDs {
+		entries[i] = journalEntry{eventID: id, previous: previous[id], current: current[id], state: current[id]}
+	}
+
+	return j.record(ctx, tx, RevisionActionUpdate, entries)
+}
+
+type journalEntry struct {
+	eventID  int64
+	previous *EventSnapshot
+	// nil for deleted and purged events
+	current *EventSnapshot
+	// state delivered to webhooks
+	state *EventSnapshot
+}
+
+// record stores the revisions in one round trip, then notifies them and enqueues the webhooks in a second one
+func (j *EventJournal) record(ctx context.Context, tx pgx.Tx, action RevisionAction, entries []journalEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	userID, providerID := actorFromContext(ctx)
+
+	revisions := make([]*Revision, len(entries))
+	batch := &pgx.Batch{}
+	for i, entry := range entries {
+		revision := &Revision{
+			EventID:    entry.eventID,
+			Action:     action,
+			UserID:     userID,
+			ProviderID: providerID,
+		}
+
+		var err error
+		if entry.previous != nil {
+			revision.Previous, err = json.Marshal(entry.previous)
+			if err != nil {
+				return fmt.Errorf("EventJournal.Record: %v", err)
+			}
+		}
+
+		if action != RevisionActionDelete && action != RevisionActionPurge {
+			revision.Current, err = json.Marshal(entry.current)
+			if err != nil {
+				return fmt.Errorf("EventJournal.Record: %v", err)
+			}
+		}
+
+		revisions[i] = revision
+		j.revisionRepo.QueueCreateRevision(batch, revision)
+	}
+
+	err := tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("EventJournal.Record: failed to store revision, %v", err)
+	}
+
+	batch = &pgx.Batch{}
+	for i, entry := range entries {
+		err = j.revisionRepo.QueueNotifyRevision(batch, revisions[i])
+		if err != nil {
+			return fmt.Errorf("EventJournal.Record: failed to notify revision, %v", err)
+		}
+
+		// purged events were delivered as deleted when they were moved to the trash
+		if entry.state != nil && action != RevisionActionPurge {
+			err = j.webhookRepo.QueueDeliveries(batch, &WebhookPayload{
+				Revision: revisions[i].ID,
+				Action:   action,
+				Created:  revisions[i].Created,
+				Event:    entry.state,
+			})
+			if err != nil {
+				return fmt.Errorf("EventJournal.Record: failed to enqueue webhooks, %v", err)
+			}
+		}
+	}
+
+	err = tx.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("EventJournal.Record: failed to notify revision, %v", err)
+	}
+
+	return nil
+}
+
+// Restore writes the snapshot back, including the module extras
+func (j *EventJournal) Restore(ctx context.Context, tx pgx.Tx, snapshot *EventSnapshot) error {
+	_, err := j.eventRepo.WithTx(tx).RestoreEvent(ctx, &Event{
+		ID:         snapshot.ID,
+		Type:       snapshot.Type,
+		Timestamp:  snapshot.Timestamp,
+		Until:      snapshot.Until,
+		Tags:       snapshot.Tags,
+		Note:       snapshot.Note,
+		Reference:  snapshot.Reference,
+		ProviderID: snapshot.ProviderID,
+		ClientID:   snapshot.ClientID,
+		Timezone:   snapshot.Timezone,
+		SeriesID:   snapshot.SeriesID,
+		Occurrence: snapshot.Occurrence,
+		DeletedAt:  snapshot.DeletedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("EventJournal.Restore: failed to restore event, %v", err)
+	}
+
+	extras := j.extras.Get(tx, snapshot.Reference)
+	if extras != nil && len(snapshot.Extras) > 0 {
+		err = extras.RestoreExtras(ctx, snapshot.ID, snapshot.Extras)
+		if err != nil {
+			return fmt.Errorf("EventJournal.Restore: failed to restore extras, %v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+type EventLinkType string
+
+const (
+	EventLinkCausedBy    EventLinkType = "caused-by"
+	EventLinkPartOf      EventLinkType = "part-of"
+	EventLinkFollowUp    EventLinkType = "follow-up"
+	EventLinkDuplicateOf EventLinkType = "duplicate-of"
+)
+
+// MaxEventGraphDepth limits the hops of GET /api/core/events/{id}/graph
+const MaxEventGraphDepth = 5
+
+// EventLink is a directed relation, e.g. the source event is caused by the target event
+type EventLink struct {
+	ID       int64
+	SourceID int64
+	TargetID int64
+	Type     EventLinkType
+	Created  time.Time
+}
+
+func (l *EventLink) ToEventLinkResponse() *EventLinkResponse {
+	return &EventLinkResponse{
+		ID:       l.ID,
+		SourceID: l.SourceID,
+		TargetID: l.TargetID,
+		Type:     l.Type,
+		Created:  l.Created,
+	}
+}
+
+type EventLinkRequest struct {
+	SourceID int64         `json:"-"`
+	TargetID int64         `json:"targetId"`
+	Type     EventLinkType `json:"type"`
+}
+
+func (r *EventLinkRequest) Validate() error {
+	switch r.Type {
+	case EventLinkCausedBy, EventLinkPartOf, EventLinkFollowUp, EventLinkDuplicateOf:
+	default:
+		return errors.New("EventLinkRequest.Validate: invalid type " + string(r.Type))
+	}
+
+	if r.SourceID == r.TargetID {
+		return errors.New("EventLinkRequest.Validate: an event cannot link to itself")
+	}
+
+	return nil
+}
+
+type EventLinkResponse struct {
+	ID       int64         `json:"id"`
+	SourceID int64         `json:"sourceId"`
+	TargetID int64         `json:"targetId"`
+	Type     EventLinkType `json:"type"`
+	Created  time.Time     `json:"created"`
+}
+
+// EventGraph is the neighbourhood of an event, the links connect the returned events only
+type EventGraph struct {
+	Root   int64               `json:"root"`
+	Depth  int                 `json:"depth"`
+	Events []EventResponse     `json:"events"`
+	Links  []EventLinkResponse `json:"links"`
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+	"strconv"
+)
+
+type EventLinkHandler struct {
+	handler.BaseHandler
+
+	service *EventLinkService
+}
+
+func NewEventLinkHandler(service *EventLinkService) *EventLinkHandler {
+	return &EventLinkHandler{service: service}
+}
+
+func (h *EventLinkHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/events/{id}/links", h.ListLinks, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/events/{id}/links", h.CreateLink, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/events/{id}/links/{linkId}", h.DeleteLink, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/events/{id}/graph", h.GetGraph, handler.RouteOwnerRole),
+	}
+}
+
+func (h *EventLinkHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListLinks(r.Context(), eventId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "event not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+// CreateLink links the event in the path, the source, to the target of the body
+func (h *EventLinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data EventLinkRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data.SourceID = eventId
+
+	result, err := h.service.CreateLink(r.Context(), &data, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "event not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
+
+func (h *EventLinkHandler) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	linkId, err := h.GetInt64FromPath(r, "linkId")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deleted, err := h.service.DeleteLink(r.Context(), eventId, linkId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !deleted {
+		h.SendJSON(w, http.StatusNotFound, "link not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetGraph returns the event with its linked neighbourhood, ?depth sets the number of hops (default 1)
+func (h *EventLinkHandler) GetGraph(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	depth := 1
+	if r.URL.Query().Has("depth") {
+		depth, err = strconv.Atoi(r.URL.Query().Get("depth"))
+		if err != nil || depth < 0 {
+			h.SendJSON(w, http.StatusBadRequest, "invalid depth")
+			return
+		}
+		depth = min(depth, MaxEventGraphDepth)
+	}
+
+	data, err := h.service.GetGraph(r.Context(), eventId, depth, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "event not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
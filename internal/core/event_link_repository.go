@@ -0,0 +1,168 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type EventLinkRepository struct {
+	db db.DBTX
+}
+
+func NewEventLinkRepository(db *pgxpool.Pool) *EventLinkRepository {
+	return &EventLinkRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *EventLinkRepository) WithTx(tx pgx.Tx) *EventLinkRepository {
+	return &EventLinkRepository{db: tx}
+}
+
+// linkVisibility returns the SQL condition hiding links with an end the caller may not see
+func linkVisibility(visibility Visibility) string {
+	condition := visibility.EventCondition()
+	if len(condition) == 0 {
+		return "TRUE"
+	}
+
+	return `EXISTS (SELECT 1 FROM events WHERE events.id = event_links.source_id AND ` + condition + `)
+		AND EXISTS (SELECT 1 FROM events WHERE events.id = event_links.target_id AND ` + condition + `)`
+}
+
+// ListLinks returns the links from or to any of the events, with between both ends have to be in the list
+func (r *EventLinkRepository) ListLinks(ctx context.Context, eventIDs []int64, between bool, visibility Visibility) ([]EventLink, error) {
+	where := "(source_id = ANY($1) OR target_id = ANY($1))"
+	if between {
+		where = "source_id = ANY($1) AND target_id = ANY($1)"
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, source_id, target_id, type, created
+		FROM event_links
+		WHERE `+where+` AND `+linkVisibility(visibility)+`
+		ORDER BY id ASC
+	`, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]EventLink, 0)
+	for rows.Next() {
+		link := EventLink{}
+		err = rows.Scan(&link.ID, &link.SourceID, &link.TargetID, &link.Type, &link.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// CreateLink inserts the link, an existing link of the same type between the events is returned as is
+func (r *EventLinkRepository) CreateLink(ctx context.Context, link *EventLink) (*EventLink, error) {
+	result := EventLink{}
+	err := r.db.QueryRow(ctx, `
+		WITH inserted AS (
+			INSERT INTO event_links (source_id, target_id, type)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (source_id, target_id, type) DO NOTHING
+			RETURNING id, source_id, target_id, type, created
+		)
+		SELECT id, source_id, target_id, type, created FROM inserted
+		UNION ALL
+		SELECT id, source_id, target_id, type, created FROM event_links
+		WHERE source_id = $1 AND target_id = $2 AND type = $3
+		LIMIT 1
+	`, link.SourceID, link.TargetID, link.Type).Scan(&result.ID, &result.SourceID, &result.TargetID, &result.Type, &result.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CreateLinks inserts the links in one statement, existing links are kept
+func (r *EventLinkRepository) CreateLinks(ctx context.Context, links []*EventLink) error {
+	sources := make([]int64, len(links))
+	targets := make([]int64, len(links))
+	types := make([]string, len(links))
+	for i, link := range links {
+		sources[i], targets[i], types[i] = link.SourceID, link.TargetID, string(link.Type)
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO event_links (source_id, target_id, type)
+		SELECT * FROM unnest($1::BIGINT[], $2::BIGINT[], $3::TEXT[])
+		ON CONFLICT (source_id, target_id, type) DO NOTHING
+	`, sources, targets, types)
+
+	return err
+}
+
+// DeleteLink removes the link when the event is one of its ends
+// DeleteLink removes the link from or to the event when the caller may see both ends, false when there is none
+func (r *EventLinkRepository) DeleteLink(ctx context.Context, eventID, linkID int64, visibility Visibility) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM event_links
+		WHERE id = $1 AND (source_id = $2 OR target_id = $2) AND `+linkVisibility(visibility),
+		linkID, eventID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return cmd.RowsAffected() == 1, nil
+}
+
+// ListNeighbourhood returns the IDs of the events reachable from the event in up to depth links, in either direction.
+// Events the caller may not see break the path.
+func (r *EventLinkRepository) ListNeighbourhood(ctx context.Context, eventID int64, depth int, visibility Visibility) ([]int64, error) {
+	condition := visibility.EventCondition()
+	if len(condition) == 0 {
+		condition = "TRUE"
+	}
+
+	rows, err := r.db.Query(ctx, `
+		WITH RECURSIVE graph(id, depth) AS (
+			SELECT $1::BIGINT, 0
+			UNION
+			SELECT events.id, graph.depth + 1
+			FROM graph
+			INNER JOIN event_links ON event_links.source_id = graph.id OR event_links.target_id = graph.id
+			INNER JOIN events ON events.id = CASE WHEN event_links.source_id = graph.id THEN event_links.target_id ELSE event_links.source_id END
+			WHERE graph.depth < $2 AND `+condition+`
+		)
+		SELECT DISTINCT id FROM graph
+	`, eventID, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+type EventLinkService struct {
+	linkRepo  *EventLinkRepository
+	eventRepo *EventRepository
+}
+
+func NewEventLinkService(linkRepo *EventLinkRepository, eventRepo *EventRepository) *EventLinkService {
+	return &EventLinkService{
+		linkRepo:  linkRepo,
+		eventRepo: eventRepo,
+	}
+}
+
+// ListLinks returns the links from and to the event, nil when the caller may not see the event
+func (s *EventLinkService) ListLinks(ctx context.Context, eventID int64, visibility Visibility) ([]EventLinkResponse, error) {
+	event, err := s.eventRepo.GetEvent(ctx, eventID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.ListLinks: %v", err)
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	links, err := s.linkRepo.ListLinks(ctx, []int64{eventID}, false, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.ListLinks: %v", err)
+	}
+
+	result := make([]EventLinkResponse, len(links))
+	for i, link := range links {
+		result[i] = *link.ToEventLinkResponse()
+	}
+
+	return result, nil
+}
+
+// CreateLink links two events the caller can see, nil when one of them is missing
+func (s *EventLinkService) CreateLink(ctx context.Context, request *EventLinkRequest, visibility Visibility) (*EventLinkResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	// trashed events cannot be linked
+	visibility.Trashed = false
+	for _, id := range []int64{request.SourceID, request.TargetID} {
+		event, err := s.eventRepo.GetEvent(ctx, id, visibility)
+		if err != nil {
+			return nil, fmt.Errorf("EventLinkService.CreateLink: %v", err)
+		}
+
+		if event == nil {
+			return nil, nil
+		}
+	}
+
+	link, err := s.linkRepo.CreateLink(ctx, &EventLink{
+		SourceID: request.SourceID,
+		TargetID: request.TargetID,
+		Type:     request.Type,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.CreateLink: %v", err)
+	}
+
+	return link.ToEventLinkResponse(), nil
+}
+
+// DeleteLink removes a link of the event, false when the caller may not see the event or the link
+func (s *EventLinkService) DeleteLink(ctx context.Context, eventID, linkID int64, visibility Visibility) (bool, error) {
+	event, err := s.eventRepo.GetEvent(ctx, eventID, visibility)
+	if err != nil {
+		return false, fmt.Errorf("EventLinkService.DeleteLink: %v", err)
+	}
+
+	if event == nil {
+		return false, nil
+	}
+
+	deleted, err := s.linkRepo.DeleteLink(ctx, eventID, linkID, visibility)
+	if err != nil {
+		return false, fmt.Errorf("EventLinkService.DeleteLink: %v", err)
+	}
+
+	return deleted, nil
+}
+
+// GetGraph returns the event together with the events reachable in up to depth links, nil when the caller may not see the event
+func (s *EventLinkService) GetGraph(ctx context.Context, eventID int64, depth int, visibility Visibility) (*EventGraph, error) {
+	root, err := s.eventRepo.GetEvent(ctx, eventID, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.GetGraph: %v", err)
+	}
+
+	if root == nil {
+		return nil, nil
+	}
+
+	ids, err := s.linkRepo.ListNeighbourhood(ctx, eventID, depth, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.GetGraph: failed to walk links, %v", err)
+	}
+
+	events, err := s.eventRepo.ListEvents(ctx, &EventQueryBuilder{IDs: ids, Visibility: visibility, Order: SortOrderAsc})
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.GetGraph: failed to load events, %v", err)
+	}
+
+	links, err := s.linkRepo.ListLinks(ctx, ids, true, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("EventLinkService.GetGraph: failed to load links, %v", err)
+	}
+
+	graph := &EventGraph{
+		Root:   eventID,
+		Depth:  depth,
+		Events: make([]EventResponse, len(events)),
+		Links:  make([]EventLinkResponse, len(links)),
+	}
+	for i, event := range events {
+		graph.Events[i] = *event.ToEventResponse()
+	}
+	for i, link := range links {
+		graph.Links[i] = *link.ToEventLinkResponse()
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,19 @@
+package core
+
+type EventPage[T any] struct {
+	Data []T    `json:"data"`
+	Next string `json:"next,omitempty"`
+}
+
+// NewEventPage drops the extra row requested by EventQueryBuilder.BuildOrder and sets the cursor of the next page
+func NewEventPage[T any](items []T, query *EventQueryBuilder, event func(item *T) *EventResponse) *EventPage[T] {
+	page := &EventPage[T]{Data: items}
+
+	if query.Limit > 0 && len(items) > query.Limit {
+		page.Data = items[:query.Limit]
+		last := event(&page.Data[len(page.Data)-1])
+		page.Next = NewEventCursor(last).Encode()
+	}
+
+	return page
+}
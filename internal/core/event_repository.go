@@ -1,22 +1,33 @@
 package core
 
 import (
+	"backend/internal/db"
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// ErrDuplicateEvent is returned when the provider retries a submission with an already used client ID
+var ErrDuplicateEvent = errors.New("EventRepository: duplicate client id")
+
 type EventRepository struct {
-	db *pgxpool.Pool
+	db db.DBTX
 }
 
 func NewEventRepository(db *pgxpool.Pool) *EventRepository {
 	return &EventRepository{db: db}
 }
 
-func (r *EventRepository) ListEvents(queryBuilder *EventQueryBuilder) ([]Event, error) {
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *EventRepository) WithTx(tx pgx.Tx) *EventRepository {
+	return &EventRepository{db: tx}
+}
+
+func (r *EventRepository) ListEvents(ctx context.Context, queryBuilder *EventQueryBuilder) ([]Event, error) {
 	where, params := queryBuilder.Build()
 	query := fmt.Sprintf(`
 		SELECT
@@ -27,15 +38,19 @@ func (r *EventRepository) ListEvents(queryBuilder *EventQueryBuilder) ([]Event,
 			tags,
 			note,
 			reference,
-			provider_id
+			provider_id,
+			client_id,
+			timezone,
+			series_id,
+			occurrence,
+			deleted_at,
+			%s
 		FROM events
 		%s
-		ORDER BY timestamp ASC
-	`, where)
-	fmt.Println(query)
-	fmt.Println(params)
+		%s
+	`, queryBuilder.BuildColumns(), where, queryBuilder.BuildOrder())
 
-	rows, err := r.db.Query(context.Background(), query, params...)
+	rows, err := r.db.Query(ctx, query, params...)
 
 	if err != nil {
 		return nil, err
@@ -45,7 +60,7 @@ func (r *EventRepository) ListEvents(queryBuilder *EventQueryBuilder) ([]Event,
 	events := make([]Event, 0)
 	for rows.Next() {
 		event := Event{}
-		err = rows.Scan(&event.ID, &event.Type, &event.Timestamp, &event.Until, &event.Tags, &event.Note, &event.Reference, &event.ProviderID)
+		err = rows.Scan(&event.ID, &event.Type, &event.Timestamp, &event.Until, &event.Tags, &event.Note, &event.Reference, &event.ProviderID, &event.ClientID, &event.Timezone, &event.SeriesID, &event.Occurrence, &event.DeletedAt, &event.Rank, &event.Snippet)
 		if err != nil {
 			return nil, err
 		}
@@ -53,13 +68,22 @@ func (r *EventRepository) ListEvents(queryBuilder *EventQueryBuilder) ([]Event,
 		events = append(events, event)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return events, nil
 }
 
-func (r *EventRepository) GetEvent(id int64) (*Event, error) {
+func (r *EventRepository) GetEvent(ctx context.Context, id int64, visibility Visibility) (*Event, error) {
 	event := Event{}
 
-	err := r.db.QueryRow(context.Background(), `
+	where := "WHERE id = $1"
+	if condition := visibility.EventCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	err := r.db.QueryRow(ctx, `
 		SELECT
 		    id,
 		    type,
@@ -68,13 +92,21 @@ func (r *EventRepository) GetEvent(id int64) (*Event, error) {
 		    tags,
 		    note,
 			reference,
-			provider_id
+			provider_id,
+			client_id,
+			timezone,
+			series_id,
+			occurrence,
+			deleted_at
 		FROM events
-		WHERE id = $1
-	`, id).Scan(
-		&event.ID, &event.Type, &event.Timestamp, &event.Until, &event.Tags, &event.Note, &event.Reference, &event.ProviderID,
+		`+where, id).Scan(
+		&event.ID, &event.Type, &event.Timestamp, &event.Until, &event.Tags, &event.Note, &event.Reference, &event.ProviderID, &event.ClientID, &event.Timezone, &event.SeriesID, &event.Occurrence, &event.DeletedAt,
 	)
 
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -82,22 +114,126 @@ func (r *EventRepository) GetEvent(id int64) (*Event, error) {
 	return &event, nil
 }
 
-func (r *EventRepository) CreateEvent(event *Event) (*Event, error) {
+// CreateEvent inserts the event, returns ErrDuplicateEvent when the provider already submitted an event with the same client ID
+func (r *EventRepository) CreateEvent(ctx context.Context, event *Event) (*Event, error) {
 	var id int64 = 0
-	err := r.db.QueryRow(context.Background(), `
-		INSERT INTO events (type, timestamp, until, tags, note, reference, provider_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO events (type, timestamp, until, tags, note, reference, provider_id, client_id, timezone, series_id, occurrence)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (COALESCE(provider_id, 0), client_id) WHERE client_id IS NOT NULL DO NOTHING
 		RETURNING id
-	`, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID).Scan(&id)
+	`, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID, event.ClientID, event.Timezone, event.SeriesID, event.Occurrence).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, ErrDuplicateEvent
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetEvent(ctx, id, FullVisibility)
+}
+
+// CreateEvents inserts the events in one round trip and returns them in order.
+// The entry is nil when the provider already submitted an event with the same client ID, see CreateEvent.
+// A failing insert is reported as *BatchItemError.
+func (r *EventRepository) CreateEvents(ctx context.Context, events []*Event) ([]*Event, error) {
+	created := make([]*Event, len(events))
+
+	batch := &pgx.Batch{}
+	for i, event := range events {
+		batch.Queue(`
+			INSERT INTO events (type, timestamp, until, tags, note, reference, provider_id, client_id, timezone, series_id, occurrence)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (COALESCE(provider_id, 0), client_id) WHERE client_id IS NOT NULL DO NOTHING
+			RETURNING id, type, timestamp, until, tags, note, reference, provider_id, client_id, timezone, series_id, occurrence, deleted_at
+		`, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID, event.ClientID, event.Timezone, event.SeriesID, event.Occurrence).QueryRow(func(row pgx.Row) error {
+			result := Event{}
+			err := row.Scan(&result.ID, &result.Type, &result.Timestamp, &result.Until, &result.Tags, &result.Note, &result.Reference, &result.ProviderID, &result.ClientID, &result.Timezone, &result.SeriesID, &result.Occurrence, &result.DeletedAt)
+			if err == pgx.ErrNoRows {
+				return nil
+			}
+
+			if err != nil {
+				return &BatchItemError{Index: i, Err: err}
+			}
+
+			created[i] = &result
+			return nil
+		})
+	}
+
+	err := r.db.SendBatch(ctx, batch).Close()
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetEvent(id)
+	return created, nil
 }
 
-func (r *EventRepository) UpdateEvent(event *Event) (*Event, error) {
-	cmd, err := r.db.Exec(context.Background(), `
+// GetEventByClientID returns the event submitted by the provider with the client ID, or nil
+func (r *EventRepository) GetEventByClientID(ctx context.Context, providerID *int64, clientID string) (*Event, error) {
+	var id int64 = 0
+	err := r.db.QueryRow(ctx, `
+		SELECT id
+		FROM events
+		WHERE COALESCE(provider_id, 0) = COALESCE($1, 0) AND client_id = $2
+	`, providerID, clientID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetEvent(ctx, id, FullVisibility)
+}
+
+// ListClientIDs returns the client IDs of the list used by events submitted without provider
+func (r *EventRepository) ListClientIDs(ctx context.Context, clientIDs []string) (map[string]bool, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT client_id
+		FROM events
+		WHERE provider_id IS NULL AND client_id = ANY($1)
+	`, clientIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool, len(used))
+	for _, clientID := range used {
+		result[clientID] = true
+	}
+
+	return result, nil
+}
+
+// AddTags appends the tags to the events in one round trip, tags an event already has are skipped
+func (r *EventRepository) AddTags(ctx context.Context, tags map[int64][]string) error {
+	batch := &pgx.Batch{}
+	for id, added := range tags {
+		batch.Queue(`
+			UPDATE events
+			SET tags = tags || ARRAY(
+				SELECT tag FROM unnest($2::TEXT[]) WITH ORDINALITY AS added(tag, position)
+				WHERE NOT tag = ANY(events.tags)
+				ORDER BY position
+			)
+			WHERE id = $1
+		`, id, added)
+	}
+
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+func (r *EventRepository) UpdateEvent(ctx context.Context, event *Event) (*Event, error) {
+	cmd, err := r.db.Exec(ctx, `
 		UPDATE events
 		SET type = $2,
 		    timestamp = $3,
@@ -105,9 +241,10 @@ func (r *EventRepository) UpdateEvent(event *Event) (*Event, error) {
 		    tags = $5,
 		    note = $6,
 		    reference = $7,
-		    provider_id = $8
-		WHERE id = $1
-	`, event.ID, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID)
+		    provider_id = $8,
+		    timezone = $9
+		WHERE id = $1 AND deleted_at IS NULL
+	`, event.ID, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID, event.Timezone)
 	if err != nil {
 		return nil, err
 	}
@@ -120,13 +257,93 @@ func (r *EventRepository) UpdateEvent(event *Event) (*Event, error) {
 		return nil, errors.New("Update: too many rows updated")
 	}
 
-	return r.GetEvent(event.ID)
+	return r.GetEvent(ctx, event.ID, FullVisibility)
 }
 
-func (r *EventRepository) DeleteEvent(id int64) error {
-	cmd, err := r.db.Exec(context.Background(), `
-		DELETE FROM events
-		WHERE id = $1
+// StopEvent closes the running interval
+func (r *EventRepository) StopEvent(ctx context.Context, id int64, until time.Time) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET until = $2
+		WHERE id = $1 AND deleted_at IS NULL AND `+runningCondition+` AND events.timestamp <= $2
+	`, id, until)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("Stop: no running interval started before until")
+	}
+
+	return nil
+}
+
+// ListRunningExclusive returns the IDs of running intervals carrying any of the tags marked as exclusive
+func (r *EventRepository) ListRunningExclusive(ctx context.Context, tags []string) ([]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT events.id
+		FROM events
+		WHERE events.deleted_at IS NULL AND `+runningCondition+`
+			AND events.tags && ARRAY(SELECT tag FROM tags WHERE exclusive AND tag = ANY($1))
+		ORDER BY events.timestamp ASC
+	`, tags)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RestoreEvent writes the event back with its original ID, recreating it when it was deleted
+func (r *EventRepository) RestoreEvent(ctx context.Context, event *Event) (*Event, error) {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO events (id, type, timestamp, until, tags, note, reference, provider_id, client_id, timezone, series_id, occurrence, deleted_at)
+		OVERRIDING SYSTEM VALUE
+		-- the series may be gone since the snapshot was taken
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, (SELECT id FROM series WHERE id = $11), $12, $13)
+		ON CONFLICT (id) DO UPDATE
+		SET type = EXCLUDED.type,
+		    timestamp = EXCLUDED.timestamp,
+		    until = EXCLUDED.until,
+		    tags = EXCLUDED.tags,
+		    note = EXCLUDED.note,
+		    reference = EXCLUDED.reference,
+		    provider_id = EXCLUDED.provider_id,
+		    client_id = EXCLUDED.client_id,
+		    timezone = EXCLUDED.timezone,
+		    series_id = EXCLUDED.series_id,
+		    occurrence = EXCLUDED.occurrence,
+		    deleted_at = EXCLUDED.deleted_at
+	`, event.ID, event.Type, event.Timestamp, event.Until, event.Tags, event.Note, event.Reference, event.ProviderID, event.ClientID, event.Timezone, event.SeriesID, event.Occurrence, event.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetEvent(ctx, event.ID, FullVisibility)
+}
+
+// DeleteEvent moves the event to the trash, the module extras are kept until the event is purged
+func (r *EventRepository) DeleteEvent(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET deleted_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND deleted_at IS NULL
 	`, id)
 	if err != nil {
 		return err
@@ -139,13 +356,125 @@ func (r *EventRepository) DeleteEvent(id int64) error {
 	return nil
 }
 
-func (r *EventRepository) UsedTags() ([]string, error) {
-	rows, err := r.db.Query(context.Background(), `
+// UndeleteEvent takes the event out of the trash
+func (r *EventRepository) UndeleteEvent(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("Undelete: no rows affected")
+	}
+
+	return nil
+}
+
+// PurgeEvent permanently deletes the trashed event, the module extras are removed by ON DELETE CASCADE
+func (r *EventRepository) PurgeEvent(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM events
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("Purge: no rows affected")
+	}
+
+	return nil
+}
+
+// ListTrashed returns the IDs of visible events trashed before the given time
+func (r *EventRepository) ListTrashed(ctx context.Context, before time.Time, visibility Visibility) ([]int64, error) {
+	visibility.Trashed = true
+	where := "WHERE deleted_at IS NOT NULL AND deleted_at < $1"
+	if condition := visibility.EventCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id
+		FROM events
+		`+where+`
+		ORDER BY deleted_at
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ListTagged returns the IDs of all events tagged with the tag or one of its namespaced descendants, trashed ones included
+func (r *EventRepository) ListTagged(ctx context.Context, tag string) ([]int64, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id
+		FROM events
+		WHERE EXISTS (SELECT 1 FROM unnest(tags) AS event_tag WHERE event_tag = $1 OR starts_with(event_tag, $1 || '`+TagSeparator+`'))
+		ORDER BY id
+		FOR UPDATE
+	`, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[int64])
+}
+
+// ReplaceTag renames the tag and its namespaced descendants in the events,
+// a renamed tag the event already carries is not added twice
+func (r *EventRepository) ReplaceTag(ctx context.Context, ids []int64, tag, target string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET tags = ARRAY(
+			SELECT renamed
+			FROM (
+				SELECT CASE
+					WHEN event_tag = $1 OR starts_with(event_tag, $1 || '`+TagSeparator+`') THEN $2 || substr(event_tag, length($1) + 1)
+					ELSE event_tag
+				END AS renamed, position
+				FROM unnest(tags) WITH ORDINALITY AS event_tags (event_tag, position)
+			) AS renamed_tags
+			GROUP BY renamed
+			ORDER BY min(position)
+		)
+		WHERE id = ANY($3)
+	`, tag, target, ids)
+
+	return err
+}
+
+func (r *EventRepository) UsedTags(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
 		SELECT DISTINCT unnest(tags) AS unique_tag FROM events;
 	`)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	result := make([]string, 0)
 	for rows.Next() {
@@ -158,5 +487,9 @@ func (r *EventRepository) UsedTags() ([]string, error) {
 		result = append(result, tag)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
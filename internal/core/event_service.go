@@ -1,48 +1,124 @@
 package core
 
 import (
+	"backend/internal/db"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 type EventService struct {
-	repo *EventRepository
+	txManager  *db.TxManager
+	repo       *EventRepository
+	seriesRepo *SeriesRepository
+	linkRepo   *EventLinkRepository
+	journal    *EventJournal
+	rules      *RuleEngine
 }
 
-func NewEventService(repo *EventRepository) *EventService {
-	return &EventService{repo: repo}
+func NewEventService(txManager *db.TxManager, repo *EventRepository, seriesRepo *SeriesRepository, linkRepo *EventLinkRepository, journal *EventJournal, rules *RuleEngine) *EventService {
+	return &EventService{
+		txManager:  txManager,
+		repo:       repo,
+		seriesRepo: seriesRepo,
+		linkRepo:   linkRepo,
+		journal:    journal,
+		rules:      rules,
+	}
 }
 
-func (s *EventService) ListEvents(query *EventQueryBuilder) ([]EventResponse, error) {
-	events, err := s.repo.ListEvents(query)
+func (s *EventService) ListEvents(ctx context.Context, query *EventQueryBuilder) (*EventPage[EventResponse], error) {
+	events, err := s.repo.ListEvents(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
+	if query.Expand {
+		events, err = s.expandSeries(ctx, query, events)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	result := make([]EventResponse, len(events))
 	for i, event := range events {
 		result[i] = *event.ToEventResponse()
 	}
 
-	return result, nil
+	if query.Links {
+		err = s.attachLinks(ctx, result, query.Visibility)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewEventPage(result, query, func(e *EventResponse) *EventResponse { return e }), nil
 }
 
-func (s *EventService) GetEvent(id int64) (*EventResponse, error) {
-	event, err := s.repo.GetEvent(id)
+func (s *EventService) GetEvent(ctx context.Context, id int64, visibility Visibility, withLinks bool) (*EventResponse, error) {
+	event, err := s.repo.GetEvent(ctx, id, visibility)
 	if err != nil {
 		return nil, err
 	}
 
-	return event.ToEventResponse(), nil
+	if event == nil {
+		return nil, nil
+	}
+
+	result := []EventResponse{*event.ToEventResponse()}
+	if withLinks {
+		err = s.attachLinks(ctx, result, visibility)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &result[0], nil
+}
+
+// attachLinks sets the links from and to every event, see EventResponse.Links
+func (s *EventService) attachLinks(ctx context.Context, events []EventResponse, visibility Visibility) error {
+	ids := make([]int64, 0, len(events))
+	for _, event := range events {
+		// virtual occurrences have no links
+		if !event.Virtual {
+			ids = append(ids, event.ID)
+		}
+	}
+
+	links, err := s.linkRepo.ListLinks(ctx, ids, false, visibility)
+	if err != nil {
+		return fmt.Errorf("EventService.attachLinks: %v", err)
+	}
+
+	for i := range events {
+		for _, link := range links {
+			if link.SourceID == events[i].ID || link.TargetID == events[i].ID {
+				events[i].Links = append(events[i].Links, *link.ToEventLinkResponse())
+			}
+		}
+	}
+
+	return nil
 }
 
-func (s *EventService) CreateEvent(request *CreateEventRequest) (*EventResponse, error) {
+func (s *EventService) CreateEvent(ctx context.Context, request *CreateEventRequest) (*EventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
 	}
 
-	event, err := s.repo.CreateEvent(request.ToEvent())
+	var event *Event
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		event, err = s.createEvent(ctx, tx, request)
+		return err
+	})
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
@@ -51,14 +127,206 @@ func (s *EventService) CreateEvent(request *CreateEventRequest) (*EventResponse,
 	return event.ToEventResponse(), nil
 }
 
-func (s *EventService) UpdateEvent(request *UpdateEventRequest) (*EventResponse, error) {
+// CreateEvents creates the events in one transaction and reports the result of every item, see RunBatch
+func (s *EventService) CreateEvents(ctx context.Context, requests []CreateEventRequest, atomic bool) (*BatchResponse[EventResponse], error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return RunBatch(ctx, s.txManager, requests, atomic, func(request *CreateEventRequest) error {
+		err := request.Validate()
+		if err != nil {
+			return err
+		}
+
+		rules.Apply(&request.EventRequest, nil)
+		return nil
+	}, func(tx pgx.Tx, requests []*CreateEventRequest) ([]*EventResponse, error) {
+		events, err := s.createEvents(ctx, tx, rules, requests)
+		if err != nil {
+			return nil, err
+		}
+
+		result := make([]*EventResponse, len(events))
+		for i, event := range events {
+			if event != nil {
+				result[i] = event.ToEventResponse()
+			}
+		}
+
+		return result, nil
+	})
+}
+
+func (s *EventService) createEvent(ctx context.Context, tx pgx.Tx, request *CreateEventRequest) (*Event, error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules.Apply(&request.EventRequest, nil)
+
+	events, err := s.createEvents(ctx, tx, rules, []*CreateEventRequest{request})
+	if err != nil {
+		return nil, err
+	}
+
+	return events[0], nil
+}
+
+// createEvents inserts the events of the requests, the rules were already applied, records their creation
+// and creates the events derived by the rules
+func (s *EventService) createEvents(ctx context.Context, tx pgx.Tx, rules RuleSet, requests []*CreateEventRequest) ([]*Event, error) {
+	events := make([]*Event, len(requests))
+	for i, request := range requests {
+		events[i] = request.ToEvent()
+	}
+
+	events, err := s.repo.WithTx(tx).CreateEvents(ctx, events)
+	if err != nil {
+		return nil, err
+	}
+
+	created := make([]*EventSnapshot, 0, len(events))
+	for i, event := range events {
+		if event == nil {
+			// retried submission, return the original event
+			events[i], err = s.getEventByClientID(ctx, tx, &requests[i].EventRequest)
+			if err != nil {
+				return nil, &BatchItemError{Index: i, Err: err}
+			}
+			continue
+		}
+
+		created = append(created, &EventSnapshot{EventResponse: *event.ToEventResponse()})
+	}
+
+	err = s.journal.RecordCreated(ctx, tx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.rules.Derive(ctx, tx, rules, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// getEventByClientID returns the event created before with the client ID of the request,
+// the client ID must not be used by an event of another kind, e.g. one of a module
+func (s *EventService) getEventByClientID(ctx context.Context, tx pgx.Tx, request *EventRequest) (*Event, error) {
+	event, err := s.repo.WithTx(tx).GetEventByClientID(ctx, request.ProviderID, *request.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("EventService.getEventByClientID: %v", err)
+	}
+
+	if event == nil || event.Reference != request.Reference {
+		return nil, fmt.Errorf("EventService.getEventByClientID: client id %s is used by another event", *request.ClientID)
+	}
+
+	return event, nil
+}
+
+func (s *EventService) UpdateEvent(ctx context.Context, request *UpdateEventRequest) (*EventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		fmt.Println(err)
 		return nil, err
 	}
 
-	event, err := s.repo.UpdateEvent(request.ToEvent())
+	var event *Event
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, request.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.rules.Apply(ctx, tx, &request.EventRequest, nil)
+		if err != nil {
+			return err
+		}
+
+		event, err = s.repo.WithTx(tx).UpdateEvent(ctx, request.ToEvent())
+		if err != nil {
+			return err
+		}
+
+		return s.journal.Record(ctx, tx, RevisionActionUpdate, event.ID, previous)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event.ToEventResponse(), nil
+}
+
+func (s *EventService) DeleteEvent(ctx context.Context, id int64) error {
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = s.repo.WithTx(tx).DeleteEvent(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return s.journal.Record(ctx, tx, RevisionActionDelete, id, previous)
+	})
+}
+
+// StartEvent opens an interval. Running intervals sharing an exclusive tag with it are stopped at its start.
+func (s *EventService) StartEvent(ctx context.Context, request *StartEventRequest) (*EventResponse, error) {
+	create := request.ToCreateEventRequest()
+	err := create.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var event *Event
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		running, err := s.repo.WithTx(tx).ListRunningExclusive(ctx, create.Tags)
+		if err != nil {
+			return fmt.Errorf("EventService.StartEvent: %v", err)
+		}
+
+		for _, id := range running {
+			err = s.stopEvent(ctx, tx, id, *create.Timestamp)
+			if err != nil {
+				return err
+			}
+		}
+
+		event, err = s.createEvent(ctx, tx, create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return event.ToEventResponse(), nil
+}
+
+func (s *EventService) StopEvent(ctx context.Context, id int64, request *StopEventRequest) (*EventResponse, error) {
+	until := time.Now()
+	if request.Until != nil {
+		until = *request.Until
+	}
+
+	var event *Event
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		err := s.stopEvent(ctx, tx, id, until)
+		if err != nil {
+			return err
+		}
+
+		event, err = s.repo.WithTx(tx).GetEvent(ctx, id, FullVisibility)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +334,175 @@ func (s *EventService) UpdateEvent(request *UpdateEventRequest) (*EventResponse,
 	return event.ToEventResponse(), nil
 }
 
-func (s *EventService) DeleteEvent(id int64) error {
-	return s.repo.DeleteEvent(id)
+func (s *EventService) stopEvent(ctx context.Context, tx pgx.Tx, id int64, until time.Time) error {
+	previous, err := s.journal.Snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.WithTx(tx).StopEvent(ctx, id, until)
+	if err != nil {
+		return fmt.Errorf("EventService.StopEvent: event %d, %v", id, err)
+	}
+
+	return s.journal.Record(ctx, tx, RevisionActionUpdate, id, previous)
+}
+
+// expandSeries merges the virtual occurrences of the series into the page of events.
+// Occurrences which were materialized are skipped, the event takes their place.
+func (s *EventService) expandSeries(ctx context.Context, query *EventQueryBuilder, events []Event) ([]Event, error) {
+	if query.From.IsZero() || query.To.IsZero() {
+		return nil, errors.New("EventService.ListEvents: expand requires from and to")
+	}
+
+	// virtual occurrences are never searched, trashed, running or module events
+	if len(query.Search) > 0 || query.Trash || query.Running || len(query.IDs) > 0 || len(query.Reference) > 0 {
+		return events, nil
+	}
+
+	series, err := s.seriesRepo.ListMatchingSeries(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("EventService.ListEvents: failed to list series, %v", err)
+	}
+
+	virtual := make([]Event, 0)
+	for _, item := range series {
+		if len(query.Type) > 0 && query.Type != item.Type() {
+			continue
+		}
+
+		occurrences, err := item.Occurrences(query.From, query.To)
+		if err != nil {
+			return nil, fmt.Errorf("EventService.ListEvents: series %d, %v", item.ID, err)
+		}
+
+		for _, occurrence := range occurrences {
+			event := item.ToEventRequest(occurrence.UTC()).ToEvent()
+			// the negated series ID keeps the cursor unique, a series has one occurrence per instant
+			event.ID = -item.ID
+			event.Virtual = true
+
+			if query.Cursor == nil || compareEvents(event, query.Cursor.Timestamp, query.Cursor.ID, query.Order) > 0 {
+				virtual = append(virtual, *event)
+			}
+		}
+	}
+
+	if len(virtual) == 0 {
+		return events, nil
+	}
+
+	ids := make([]int64, 0, len(series))
+	from, to := *virtual[0].Occurrence, *virtual[0].Occurrence
+	for _, event := range virtual {
+		ids = append(ids, *event.SeriesID)
+		from, to = minTime(from, *event.Occurrence), maxTime(to, *event.Occurrence)
+	}
+
+	materialized, err := s.seriesRepo.ListMaterialized(ctx, ids, from, to.Add(time.Nanosecond))
+	if err != nil {
+		return nil, fmt.Errorf("EventService.ListEvents: failed to list materialized occurrences, %v", err)
+	}
+
+	for _, event := range virtual {
+		if !materialized[SeriesOccurrence{SeriesID: *event.SeriesID, Occurrence: event.Occurrence.UTC()}] {
+			events = append(events, event)
+		}
+	}
+
+	slices.SortStableFunc(events, func(a, b Event) int {
+		return compareEvents(&a, sortTime(&b), b.ID, query.Order)
+	})
+
+	// keep the extra row of EventQueryBuilder.BuildOrder
+	if query.Limit > 0 && len(events) > query.Limit+1 {
+		events = events[:query.Limit+1]
+	}
+
+	return events, nil
+}
+
+// compareEvents compares the event with the position in the sort order of the events, see EventQueryBuilder.BuildOrder
+func compareEvents(event *Event, timestamp time.Time, id int64, order SortOrder) int {
+	result := sortTime(event).Compare(timestamp)
+	if result == 0 {
+		result = cmp.Compare(event.ID, id)
+	}
+
+	if order == SortOrderDesc {
+		return -result
+	}
+
+	return result
+}
+
+// sortTime returns the time the event is sorted by, see eventSortKey
+func sortTime(event *Event) time.Time {
+	if event.Timestamp != nil {
+		return *event.Timestamp
+	}
+
+	if event.Until != nil {
+		return *event.Until
+	}
+
+	return time.Time{}
+}
+
+// MaterializeOccurrence creates the event of an occurrence of the series, or returns it when it already exists.
+// Returns nil when the series does not exist.
+func (s *EventService) MaterializeOccurrence(ctx context.Context, seriesID int64, request *OccurrenceRequest) (*EventResponse, error) {
+	var event *Event
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		series, err := s.seriesRepo.WithTx(tx).GetSeries(ctx, seriesID)
+		if err != nil {
+			return fmt.Errorf("EventService.MaterializeOccurrence: %v", err)
+		}
+
+		if series == nil {
+			return nil
+		}
+
+		ok, err := series.HasOccurrence(request.Occurrence)
+		if err != nil {
+			return fmt.Errorf("EventService.MaterializeOccurrence: %v", err)
+		}
+
+		if !ok {
+			return fmt.Errorf("EventService.MaterializeOccurrence: %s is not an occurrence of series %d", request.Occurrence.Format(time.RFC3339), seriesID)
+		}
+
+		id, err := s.seriesRepo.WithTx(tx).GetOccurrenceEventID(ctx, seriesID, request.Occurrence)
+		if err != nil {
+			return fmt.Errorf("EventService.MaterializeOccurrence: %v", err)
+		}
+
+		if id != 0 {
+			event, err = s.repo.WithTx(tx).GetEvent(ctx, id, FullVisibility)
+			return err
+		}
+
+		create := &CreateEventRequest{EventRequest: *series.ToEventRequest(request.Occurrence.UTC())}
+		create.Tags = append(slices.Clone(series.Tags), request.Tags...)
+		if request.Note != nil {
+			create.Note = *request.Note
+		}
+
+		err = create.Validate()
+		if err != nil {
+			return err
+		}
+
+		event, err = s.createEvent(ctx, tx, create)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	return event.ToEventResponse(), nil
 }
@@ -0,0 +1,172 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const streamHeartbeat = 30 * time.Second
+
+type EventStreamHandler struct {
+	handler.BaseHandler
+
+	service *EventStreamService
+}
+
+func NewEventStreamHandler(service *EventStreamService) *EventStreamHandler {
+	return &EventStreamHandler{service: service}
+}
+
+func (h *EventStreamHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/events/stream", h.StreamEvents, handler.RouteOwnerRole),
+	}
+}
+
+// StreamEvents pushes created, updated and deleted events as Server-Sent Events.
+// The same query parameters as in GET /api/core/events filter the stream, except the search.
+// The event IDs are positions in commit order, a reconnecting client receives the changes after its Last-Event-ID first. A resync event tells the client
+// that changes were lost, e.g. because it was too slow, and that it has to reload the events.
+func (h *EventStreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	query := &EventQueryBuilder{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(query.Search) > 0 {
+		h.SendJSON(w, http.StatusBadRequest, "search is not supported by the stream")
+		return
+	}
+
+	lastEventID := int64(0)
+	if value := r.Header.Get("Last-Event-ID"); len(value) > 0 {
+		lastEventID, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || lastEventID < 0 {
+			h.SendJSON(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// subscribed before the replay, so no change falls between both
+	subscription := h.service.Subscribe()
+	defer h.service.Unsubscribe(subscription)
+
+	controller := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	err = controller.Flush()
+	if err != nil {
+		fmt.Println("EventStreamHandler.StreamEvents: streaming not supported,", err)
+		return
+	}
+
+	if lastEventID > 0 {
+		changes, complete, err := h.service.Replay(r.Context(), lastEventID)
+		if err != nil {
+			fmt.Println("EventStreamHandler.StreamEvents:", err)
+			return
+		}
+
+		if !complete {
+			h.sendResync(w)
+			return
+		}
+
+		for _, change := range changes {
+			err = h.sendEvent(w, query, change)
+			if err != nil {
+				fmt.Println("EventStreamHandler.StreamEvents:", err)
+				return
+			}
+			lastEventID = change.StreamSeq
+		}
+
+		err = controller.Flush()
+		if err != nil {
+			fmt.Println("EventStreamHandler.StreamEvents:", err)
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": heartbeat\n\n")
+
+		case change, ok := <-subscription:
+			// the broker drops subscribers that fall behind
+			if !ok {
+				h.sendResync(w)
+				return
+			}
+
+			// already sent by the replay, stream positions follow the commit order like the notifications
+			if change.StreamSeq <= lastEventID {
+				continue
+			}
+
+			err = h.sendEvent(w, query, change)
+		}
+
+		if err == nil {
+			err = controller.Flush()
+		}
+		if err != nil {
+			fmt.Println("EventStreamHandler.StreamEvents:", err)
+			return
+		}
+	}
+}
+
+func (h *EventStreamHandler) sendEvent(w http.ResponseWriter, query *EventQueryBuilder, change *EventChange) error {
+	snapshot := h.service.Match(change, query)
+	if snapshot == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.StreamSeq, streamEventName(change.Action), data)
+	return err
+}
+
+// sendResync asks the client to reload the events, it ends the stream
+func (h *EventStreamHandler) sendResync(w http.ResponseWriter) {
+	_, err := fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+	if err == nil {
+		err = http.NewResponseController(w).Flush()
+	}
+
+	if err != nil {
+		fmt.Println("EventStreamHandler.sendResync:", err)
+	}
+}
+
+func streamEventName(action RevisionAction) string {
+	switch action {
+	case RevisionActionCreate:
+		return "created"
+	case RevisionActionDelete:
+		return "deleted"
+	default:
+		return "updated"
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// changes replayed at most when a stream resumes, clients further behind have to resynchronize
+const streamReplayLimit = 1000
+
+type EventStreamService struct {
+	revisionRepo *RevisionRepository
+	tagRepo      *TagRepository
+	broker       *EventBroker
+}
+
+func NewEventStreamService(revisionRepo *RevisionRepository, tagRepo *TagRepository, broker *EventBroker) *EventStreamService {
+	return &EventStreamService{
+		revisionRepo: revisionRepo,
+		tagRepo:      tagRepo,
+		broker:       broker,
+	}
+}
+
+func (s *EventStreamService) Subscribe() chan *EventChange {
+	return s.broker.Subscribe()
+}
+
+func (s *EventStreamService) Unsubscribe(ch chan *EventChange) {
+	s.broker.Unsubscribe(ch)
+}
+
+// Replay returns the changes committed after the stream position, e.g. the Last-Event-ID of a reconnecting stream.
+// It returns false when more than streamReplayLimit changes happened since.
+func (s *EventStreamService) Replay(ctx context.Context, after int64) ([]*EventChange, bool, error) {
+	revisions, err := s.revisionRepo.ListRevisionsAfter(ctx, after, streamReplayLimit+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("EventStreamService.Replay: %v", err)
+	}
+
+	if len(revisions) > streamReplayLimit {
+		return nil, false, nil
+	}
+
+	tags, err := s.tagRepo.ListTags(ctx, FullVisibility)
+	if err != nil {
+		return nil, false, fmt.Errorf("EventStreamService.Replay: %v", err)
+	}
+	index := NewTagIndex(tags)
+
+	changes := make([]*EventChange, len(revisions))
+	for i := range revisions {
+		changes[i], err = NewEventChange(&revisions[i], index)
+		if err != nil {
+			return nil, false, fmt.Errorf("EventStreamService.Replay: %v", err)
+		}
+	}
+
+	return changes, true, nil
+}
+
+// Match returns the event of the change when it passes the filters and the visibility of the query, otherwise nil.
+// Purged events are never matched, they were reported when moved to the trash.
+func (s *EventStreamService) Match(change *EventChange, query *EventQueryBuilder) *EventSnapshot {
+	if change.Action == RevisionActionPurge {
+		return nil
+	}
+
+	filter := *query
+	if change.Action == RevisionActionDelete {
+		filter.Visibility.Trashed = true
+	}
+
+	if !filter.Matches(&change.Event.EventResponse, change.Tags) {
+		return nil
+	}
+
+	return change.Event
+}
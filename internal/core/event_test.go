@@ -0,0 +1,43 @@
+package core
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEventQueryBuilderExpandRequiresRange(t *testing.T) {
+	for target, valid := range map[string]bool{
+		"/api/core/events?expand=true":                               false,
+		"/api/core/events?expand=true&from=2024-01-01":               false,
+		"/api/core/events?expand=true&from=2024-01-01&to=2024-02-01": true,
+		"/api/core/events?expand=false":                              true,
+	} {
+		query := &EventQueryBuilder{}
+		err := query.FromRequest(httptest.NewRequest("GET", target, nil))
+		if (err == nil) != valid {
+			t.Errorf("%s: error %v", target, err)
+		}
+	}
+}
+
+func TestEventQueryBuilderLimit(t *testing.T) {
+	cursor := NewEventCursor(&EventResponse{ID: 1}).Encode()
+
+	for target, limit := range map[string]int{
+		"/api/core/events":                          DefaultEventLimit,
+		"/api/core/events?limit=10":                 10,
+		"/api/core/events?limit=100000":             MaxEventLimit,
+		"/api/core/events?cursor=" + cursor:         DefaultEventLimit,
+		"/api/core/events?limit=5&cursor=" + cursor: 5,
+	} {
+		query := &EventQueryBuilder{}
+		err := query.FromRequest(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatalf("%s: %v", target, err)
+		}
+
+		if query.Limit != limit {
+			t.Errorf("%s: limit %d, expected %d", target, query.Limit, limit)
+		}
+	}
+}
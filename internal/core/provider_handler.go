@@ -33,7 +33,7 @@ func (h *ProviderHandler) GetRoutes() []handler.Route {
 }
 
 func (h *ProviderHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
-	providers, err := h.service.ListProviders()
+	providers, err := h.service.ListProviders(r.Context())
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -53,7 +53,7 @@ func (h *ProviderHandler) CreateProvider(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	provider, err := h.service.CreateProvider(body.Name, body.Description)
+	provider, err := h.service.CreateProvider(r.Context(), body.Name, body.Description)
 	if err != nil {
 		// TODO: differentiate between validation errors and general server errors
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
@@ -70,7 +70,7 @@ func (h *ProviderHandler) GetProvider(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	provider, err := h.service.GetProvider(providerId)
+	provider, err := h.service.GetProvider(r.Context(), providerId)
 	if err != nil {
 		h.SendJSON(w, http.StatusBadRequest, err.Error())
 		return
@@ -101,7 +101,7 @@ func (h *ProviderHandler) UpdateProvider(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	provider, err := h.service.UpdateProvider(providerId, body.Name, body.Description)
+	provider, err := h.service.UpdateProvider(r.Context(), providerId, body.Name, body.Description)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -117,7 +117,7 @@ func (h *ProviderHandler) DeleteProvider(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.service.DeleteProvider(providerId)
+	err = h.service.DeleteProvider(r.Context(), providerId)
 	if err != nil {
 		h.SendJSON(w, http.StatusBadRequest, err.Error())
 		return
@@ -151,7 +151,7 @@ func (h *ProviderHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
 
 	lifespan := time.Hour * 24 * time.Duration(body.Lifespan)
 
-	token, err := h.service.CreateToken(claims.UserID, providerId, lifespan)
+	token, err := h.service.CreateToken(r.Context(), claims.UserID, providerId, lifespan)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -167,7 +167,7 @@ func (h *ProviderHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.RevokeToken(providerId)
+	err = h.service.RevokeToken(r.Context(), providerId)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -18,9 +18,9 @@ func NewProviderRepository(db *pgxpool.Pool) *ProviderRepository {
 	return &ProviderRepository{db: db}
 }
 
-func (r *ProviderRepository) GetById(id int64) (*Provider, error) {
+func (r *ProviderRepository) GetById(ctx context.Context, id int64) (*Provider, error) {
 	provider := &Provider{}
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
 			id, created, updated,  name, description, expiration
 		FROM providers
@@ -45,10 +45,10 @@ func (r *ProviderRepository) GetById(id int64) (*Provider, error) {
 	return provider, nil
 }
 
-func (r *ProviderRepository) Create(name, description string) (*Provider, error) {
+func (r *ProviderRepository) Create(ctx context.Context, name, description string) (*Provider, error) {
 	provider := &Provider{}
 
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		INSERT INTO providers (name, description)
 		VALUES ($1, $2)
 		RETURNING id, created, updated, name, description
@@ -66,10 +66,10 @@ func (r *ProviderRepository) Create(name, description string) (*Provider, error)
 	return provider, err
 }
 
-func (r *ProviderRepository) Update(data *Provider) (*Provider, error) {
+func (r *ProviderRepository) Update(ctx context.Context, data *Provider) (*Provider, error) {
 	provider := &Provider{}
 
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		UPDATE providers
 			SET name = $2, description = $3
 		WHERE id = $1
@@ -89,8 +89,8 @@ func (r *ProviderRepository) Update(data *Provider) (*Provider, error) {
 	return provider, err
 }
 
-func (r *ProviderRepository) Delete(providerId int64) error {
-	commandTag, err := r.db.Exec(context.Background(), `
+func (r *ProviderRepository) Delete(ctx context.Context, providerId int64) error {
+	commandTag, err := r.db.Exec(ctx, `
 		DELETE FROM providers
 		WHERE id = $1
 	`, providerId)
@@ -105,8 +105,8 @@ func (r *ProviderRepository) Delete(providerId int64) error {
 	return nil
 }
 
-func (r *ProviderRepository) List() ([]Provider, error) {
-	rows, err := r.db.Query(context.Background(), `
+func (r *ProviderRepository) List(ctx context.Context) ([]Provider, error) {
+	rows, err := r.db.Query(ctx, `
 		SELECT
 			id, created, updated, name, description, expiration
 		FROM providers
@@ -134,11 +134,15 @@ func (r *ProviderRepository) List() ([]Provider, error) {
 		providers = append(providers, provider)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return providers, nil
 }
 
-func (r *ProviderRepository) UpdateToken(providerId int64, jti string, expiration time.Time) error {
-	commandTag, err := r.db.Exec(context.Background(), `
+func (r *ProviderRepository) UpdateToken(ctx context.Context, providerId int64, jti string, expiration time.Time) error {
+	commandTag, err := r.db.Exec(ctx, `
 		UPDATE providers
 		SET jti = $2, expiration = $3
 		WHERE id = $1
@@ -152,8 +156,8 @@ func (r *ProviderRepository) UpdateToken(providerId int64, jti string, expiratio
 	return nil
 }
 
-func (r *ProviderRepository) RevokeToken(providerId int64) error {
-	commandTag, err := r.db.Exec(context.Background(), `
+func (r *ProviderRepository) RevokeToken(ctx context.Context, providerId int64) error {
+	commandTag, err := r.db.Exec(ctx, `
 		UPDATE providers
 		SET jti = NULL, expiration = NULL
 		WHERE id = $1
@@ -2,6 +2,7 @@ package core
 
 import (
 	"backend/pkg/jwt"
+	"context"
 	"errors"
 	"time"
 
@@ -20,39 +21,39 @@ func NewProviderService(providerRepo *ProviderRepository, authService *AuthServi
 	}
 }
 
-func (s *ProviderService) ListProviders() ([]Provider, error) {
-	return s.providerRepo.List()
+func (s *ProviderService) ListProviders(ctx context.Context) ([]Provider, error) {
+	return s.providerRepo.List(ctx)
 }
 
-func (s *ProviderService) GetProvider(id int64) (*Provider, error) {
-	return s.providerRepo.GetById(id)
+func (s *ProviderService) GetProvider(ctx context.Context, id int64) (*Provider, error) {
+	return s.providerRepo.GetById(ctx, id)
 }
 
-func (s *ProviderService) CreateProvider(name string, description string) (*Provider, error) {
+func (s *ProviderService) CreateProvider(ctx context.Context, name string, description string) (*Provider, error) {
 	if len(name) == 0 {
 		return nil, errors.New("provider.name cannot be empty")
 	}
 
-	return s.providerRepo.Create(name, description)
+	return s.providerRepo.Create(ctx, name, description)
 }
 
-func (s *ProviderService) UpdateProvider(id int64, name string, description string) (*Provider, error) {
+func (s *ProviderService) UpdateProvider(ctx context.Context, id int64, name string, description string) (*Provider, error) {
 	if len(name) == 0 {
 		return nil, errors.New("provider.name cannot be empty")
 	}
 
-	return s.providerRepo.Update(&Provider{
+	return s.providerRepo.Update(ctx, &Provider{
 		ID:          id,
 		Name:        name,
 		Description: description,
 	})
 }
 
-func (s *ProviderService) DeleteProvider(providerId int64) error {
-	return s.providerRepo.Delete(providerId)
+func (s *ProviderService) DeleteProvider(ctx context.Context, providerId int64) error {
+	return s.providerRepo.Delete(ctx, providerId)
 }
 
-func (s *ProviderService) CreateToken(userId, providerId int64, lifespan time.Duration) (string, error) {
+func (s *ProviderService) CreateToken(ctx context.Context, userId, providerId int64, lifespan time.Duration) (string, error) {
 	claims := jwt.Claims{
 		UserID:     userId,
 		ProviderID: &providerId,
@@ -66,7 +67,7 @@ func (s *ProviderService) CreateToken(userId, providerId int64, lifespan time.Du
 		return "", err
 	}
 
-	err = s.providerRepo.UpdateToken(providerId, claims.JTI, claims.Expiration)
+	err = s.providerRepo.UpdateToken(ctx, providerId, claims.JTI, claims.Expiration)
 	if err != nil {
 		return "", err
 	}
@@ -74,6 +75,6 @@ func (s *ProviderService) CreateToken(userId, providerId int64, lifespan time.Du
 	return token, nil
 }
 
-func (s *ProviderService) RevokeToken(providerId int64) error {
-	return s.providerRepo.RevokeToken(providerId)
+func (s *ProviderService) RevokeToken(ctx context.Context, providerId int64) error {
+	return s.providerRepo.RevokeToken(ctx, providerId)
 }
@@ -0,0 +1,233 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// MaxReportBuckets limits the number of buckets of a single report
+const MaxReportBuckets = 1000
+
+type ReportBucket string
+
+const (
+	// a single bucket spanning the whole range
+	ReportBucketNone  ReportBucket = ""
+	ReportBucketHour  ReportBucket = "hour"
+	ReportBucketDay   ReportBucket = "day"
+	ReportBucketWeek  ReportBucket = "week"
+	ReportBucketMonth ReportBucket = "month"
+)
+
+// Start returns the beginning of the bucket containing t, weeks start on monday
+func (b ReportBucket) Start(t time.Time) time.Time {
+	year, month, day := t.Date()
+	switch b {
+	case ReportBucketHour:
+		return time.Date(year, month, day, t.Hour(), 0, 0, 0, t.Location())
+	case ReportBucketDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	case ReportBucketWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(year, month, day-offset, 0, 0, 0, 0, t.Location())
+	case ReportBucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, t.Location())
+	}
+
+	return t
+}
+
+// Next returns the beginning of the bucket following the one starting at t
+func (b ReportBucket) Next(t time.Time) time.Time {
+	switch b {
+	case ReportBucketHour:
+		return t.Add(time.Hour)
+	case ReportBucketDay:
+		return t.AddDate(0, 0, 1)
+	case ReportBucketWeek:
+		return t.AddDate(0, 0, 7)
+	case ReportBucketMonth:
+		return t.AddDate(0, 1, 0)
+	}
+
+	return t
+}
+
+// Duration returns the nominal length of the bucket, months are counted as 30 days
+func (b ReportBucket) Duration() time.Duration {
+	switch b {
+	case ReportBucketHour:
+		return time.Hour
+	case ReportBucketDay:
+		return 24 * time.Hour
+	case ReportBucketWeek:
+		return 7 * 24 * time.Hour
+	case ReportBucketMonth:
+		return 30 * 24 * time.Hour
+	}
+
+	return 0
+}
+
+// ReportRange is a bucket of a report clipped to the requested range, Label is the unclipped start of the bucket
+type ReportRange struct {
+	Label time.Time
+	Start time.Time
+	Stop  time.Time
+}
+
+// ReportRanges splits the range into buckets aligned to the timezone of from, the first and last bucket are clipped to the range
+func ReportRanges(from, to time.Time, bucket ReportBucket) ([]ReportRange, error) {
+	if bucket == ReportBucketNone {
+		return []ReportRange{{Label: from, Start: from, Stop: to}}, nil
+	}
+
+	ranges := make([]ReportRange, 0)
+	for label := bucket.Start(from); label.Before(to); label = bucket.Next(label) {
+		if len(ranges) == MaxReportBuckets {
+			return nil, errors.New("ReportRanges: too many buckets, use a larger bucket or a shorter range")
+		}
+
+		ranges = append(ranges, ReportRange{
+			Label: label,
+			Start: maxTime(label, from),
+			Stop:  minTime(bucket.Next(label), to),
+		})
+	}
+
+	return ranges, nil
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// TimeReportQuery aggregates the duration of the intervals matched by Events.
+// Intervals are clipped to the range, running intervals count until now.
+type TimeReportQuery struct {
+	Events EventQueryBuilder
+	Bucket ReportBucket
+	// the duration of a tag counts for all its parents as well, once per event
+	Subtree bool
+}
+
+func (q *TimeReportQuery) FromRequest(r *http.Request) error {
+	err := q.Events.FromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if q.Events.From.IsZero() || q.Events.To.IsZero() {
+		return errors.New("TimeReportQuery.FromRequest: from and to are required")
+	}
+
+	if !q.Events.From.Before(q.Events.To) {
+		return errors.New("TimeReportQuery.FromRequest: from must be before to")
+	}
+
+	// the report is not paginated
+	q.Events.Limit = 0
+	q.Events.Cursor = nil
+	q.Events.Type = EventTypeInterval
+
+	q.Bucket = ReportBucket(r.URL.Query().Get("bucket"))
+	switch q.Bucket {
+	case ReportBucketNone, ReportBucketDay, ReportBucketWeek, ReportBucketMonth:
+	default:
+		return errors.New("TimeReportQuery.FromRequest: invalid bucket " + string(q.Bucket))
+	}
+
+	q.Subtree = r.URL.Query().Has("subtree")
+
+	return nil
+}
+
+type TimeReportEntry struct {
+	Bucket time.Time `json:"bucket"`
+	Tag    string    `json:"tag"`
+	// duration in seconds
+	Duration int64 `json:"duration"`
+}
+
+type TimeReport struct {
+	From   time.Time         `json:"from"`
+	To     time.Time         `json:"to"`
+	Bucket ReportBucket      `json:"bucket,omitempty"`
+	Items  []TimeReportEntry `json:"items"`
+	// duration in seconds of every tag over the whole range
+	Totals map[string]int64 `json:"totals"`
+}
+
+// MaxHistogramBuckets limits the number of buckets of a single histogram
+const MaxHistogramBuckets = 10000
+
+// HistogramQuery counts the events matched by Events per bucket and type.
+// Events are counted in the bucket of their timestamp, intervals without start in the one of their end.
+type HistogramQuery struct {
+	Events EventQueryBuilder
+	Bucket ReportBucket
+	// IANA name of the timezone the buckets are aligned to, see EventQueryBuilder.Location
+	Timezone string
+}
+
+func (q *HistogramQuery) FromRequest(r *http.Request) error {
+	err := q.Events.FromRequest(r)
+	if err != nil {
+		return err
+	}
+
+	if q.Events.From.IsZero() || q.Events.To.IsZero() {
+		return errors.New("HistogramQuery.FromRequest: from and to are required")
+	}
+
+	if !q.Events.From.Before(q.Events.To) {
+		return errors.New("HistogramQuery.FromRequest: from must be before to")
+	}
+
+	// the histogram is not paginated
+	q.Events.Limit = 0
+	q.Events.Cursor = nil
+
+	q.Bucket = ReportBucket(r.URL.Query().Get("bucket"))
+	switch q.Bucket {
+	case ReportBucketHour, ReportBucketDay, ReportBucketWeek:
+	default:
+		return errors.New("HistogramQuery.FromRequest: invalid bucket " + string(q.Bucket))
+	}
+
+	if q.Events.To.Sub(q.Events.From)/q.Bucket.Duration() > MaxHistogramBuckets {
+		return errors.New("HistogramQuery.FromRequest: too many buckets, use a larger bucket or a shorter range")
+	}
+
+	q.Timezone = q.Events.GetLocation().String()
+
+	return nil
+}
+
+type HistogramEntry struct {
+	Bucket time.Time `json:"bucket"`
+	Type   EventType `json:"type"`
+	Count  int64     `json:"count"`
+}
+
+// Histogram lists only the buckets containing events
+type Histogram struct {
+	From     time.Time        `json:"from"`
+	To       time.Time        `json:"to"`
+	Bucket   ReportBucket     `json:"bucket"`
+	Timezone string           `json:"tz"`
+	Items    []HistogramEntry `json:"items"`
+	// number of events per type over the whole range
+	Totals map[EventType]int64 `json:"totals"`
+}
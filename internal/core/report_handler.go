@@ -0,0 +1,87 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReportHandler struct {
+	handler.BaseHandler
+
+	service *ReportService
+}
+
+func NewReportHandler(service *ReportService) *ReportHandler {
+	return &ReportHandler{service: service}
+}
+
+func (h *ReportHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/reports/time", h.GetTimeReport, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/stats/histogram", h.GetHistogram, handler.RouteOwnerRole),
+	}
+}
+
+// GetTimeReport accepts the filters of the event list, requires from and to.
+// The report is sent as CSV with ?format=csv or an Accept header asking for text/csv.
+func (h *ReportHandler) GetTimeReport(w http.ResponseWriter, r *http.Request) {
+	query := &TimeReportQuery{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.TimeReport(r.Context(), query)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		h.sendCSV(w, data)
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+// GetHistogram accepts the filters of the event list, requires from, to and the bucket
+func (h *ReportHandler) GetHistogram(w http.ResponseWriter, r *http.Request) {
+	query := &HistogramQuery{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.Histogram(r.Context(), query)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *ReportHandler) sendCSV(w http.ResponseWriter, report *TimeReport) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="time-report.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"bucket", "tag", "seconds", "hours"})
+	for _, entry := range report.Items {
+		writer.Write([]string{
+			entry.Bucket.Format(time.RFC3339),
+			entry.Tag,
+			strconv.FormatInt(entry.Duration, 10),
+			strconv.FormatFloat(float64(entry.Duration)/3600, 'f', 2, 64),
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReportRepository struct {
+	db db.DBTX
+}
+
+func NewReportRepository(db *pgxpool.Pool) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *ReportRepository) WithTx(tx pgx.Tx) *ReportRepository {
+	return &ReportRepository{db: tx}
+}
+
+// TimeByTag sums the overlap of the intervals with every range per tag, intervals without tags or start are not counted
+func (r *ReportRepository) TimeByTag(ctx context.Context, query *TimeReportQuery, ranges []ReportRange) ([]TimeReportEntry, error) {
+	where, params := query.Events.Build()
+
+	// every tag, or every tag and its ancestors following tags.parent, once per event
+	tag := "event_tag"
+	expand := ""
+	if query.Subtree {
+		tag = "ancestors.tag"
+		expand = `, LATERAL (
+			WITH RECURSIVE ancestors AS (
+				SELECT event_tag AS tag
+				UNION
+				SELECT tags.parent FROM tags INNER JOIN ancestors ON tags.tag = ancestors.tag
+				WHERE tags.parent IS NOT NULL
+			)
+			SELECT tag FROM ancestors
+		) AS ancestors`
+	}
+
+	labels := make([]time.Time, len(ranges))
+	starts := make([]time.Time, len(ranges))
+	stops := make([]time.Time, len(ranges))
+	for i, bucket := range ranges {
+		labels[i], starts[i], stops[i] = bucket.Label, bucket.Start, bucket.Stop
+	}
+	params = append(params, labels, starts, stops)
+
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		WITH intervals AS (
+			SELECT events.id, events.tags, events.timestamp AS start, COALESCE(events.until, now()) AS stop
+			FROM events
+			%s AND events.timestamp IS NOT NULL
+		), tagged AS (
+			SELECT DISTINCT intervals.id, intervals.start, intervals.stop, %s AS tag
+			FROM intervals, UNNEST(intervals.tags) AS event_tag%s
+		)
+		SELECT buckets.label, tagged.tag, SUM(EXTRACT(EPOCH FROM LEAST(tagged.stop, buckets.stop) - GREATEST(tagged.start, buckets.start)))::BIGINT
+		FROM tagged
+		INNER JOIN UNNEST($%d::TIMESTAMPTZ[], $%d::TIMESTAMPTZ[], $%d::TIMESTAMPTZ[]) AS buckets(label, start, stop)
+			ON tagged.start < buckets.stop AND tagged.stop > buckets.start
+		GROUP BY buckets.label, tagged.tag
+		ORDER BY buckets.label, tagged.tag
+	`, where, tag, expand, len(params)-2, len(params)-1, len(params)), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]TimeReportEntry, 0)
+	for rows.Next() {
+		entry := TimeReportEntry{}
+		err = rows.Scan(&entry.Bucket, &entry.Tag, &entry.Duration)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// CountByBucket counts the events per bucket and type, the buckets are aligned to the timezone of the query
+func (r *ReportRepository) CountByBucket(ctx context.Context, query *HistogramQuery) ([]HistogramEntry, error) {
+	// events are counted where they are sorted, so the overlap condition of the builder is replaced
+	events := query.Events
+	events.From, events.To = time.Time{}, time.Time{}
+	where, params := events.Build()
+
+	params = append(params, query.Events.From, query.Events.To)
+	condition := fmt.Sprintf("%s >= $%d AND %s < $%d", eventSortKey, len(params)-1, eventSortKey, len(params))
+	if len(where) > 0 {
+		where += " AND " + condition
+	} else {
+		where = "WHERE " + condition
+	}
+
+	params = append(params, query.Bucket, query.Timezone)
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT date_trunc($%[2]d::TEXT, %[3]s, $%[4]d::TEXT) AS bucket, events.type, COUNT(*)
+		FROM events
+		%[1]s
+		GROUP BY bucket, events.type
+		ORDER BY bucket, events.type
+	`, where, len(params)-1, eventSortKey, len(params)), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]HistogramEntry, 0)
+	for rows.Next() {
+		entry := HistogramEntry{}
+		err = rows.Scan(&entry.Bucket, &entry.Type, &entry.Count)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
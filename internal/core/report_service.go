@@ -0,0 +1,66 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+type ReportService struct {
+	reportRepo *ReportRepository
+}
+
+func NewReportService(reportRepo *ReportRepository) *ReportService {
+	return &ReportService{reportRepo: reportRepo}
+}
+
+// TimeReport returns the time spent per tag and bucket, together with the totals per tag
+func (s *ReportService) TimeReport(ctx context.Context, query *TimeReportQuery) (*TimeReport, error) {
+	location := query.Events.GetLocation()
+	ranges, err := ReportRanges(query.Events.From.In(location), query.Events.To.In(location), query.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.reportRepo.TimeByTag(ctx, query, ranges)
+	if err != nil {
+		return nil, fmt.Errorf("ReportService.TimeReport: %v", err)
+	}
+
+	report := &TimeReport{
+		From:   query.Events.From,
+		To:     query.Events.To,
+		Bucket: query.Bucket,
+		Items:  entries,
+		Totals: make(map[string]int64),
+	}
+	for i, entry := range entries {
+		entries[i].Bucket = entry.Bucket.In(location)
+		report.Totals[entry.Tag] += entry.Duration
+	}
+
+	return report, nil
+}
+
+// Histogram returns the number of events per bucket and type, together with the totals per type
+func (s *ReportService) Histogram(ctx context.Context, query *HistogramQuery) (*Histogram, error) {
+	location := query.Events.GetLocation()
+	entries, err := s.reportRepo.CountByBucket(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("ReportService.Histogram: %v", err)
+	}
+
+	histogram := &Histogram{
+		From:     query.Events.From,
+		To:       query.Events.To,
+		Bucket:   query.Bucket,
+		Timezone: query.Timezone,
+		Items:    entries,
+		Totals:   make(map[EventType]int64),
+	}
+	for i, entry := range entries {
+		entries[i].Bucket = entry.Bucket.In(location)
+		histogram.Totals[entry.Type] += entry.Count
+	}
+
+	return histogram, nil
+}
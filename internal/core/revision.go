@@ -0,0 +1,64 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"backend/pkg/jwt"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type RevisionAction string
+
+const (
+	RevisionActionCreate  RevisionAction = "create"
+	RevisionActionUpdate  RevisionAction = "update"
+	RevisionActionDelete  RevisionAction = "delete"
+	RevisionActionRestore RevisionAction = "restore"
+	RevisionActionPurge   RevisionAction = "purge"
+)
+
+// EventSnapshot is the full state of an event including the module extras
+type EventSnapshot struct {
+	EventResponse
+	Extras json.RawMessage `json:"extras,omitempty"`
+}
+
+type Revision struct {
+	ID         int64           `json:"id"`
+	Created    time.Time       `json:"created"`
+	EventID    int64           `json:"eventId"`
+	Action     RevisionAction  `json:"action"`
+	Previous   json.RawMessage `json:"previous"`
+	Current    json.RawMessage `json:"current"`
+	UserID     *int64          `json:"userId,omitempty"`
+	ProviderID *int64          `json:"providerId,omitempty"`
+	// position in commit order, the ID of the change in the event stream
+	StreamSeq int64 `json:"-"`
+}
+
+// Snapshot returns the state stored by the revision, for deletes it is the state before the delete
+func (r *Revision) Snapshot() (*EventSnapshot, error) {
+	data := r.Current
+	if len(data) == 0 || string(data) == "null" {
+		data = r.Previous
+	}
+
+	snapshot := &EventSnapshot{}
+	err := json.Unmarshal(data, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// actorFromContext returns the user and provider of the authenticated request
+func actorFromContext(ctx context.Context) (*int64, *int64) {
+	claims, ok := ctx.Value(handler.RequestClaims).(jwt.Claims)
+	if !ok {
+		return nil, nil
+	}
+
+	return &claims.UserID, claims.ProviderID
+}
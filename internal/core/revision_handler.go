@@ -0,0 +1,66 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+)
+
+type RevisionHandler struct {
+	handler.BaseHandler
+
+	service *RevisionService
+}
+
+func NewRevisionHandler(service *RevisionService) *RevisionHandler {
+	return &RevisionHandler{service: service}
+}
+
+func (h *RevisionHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/events/{id}/history", h.ListRevisions, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/events/{id}/history/{revision}/restore", h.RestoreRevision, handler.RouteOwnerRole),
+	}
+}
+
+func (h *RevisionHandler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListRevisions(r.Context(), eventId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *RevisionHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revisionId, err := h.GetInt64FromPath(r, "revision")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.RestoreRevision(r.Context(), eventId, revisionId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// tags of the state stored by a revision, used for the visibility check
+const revisionTags = "ARRAY(SELECT jsonb_array_elements_text(COALESCE(event_revisions.current, event_revisions.previous)->'tags'))"
+
+type RevisionRepository struct {
+	db db.DBTX
+}
+
+func NewRevisionRepository(db *pgxpool.Pool) *RevisionRepository {
+	return &RevisionRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *RevisionRepository) WithTx(tx pgx.Tx) *RevisionRepository {
+	return &RevisionRepository{db: tx}
+}
+
+func (r *RevisionRepository) ListRevisions(ctx context.Context, eventID int64, visibility Visibility) ([]Revision, error) {
+	where := "WHERE event_id = $1"
+	if condition := visibility.TagsCondition(revisionTags); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created, event_id, action, previous, current, user_id, provider_id, stream_seq
+		FROM event_revisions
+		`+where+`
+		ORDER BY id ASC
+	`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]Revision, 0)
+	for rows.Next() {
+		revision := Revision{}
+		err = rows.Scan(
+			&revision.ID, &revision.Created, &revision.EventID, &revision.Action,
+			&revision.Previous, &revision.Current, &revision.UserID, &revision.ProviderID, &revision.StreamSeq,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (r *RevisionRepository) GetRevision(ctx context.Context, eventID, revisionID int64, visibility Visibility) (*Revision, error) {
+	where := "WHERE event_id = $1 AND id = $2"
+	if condition := visibility.TagsCondition(revisionTags); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	revision := Revision{}
+	err := r.db.QueryRow(ctx, `
+		SELECT id, created, event_id, action, previous, current, user_id, provider_id, stream_seq
+		FROM event_revisions
+		`+where, eventID, revisionID).Scan(
+		&revision.ID, &revision.Created, &revision.EventID, &revision.Action,
+		&revision.Previous, &revision.Current, &revision.UserID, &revision.ProviderID, &revision.StreamSeq,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// ListRevisionsAfter returns the revisions of all events committed after the stream position in commit order, at most limit
+func (r *RevisionRepository) ListRevisionsAfter(ctx context.Context, after int64, limit int) ([]Revision, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created, event_id, action, previous, current, user_id, provider_id, stream_seq
+		FROM event_revisions
+		WHERE stream_seq > $1
+		ORDER BY stream_seq ASC
+		LIMIT $2
+	`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]Revision, 0)
+	for rows.Next() {
+		revision := Revision{}
+		err = rows.Scan(
+			&revision.ID, &revision.Created, &revision.EventID, &revision.Action,
+			&revision.Previous, &revision.Current, &revision.UserID, &revision.ProviderID, &revision.StreamSeq,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// QueueCreateRevision queues the insert of the revision, its ID and creation time are set once the batch is sent
+func (r *RevisionRepository) QueueCreateRevision(batch *pgx.Batch, revision *Revision) {
+	batch.Queue(`
+		INSERT INTO event_revisions (event_id, action, previous, current, user_id, provider_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created
+	`, revision.EventID, revision.Action, revision.Previous, revision.Current, revision.UserID, revision.ProviderID).QueryRow(func(row pgx.Row) error {
+		return row.Scan(&revision.ID, &revision.Created)
+	})
+}
+
+// QueueNotifyRevision queues the notification of the revision on EventChangesChannel,
+// listeners receive it when the transaction commits
+func (r *RevisionRepository) QueueNotifyRevision(batch *pgx.Batch, revision *Revision) error {
+	payload, err := json.Marshal(&EventNotification{
+		RevisionID: revision.ID,
+		EventID:    revision.EventID,
+		Action:     revision.Action,
+	})
+	if err != nil {
+		return err
+	}
+
+	batch.Queue("SELECT pg_notify($1, $2)", EventChangesChannel, string(payload))
+	return nil
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type RevisionService struct {
+	txManager    *db.TxManager
+	revisionRepo *RevisionRepository
+	journal      *EventJournal
+}
+
+func NewRevisionService(txManager *db.TxManager, revisionRepo *RevisionRepository, journal *EventJournal) *RevisionService {
+	return &RevisionService{
+		txManager:    txManager,
+		revisionRepo: revisionRepo,
+		journal:      journal,
+	}
+}
+
+func (s *RevisionService) ListRevisions(ctx context.Context, eventID int64, visibility Visibility) ([]Revision, error) {
+	return s.revisionRepo.ListRevisions(ctx, eventID, visibility)
+}
+
+// RestoreRevision brings the event back to the state stored by the revision, see Revision.Snapshot
+func (s *RevisionService) RestoreRevision(ctx context.Context, eventID, revisionID int64, visibility Visibility) (*EventSnapshot, error) {
+	var result *EventSnapshot
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		revision, err := s.revisionRepo.WithTx(tx).GetRevision(ctx, eventID, revisionID, visibility)
+		if err != nil {
+			return fmt.Errorf("RevisionService.RestoreRevision: %v", err)
+		}
+
+		if revision == nil {
+			return nil
+		}
+
+		snapshot, err := revision.Snapshot()
+		if err != nil {
+			return fmt.Errorf("RevisionService.RestoreRevision: invalid revision, %v", err)
+		}
+
+		previous, err := s.journal.Snapshot(ctx, tx, eventID)
+		if err != nil {
+			return err
+		}
+
+		err = s.journal.Restore(ctx, tx, snapshot)
+		if err != nil {
+			return err
+		}
+
+		err = s.journal.Record(ctx, tx, RevisionActionRestore, eventID, previous)
+		if err != nil {
+			return err
+		}
+
+		result, err = s.journal.Snapshot(ctx, tx, eventID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
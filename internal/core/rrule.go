@@ -0,0 +1,359 @@
+package core
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRecurrencePeriods stops the expansion of rules whose parts never match, e.g. FEBRUARY 30th
+const maxRecurrencePeriods = 100000
+
+type RecurrenceFrequency string
+
+const (
+	RecurrenceDaily   RecurrenceFrequency = "DAILY"
+	RecurrenceWeekly  RecurrenceFrequency = "WEEKLY"
+	RecurrenceMonthly RecurrenceFrequency = "MONTHLY"
+	RecurrenceYearly  RecurrenceFrequency = "YEARLY"
+)
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// RecurrenceDay is a BYDAY entry, Ordinal selects the nth weekday of the month (negative from the end), 0 every one
+type RecurrenceDay struct {
+	Weekday time.Weekday
+	Ordinal int
+}
+
+// RecurrenceRule is the subset of the RFC 5545 RRULE supported by series:
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY and BYMONTH.
+// Weeks start on monday.
+type RecurrenceRule struct {
+	Frequency RecurrenceFrequency
+	Interval  int
+	Count     int
+	Until     *time.Time
+	// UNTIL was given as date or without timezone, its wall clock time is in the location of the start
+	UntilLocal bool
+	ByDay      []RecurrenceDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+// ParseRecurrenceRule parses a rule like "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", the "RRULE:" prefix is optional
+func ParseRecurrenceRule(value string) (*RecurrenceRule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := &RecurrenceRule{Interval: 1}
+
+	for _, part := range strings.Split(value, ";") {
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, errors.New("ParseRecurrenceRule: invalid part " + part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Frequency = RecurrenceFrequency(strings.ToUpper(value))
+			switch rule.Frequency {
+			case RecurrenceDaily, RecurrenceWeekly, RecurrenceMonthly, RecurrenceYearly:
+			default:
+				return nil, errors.New("ParseRecurrenceRule: unsupported FREQ " + value)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(value)
+			if err != nil || rule.Interval <= 0 {
+				return nil, errors.New("ParseRecurrenceRule: invalid INTERVAL")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(value)
+			if err != nil || rule.Count <= 0 {
+				return nil, errors.New("ParseRecurrenceRule: invalid COUNT")
+			}
+		case "UNTIL":
+			until, local, err := parseRecurrenceUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+			rule.UntilLocal = local
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(value), ",") {
+				if len(day) < 2 {
+					return nil, errors.New("ParseRecurrenceRule: invalid BYDAY " + day)
+				}
+
+				weekday, ok := recurrenceWeekdays[day[len(day)-2:]]
+				if !ok {
+					return nil, errors.New("ParseRecurrenceRule: invalid BYDAY " + day)
+				}
+
+				ordinal := 0
+				if len(day) > 2 {
+					ordinal, err = strconv.Atoi(day[:len(day)-2])
+					if err != nil || ordinal == 0 || ordinal < -5 || ordinal > 5 {
+						return nil, errors.New("ParseRecurrenceRule: invalid BYDAY " + day)
+					}
+				}
+
+				rule.ByDay = append(rule.ByDay, RecurrenceDay{Weekday: weekday, Ordinal: ordinal})
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay == 0 || monthDay < -31 || monthDay > 31 {
+					return nil, errors.New("ParseRecurrenceRule: invalid BYMONTHDAY " + day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, monthDay)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				number, err := strconv.Atoi(month)
+				if err != nil || number < 1 || number > 12 {
+					return nil, errors.New("ParseRecurrenceRule: invalid BYMONTH " + month)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(number))
+			}
+		default:
+			return nil, errors.New("ParseRecurrenceRule: unsupported part " + name)
+		}
+	}
+
+	if len(rule.Frequency) == 0 {
+		return nil, errors.New("ParseRecurrenceRule: missing FREQ")
+	}
+
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, errors.New("ParseRecurrenceRule: COUNT and UNTIL are exclusive")
+	}
+
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Frequency != RecurrenceMonthly && rule.Frequency != RecurrenceYearly {
+			return nil, errors.New("ParseRecurrenceRule: BYDAY with ordinal requires a MONTHLY or YEARLY frequency")
+		}
+	}
+
+	if rule.Frequency == RecurrenceYearly && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return nil, errors.New("ParseRecurrenceRule: BYDAY in a YEARLY rule requires BYMONTH")
+	}
+
+	return rule, nil
+}
+
+// parseRecurrenceUntil returns the time of UNTIL and whether it is a wall clock time of the start's location
+func parseRecurrenceUntil(value string) (time.Time, bool, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		until, err := time.Parse(layout, value)
+		if err == nil {
+			if layout == "20060102" {
+				// a date includes the whole day
+				until = until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return until, layout != "20060102T150405Z", nil
+		}
+	}
+
+	return time.Time{}, false, errors.New("ParseRecurrenceRule: invalid UNTIL " + value)
+}
+
+// Between returns the occurrences of the rule starting at start which begin in [from, to).
+// The occurrences keep the wall clock time of start in its location, also across daylight saving changes.
+// Without COUNT the expansion starts at the period containing from, with COUNT every occurrence since start counts.
+func (r *RecurrenceRule) Between(start, from, to time.Time) []time.Time {
+	result := make([]time.Time, 0)
+	count := 0
+	until := r.until(start)
+	first := r.firstPeriod(start, from)
+
+	for period := first; period < first+maxRecurrencePeriods; period++ {
+		for _, occurrence := range r.candidates(start, period) {
+			if occurrence.Before(start) {
+				continue
+			}
+
+			if !occurrence.Before(to) || (until != nil && occurrence.After(*until)) {
+				return result
+			}
+
+			count++
+			if !occurrence.Before(from) {
+				result = append(result, occurrence)
+			}
+
+			if r.Count > 0 && count == r.Count {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+// until returns the end of the rule, nil when it has none
+func (r *RecurrenceRule) until(start time.Time) *time.Time {
+	if r.Until == nil || !r.UntilLocal {
+		return r.Until
+	}
+
+	until := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), r.Until.Hour(), r.Until.Minute(), r.Until.Second(), 0, start.Location())
+	return &until
+}
+
+// firstPeriod returns the period of the rule containing from, earlier periods only have occurrences before from.
+// With COUNT the expansion has to count the occurrences of all periods, so it starts at the first one.
+func (r *RecurrenceRule) firstPeriod(start, from time.Time) int {
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+
+	from = from.In(start.Location())
+	startYear, startMonth, startDay := start.Date()
+	fromYear, fromMonth, fromDay := from.Date()
+
+	// calendar days, independent of daylight saving changes
+	days := int(time.Date(fromYear, fromMonth, fromDay, 0, 0, 0, 0, time.UTC).Sub(time.Date(startYear, startMonth, startDay, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+
+	elapsed := 0
+	switch r.Frequency {
+	case RecurrenceDaily:
+		elapsed = days
+	case RecurrenceWeekly:
+		// periods start on the monday of the week of start
+		elapsed = (days + (int(start.Weekday())+6)%7) / 7
+	case RecurrenceMonthly:
+		elapsed = (fromYear-startYear)*12 + int(fromMonth-startMonth)
+	case RecurrenceYearly:
+		elapsed = fromYear - startYear
+	}
+
+	return elapsed / r.Interval
+}
+
+// Contains reports whether the rule starting at start has an occurrence at the time
+func (r *RecurrenceRule) Contains(start, occurrence time.Time) bool {
+	occurrences := r.Between(start, occurrence, occurrence.Add(time.Second))
+	return len(occurrences) > 0 && occurrences[0].Equal(occurrence)
+}
+
+// candidates returns the sorted occurrences of the nth period after start, before COUNT and UNTIL are applied
+func (r *RecurrenceRule) candidates(start time.Time, period int) []time.Time {
+	year, month, day := start.Date()
+	step := period * r.Interval
+	days := make([]time.Time, 0)
+
+	switch r.Frequency {
+	case RecurrenceDaily:
+		date := time.Date(year, month, day+step, 0, 0, 0, 0, start.Location())
+		if r.matchesDay(date) {
+			days = append(days, date)
+		}
+	case RecurrenceWeekly:
+		monday := day - (int(start.Weekday())+6)%7
+		for offset := 0; offset < 7; offset++ {
+			date := time.Date(year, month, monday+step*7+offset, 0, 0, 0, 0, start.Location())
+			weekdays := r.ByDay
+			if len(weekdays) == 0 {
+				weekdays = []RecurrenceDay{{Weekday: start.Weekday()}}
+			}
+
+			if slices.ContainsFunc(weekdays, func(d RecurrenceDay) bool { return d.Weekday == date.Weekday() }) && r.matchesMonth(date) {
+				days = append(days, date)
+			}
+		}
+	case RecurrenceMonthly:
+		first := time.Date(year, month+time.Month(step), 1, 0, 0, 0, 0, start.Location())
+		if r.matchesMonth(first) {
+			days = r.daysOfMonth(first, day)
+		}
+	case RecurrenceYearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{month}
+		}
+
+		for _, m := range months {
+			first := time.Date(year+step, m, 1, 0, 0, 0, 0, start.Location())
+			days = append(days, r.daysOfMonth(first, day)...)
+		}
+	}
+
+	result := make([]time.Time, len(days))
+	for i, date := range days {
+		result[i] = time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+	slices.SortFunc(result, func(a, b time.Time) int { return a.Compare(b) })
+
+	return result
+}
+
+// daysOfMonth returns the days of the month starting at first matching BYMONTHDAY and BYDAY, or the day of the start
+func (r *RecurrenceRule) daysOfMonth(first time.Time, startDay int) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	days := make([]time.Time, 0)
+
+	for day := 1; day <= length; day++ {
+		date := first.AddDate(0, 0, day-1)
+
+		if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 && day != startDay {
+			continue
+		}
+
+		if len(r.ByMonthDay) > 0 && !slices.ContainsFunc(r.ByMonthDay, func(d int) bool {
+			return d == day || (d < 0 && length+d+1 == day)
+		}) {
+			continue
+		}
+
+		if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(d RecurrenceDay) bool {
+			if d.Weekday != date.Weekday() {
+				return false
+			}
+
+			nth := (day-1)/7 + 1
+			nthLast := -((length-day)/7 + 1)
+			return d.Ordinal == 0 || d.Ordinal == nth || d.Ordinal == nthLast
+		}) {
+			continue
+		}
+
+		days = append(days, date)
+	}
+
+	return days
+}
+
+func (r *RecurrenceRule) matchesDay(date time.Time) bool {
+	if !r.matchesMonth(date) {
+		return false
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		length := time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, date.Location()).Day()
+		if !slices.ContainsFunc(r.ByMonthDay, func(d int) bool {
+			return d == date.Day() || (d < 0 && length+d+1 == date.Day())
+		}) {
+			return false
+		}
+	}
+
+	if len(r.ByDay) > 0 && !slices.ContainsFunc(r.ByDay, func(d RecurrenceDay) bool { return d.Weekday == date.Weekday() }) {
+		return false
+	}
+
+	return true
+}
+
+func (r *RecurrenceRule) matchesMonth(date time.Time) bool {
+	return len(r.ByMonth) == 0 || slices.Contains(r.ByMonth, date.Month())
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceRuleBetween(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	date := func(location *time.Location, value string) time.Time {
+		result, err := time.ParseInLocation("2006-01-02 15:04", value, location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		from     time.Time
+		to       time.Time
+		expected []time.Time
+	}{
+		{
+			name:  "second tuesday",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU",
+			start: date(time.UTC, "2024-01-01 10:00"),
+			from:  date(time.UTC, "2024-01-01 00:00"),
+			to:    date(time.UTC, "2024-04-01 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-01-09 10:00"),
+				date(time.UTC, "2024-02-13 10:00"),
+				date(time.UTC, "2024-03-12 10:00"),
+			},
+		},
+		{
+			name:  "last friday",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR",
+			start: date(time.UTC, "2024-01-01 10:00"),
+			from:  date(time.UTC, "2024-01-01 00:00"),
+			to:    date(time.UTC, "2024-04-01 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-01-26 10:00"),
+				date(time.UTC, "2024-02-23 10:00"),
+				date(time.UTC, "2024-03-29 10:00"),
+			},
+		},
+		{
+			name:  "last day of the month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: date(time.UTC, "2024-01-01 10:00"),
+			from:  date(time.UTC, "2024-01-01 00:00"),
+			to:    date(time.UTC, "2024-04-01 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-01-31 10:00"),
+				date(time.UTC, "2024-02-29 10:00"),
+				date(time.UTC, "2024-03-31 10:00"),
+			},
+		},
+		{
+			name:  "until date in the timezone of the start",
+			rule:  "FREQ=DAILY;UNTIL=20240103",
+			start: date(newYork, "2024-01-01 22:00"),
+			from:  date(newYork, "2024-01-01 00:00"),
+			to:    date(newYork, "2024-01-10 00:00"),
+			expected: []time.Time{
+				date(newYork, "2024-01-01 22:00"),
+				date(newYork, "2024-01-02 22:00"),
+				date(newYork, "2024-01-03 22:00"),
+			},
+		},
+		{
+			name:  "until in UTC",
+			rule:  "FREQ=DAILY;UNTIL=20240103T100000Z",
+			start: date(newYork, "2024-01-01 06:00"),
+			from:  date(newYork, "2024-01-01 00:00"),
+			to:    date(newYork, "2024-01-10 00:00"),
+			expected: []time.Time{
+				date(newYork, "2024-01-01 06:00"),
+				date(newYork, "2024-01-02 06:00"),
+			},
+		},
+		{
+			name:  "daylight saving time starts",
+			rule:  "FREQ=DAILY",
+			start: date(berlin, "2024-03-30 09:00"),
+			from:  date(berlin, "2024-03-30 00:00"),
+			to:    date(berlin, "2024-04-01 00:00"),
+			expected: []time.Time{
+				date(berlin, "2024-03-30 09:00"),
+				date(berlin, "2024-03-31 09:00"),
+			},
+		},
+		{
+			name:  "daylight saving time ends",
+			rule:  "FREQ=WEEKLY;BYDAY=SU",
+			start: date(berlin, "2024-10-20 02:30"),
+			from:  date(berlin, "2024-10-20 00:00"),
+			to:    date(berlin, "2024-11-01 00:00"),
+			expected: []time.Time{
+				date(berlin, "2024-10-20 02:30"),
+				date(berlin, "2024-10-27 02:30"),
+			},
+		},
+		{
+			name:  "range years after the start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			start: date(time.UTC, "2020-01-01 08:00"),
+			from:  date(time.UTC, "2024-01-01 00:00"),
+			to:    date(time.UTC, "2024-01-08 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-01-01 08:00"),
+				date(time.UTC, "2024-01-03 08:00"),
+			},
+		},
+		{
+			name:  "interval keeps its phase",
+			rule:  "FREQ=WEEKLY;INTERVAL=2",
+			start: date(time.UTC, "2024-01-01 08:00"),
+			from:  date(time.UTC, "2024-03-01 00:00"),
+			to:    date(time.UTC, "2024-04-01 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-03-11 08:00"),
+				date(time.UTC, "2024-03-25 08:00"),
+			},
+		},
+		{
+			name:  "range after more periods than expanded at once",
+			rule:  "FREQ=DAILY",
+			start: date(time.UTC, "1900-01-01 12:00"),
+			from:  date(time.UTC, "2200-01-01 00:00"),
+			to:    date(time.UTC, "2200-01-03 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2200-01-01 12:00"),
+				date(time.UTC, "2200-01-02 12:00"),
+			},
+		},
+		{
+			name:  "count includes occurrences before the range",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: date(time.UTC, "2024-01-01 12:00"),
+			from:  date(time.UTC, "2024-01-02 00:00"),
+			to:    date(time.UTC, "2024-02-01 00:00"),
+			expected: []time.Time{
+				date(time.UTC, "2024-01-02 12:00"),
+				date(time.UTC, "2024-01-03 12:00"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := ParseRecurrenceRule(test.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			occurrences := rule.Between(test.start, test.from, test.to)
+			if len(occurrences) != len(test.expected) {
+				t.Fatalf("occurrences %v, expected %v", occurrences, test.expected)
+			}
+
+			for i, occurrence := range occurrences {
+				if !occurrence.Equal(test.expected[i]) {
+					t.Fatalf("occurrences %v, expected %v", occurrences, test.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRecurrenceRuleInvalid(t *testing.T) {
+	for _, value := range []string{
+		"BYDAY=MO",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240101",
+		"FREQ=WEEKLY;BYDAY=2MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=YEARLY;BYDAY=MO",
+	} {
+		if _, err := ParseRecurrenceRule(value); err == nil {
+			t.Errorf("%s was accepted", value)
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type RuleField string
+
+const (
+	RuleFieldNote      RuleField = "note"
+	RuleFieldType      RuleField = "type"
+	RuleFieldTags      RuleField = "tags"
+	RuleFieldReference RuleField = "reference"
+	// module data of the event, see EventExtras, addressed by RuleCondition.Path
+	RuleFieldExtras RuleField = "extras"
+)
+
+type RuleOperator string
+
+const (
+	RuleOperatorEquals   RuleOperator = "equals"
+	RuleOperatorContains RuleOperator = "contains"
+	RuleOperatorMatches  RuleOperator = "matches"
+	RuleOperatorExists   RuleOperator = "exists"
+)
+
+type RuleCondition struct {
+	Field RuleField `json:"field"`
+	// JSON path into the extras, e.g. "$.source" or "$.items[0].name"
+	Path     string       `json:"path,omitempty"`
+	Operator RuleOperator `json:"operator"`
+	Value    any          `json:"value,omitempty"`
+}
+
+// RuleDerivation creates a core event for every event matched by the rule, e.g. a "workout" moment
+// for heart rate data. The derived event takes the type and time of the matched event and is linked
+// to it as caused by it.
+type RuleDerivation struct {
+	Tags []string `json:"tags"`
+	Note string   `json:"note,omitempty"`
+}
+
+type Rule struct {
+	ID   int64
+	Name string
+	// events of the module only, nil matches every event and empty string core events
+	Reference *string
+	// all conditions have to match
+	Conditions []RuleCondition
+	Tags       []string
+	Derive     *RuleDerivation
+	Active     bool
+	Created    time.Time
+	Updated    time.Time
+}
+
+func (r *Rule) ToRuleResponse() *RuleResponse {
+	return &RuleResponse{
+		ID:         r.ID,
+		Name:       r.Name,
+		Reference:  r.Reference,
+		Conditions: r.Conditions,
+		Tags:       r.Tags,
+		Derive:     r.Derive,
+		Active:     r.Active,
+		Created:    r.Created,
+		Updated:    r.Updated,
+	}
+}
+
+type RuleRequest struct {
+	Name       string          `json:"name"`
+	Reference  *string         `json:"reference,omitempty"`
+	Conditions []RuleCondition `json:"conditions"`
+	Tags       []string        `json:"tags"`
+	Derive     *RuleDerivation `json:"derive,omitempty"`
+	Active     bool            `json:"active"`
+}
+
+func (r *RuleRequest) Validate() error {
+	if len(strings.TrimSpace(r.Name)) == 0 {
+		return errors.New("RuleRequest.Validate: missing name")
+	}
+
+	if len(r.Tags) == 0 && r.Derive == nil {
+		return errors.New("RuleRequest.Validate: missing tags or derive")
+	}
+
+	if r.Derive != nil && len(r.Derive.Tags) == 0 {
+		return errors.New("RuleRequest.Validate: missing tags of the derived event")
+	}
+
+	for _, condition := range r.Conditions {
+		err := condition.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RuleRequest) ToRule() *Rule {
+	rule := &Rule{
+		Name:       r.Name,
+		Reference:  r.Reference,
+		Conditions: r.Conditions,
+		Tags:       r.Tags,
+		Derive:     r.Derive,
+		Active:     r.Active,
+	}
+
+	if rule.Conditions == nil {
+		rule.Conditions = []RuleCondition{}
+	}
+
+	if rule.Tags == nil {
+		rule.Tags = []string{}
+	}
+
+	return rule
+}
+
+type RuleResponse struct {
+	ID         int64           `json:"id"`
+	Name       string          `json:"name"`
+	Reference  *string         `json:"reference,omitempty"`
+	Conditions []RuleCondition `json:"conditions"`
+	Tags       []string        `json:"tags"`
+	Derive     *RuleDerivation `json:"derive,omitempty"`
+	Active     bool            `json:"active"`
+	Created    time.Time       `json:"created"`
+	Updated    time.Time       `json:"updated"`
+}
+
+type RuleApplyChange struct {
+	EventID int64    `json:"eventId"`
+	Tags    []string `json:"tags"`
+	// rules deriving an event which does not exist yet
+	DerivedBy []int64 `json:"derivedBy,omitempty"`
+}
+
+type RuleApplyResponse struct {
+	DryRun bool `json:"dryRun"`
+	// number of checked events
+	Checked int `json:"checked"`
+	// number of derived events, created or to be created with dryRun
+	Derived int               `json:"derived"`
+	Changes []RuleApplyChange `json:"changes"`
+}
+
+// RuleInput is the event as seen by the rules
+type RuleInput struct {
+	Type      EventType
+	Tags      []string
+	Note      string
+	Reference string
+	Extras    json.RawMessage
+}
+
+func (c *RuleCondition) Validate() error {
+	switch c.Field {
+	case RuleFieldNote, RuleFieldType, RuleFieldTags, RuleFieldReference:
+	case RuleFieldExtras:
+		_, err := parseJSONPath(c.Path)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("RuleCondition.Validate: invalid field " + string(c.Field))
+	}
+
+	switch c.Operator {
+	case RuleOperatorExists:
+		return nil
+	case RuleOperatorEquals:
+	case RuleOperatorContains:
+		if _, ok := c.Value.(string); !ok {
+			return errors.New("RuleCondition.Validate: contains requires a string value")
+		}
+	case RuleOperatorMatches:
+		pattern, ok := c.Value.(string)
+		if !ok {
+			return errors.New("RuleCondition.Validate: matches requires a string value")
+		}
+		_, err := compileRulePattern(pattern)
+		if err != nil {
+			return fmt.Errorf("RuleCondition.Validate: invalid pattern, %v", err)
+		}
+	default:
+		return errors.New("RuleCondition.Validate: invalid operator " + string(c.Operator))
+	}
+
+	if c.Value == nil {
+		return errors.New("RuleCondition.Validate: missing value")
+	}
+
+	return nil
+}
+
+// Matches reports whether the rule applies to the event
+func (r *Rule) Matches(input *RuleInput) bool {
+	if r.Reference != nil && *r.Reference != input.Reference {
+		return false
+	}
+
+	for _, condition := range r.Conditions {
+		if !condition.Matches(input) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *RuleCondition) Matches(input *RuleInput) bool {
+	switch c.Field {
+	case RuleFieldNote:
+		return c.matchValue(input.Note, len(input.Note) > 0)
+	case RuleFieldType:
+		return c.matchValue(string(input.Type), len(input.Type) > 0)
+	case RuleFieldReference:
+		return c.matchValue(input.Reference, len(input.Reference) > 0)
+	case RuleFieldTags:
+		if c.Operator == RuleOperatorExists {
+			return len(input.Tags) > 0
+		}
+		// any of the tags
+		for _, tag := range input.Tags {
+			if c.matchValue(tag, true) {
+				return true
+			}
+		}
+		return false
+	case RuleFieldExtras:
+		value, ok := extractJSONPath(input.Extras, c.Path)
+		return c.matchValue(value, ok)
+	}
+
+	return false
+}
+
+func (c *RuleCondition) matchValue(value any, exists bool) bool {
+	if c.Operator == RuleOperatorExists {
+		return exists
+	}
+
+	if !exists {
+		return false
+	}
+
+	switch c.Operator {
+	case RuleOperatorEquals:
+		return reflect.DeepEqual(normalizeJSONValue(value), normalizeJSONValue(c.Value))
+	case RuleOperatorContains:
+		text, ok := value.(string)
+		return ok && strings.Contains(strings.ToLower(text), strings.ToLower(c.Value.(string)))
+	case RuleOperatorMatches:
+		text, ok := value.(string)
+		if !ok {
+			return false
+		}
+		pattern, err := compileRulePattern(c.Value.(string))
+		return err == nil && pattern.MatchString(text)
+	}
+
+	return false
+}
+
+// EvaluateRules returns the tags added by the matching rules which the event does not have yet
+func EvaluateRules(rules []Rule, input *RuleInput) []string {
+	added := make([]string, 0)
+	for i := range rules {
+		if !rules[i].Active || !rules[i].Matches(input) {
+			continue
+		}
+
+		for _, tag := range rules[i].Tags {
+			if !slices.Contains(input.Tags, tag) && !slices.Contains(added, tag) {
+				added = append(added, tag)
+			}
+		}
+	}
+
+	return added
+}
+
+// derivedClientIDPrefix marks the client IDs of derived events, each rule derives one event per matched event
+const derivedClientIDPrefix = "rule:"
+
+func derivedClientID(ruleID, eventID int64) string {
+	return fmt.Sprintf("%s%d:%d", derivedClientIDPrefix, ruleID, eventID)
+}
+
+// IsDerivedEvent reports whether the event was created by a rule, those are not evaluated again
+func IsDerivedEvent(event *EventResponse) bool {
+	return event.ProviderID == nil && event.ClientID != nil && strings.HasPrefix(*event.ClientID, derivedClientIDPrefix)
+}
+
+// DerivedEvent is the event a rule derives from a matched event
+type DerivedEvent struct {
+	RuleID   int64
+	SourceID int64
+	Event    *Event
+}
+
+// DeriveEvents returns the events derived by the matching rules from the event, nothing for derived events
+func DeriveEvents(rules []Rule, event *EventSnapshot) []DerivedEvent {
+	derived := make([]DerivedEvent, 0)
+	if IsDerivedEvent(&event.EventResponse) {
+		return derived
+	}
+
+	input := &RuleInput{
+		Type:      event.Type,
+		Tags:      event.Tags,
+		Note:      event.Note,
+		Reference: event.Reference,
+		Extras:    event.Extras,
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Derive == nil || !rule.Active || !rule.Matches(input) {
+			continue
+		}
+
+		clientID := derivedClientID(rule.ID, event.ID)
+		derived = append(derived, DerivedEvent{
+			RuleID:   rule.ID,
+			SourceID: event.ID,
+			Event: &Event{
+				Type:      event.Type,
+				Timestamp: event.Timestamp,
+				Until:     event.Until,
+				Tags:      slices.Clone(rule.Derive.Tags),
+				Note:      rule.Derive.Note,
+				ClientID:  &clientID,
+				Timezone:  event.Timezone,
+			},
+		})
+	}
+
+	return derived
+}
+
+var rulePatterns sync.Map
+
+func compileRulePattern(pattern string) (*regexp.Regexp, error) {
+	if compiled, ok := rulePatterns.Load(pattern); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	rulePatterns.Store(pattern, compiled)
+
+	return compiled, nil
+}
+
+// normalizeJSONValue makes values decoded from JSON comparable with values from Go
+func normalizeJSONValue(value any) any {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var result any
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return value
+	}
+
+	return result
+}
+
+// parseJSONPath splits a path like "$.a.b[0]" into object keys and array indexes
+func parseJSONPath(path string) ([]any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.New("parseJSONPath: path has to start with $")
+	}
+
+	segments := make([]any, 0)
+	rest := path[1:]
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if len(key) == 0 {
+				return nil, errors.New("parseJSONPath: empty key in " + path)
+			}
+			segments = append(segments, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, errors.New("parseJSONPath: unclosed index in " + path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, errors.New("parseJSONPath: invalid index in " + path)
+			}
+			segments = append(segments, index)
+			rest = rest[end+1:]
+		default:
+			return nil, errors.New("parseJSONPath: invalid path " + path)
+		}
+	}
+
+	return segments, nil
+}
+
+// extractJSONPath returns the value at the path, false when the data or the path does not exist
+func extractJSONPath(data json.RawMessage, path string) (any, bool) {
+	if len(data) == 0 {
+		return nil, false
+	}
+
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var value any
+	err = json.Unmarshal(data, &value)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, segment := range segments {
+		switch key := segment.(type) {
+		case string:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			value, ok = object[key]
+			if !ok {
+				return nil, false
+			}
+		case int:
+			array, ok := value.([]any)
+			if !ok || key >= len(array) {
+				return nil, false
+			}
+			value = array[key]
+		}
+	}
+
+	return value, true
+}
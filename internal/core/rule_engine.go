@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RuleEngine adds the tags of matching rules to events created or updated through the services
+// and creates the events derived from created ones
+type RuleEngine struct {
+	repo      *RuleRepository
+	eventRepo *EventRepository
+	linkRepo  *EventLinkRepository
+	journal   *EventJournal
+}
+
+func NewRuleEngine(repo *RuleRepository, eventRepo *EventRepository, linkRepo *EventLinkRepository, journal *EventJournal) *RuleEngine {
+	return &RuleEngine{
+		repo:      repo,
+		eventRepo: eventRepo,
+		linkRepo:  linkRepo,
+		journal:   journal,
+	}
+}
+
+// Apply adds the tags of the active rules matching the request, extras is the module data or nil
+func (e *RuleEngine) Apply(ctx context.Context, tx pgx.Tx, request *EventRequest, extras json.RawMessage) error {
+	rules, err := e.repo.WithTx(tx).ListRules(ctx, true)
+	if err != nil {
+		return fmt.Errorf("RuleEngine.Apply: failed to load rules, %v", err)
+	}
+
+	RuleSet(rules).Apply(request, extras)
+	return nil
+}
+
+// Load returns the active rules, to apply them to every item of a batch without loading them again
+func (e *RuleEngine) Load(ctx context.Context) (RuleSet, error) {
+	rules, err := e.repo.ListRules(ctx, true)
+	if err != nil {
+		return nil, fmt.Errorf("RuleEngine.Load: failed to load rules, %v", err)
+	}
+
+	return rules, nil
+}
+
+// RuleSet is a list of active rules loaded by RuleEngine.Load
+type RuleSet []Rule
+
+// Apply adds the tags of the rules matching the request, extras is the module data or nil
+func (s RuleSet) Apply(request *EventRequest, extras json.RawMessage) {
+	added := EvaluateRules(s, &RuleInput{
+		Type:      request.Type,
+		Tags:      request.Tags,
+		Note:      request.Note,
+		Reference: request.Reference,
+		Extras:    extras,
+	})
+	request.Tags = append(request.Tags, added...)
+}
+
+// Derive creates the events derived by the rules from the created events, each is linked as caused by its source.
+// Derived events which already exist are skipped, it returns the number of created ones.
+func (e *RuleEngine) Derive(ctx context.Context, tx pgx.Tx, rules RuleSet, created []*EventSnapshot) (int, error) {
+	derived := make([]DerivedEvent, 0)
+	for _, snapshot := range created {
+		derived = append(derived, DeriveEvents(rules, snapshot)...)
+	}
+
+	if len(derived) == 0 {
+		return 0, nil
+	}
+
+	events := make([]*Event, len(derived))
+	for i := range derived {
+		events[i] = derived[i].Event
+	}
+
+	// not wrapped, the index of a failed derived event is no item of the caller's batch
+	events, err := e.eventRepo.WithTx(tx).CreateEvents(ctx, events)
+	if err != nil {
+		return 0, fmt.Errorf("RuleEngine.Derive: failed to create events, %v", err)
+	}
+
+	links := make([]*EventLink, 0, len(events))
+	snapshots := make([]*EventSnapshot, 0, len(events))
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+
+		links = append(links, &EventLink{SourceID: event.ID, TargetID: derived[i].SourceID, Type: EventLinkCausedBy})
+		snapshots = append(snapshots, &EventSnapshot{EventResponse: *event.ToEventResponse()})
+	}
+
+	if len(links) == 0 {
+		return 0, nil
+	}
+
+	err = e.linkRepo.WithTx(tx).CreateLinks(ctx, links)
+	if err != nil {
+		return 0, fmt.Errorf("RuleEngine.Derive: failed to link events, %v", err)
+	}
+
+	err = e.journal.RecordCreated(ctx, tx, snapshots)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(snapshots), nil
+}
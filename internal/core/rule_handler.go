@@ -0,0 +1,138 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+)
+
+type RuleHandler struct {
+	handler.BaseHandler
+
+	service *RuleService
+}
+
+func NewRuleHandler(service *RuleService) *RuleHandler {
+	return &RuleHandler{service: service}
+}
+
+func (h *RuleHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/rules/{$}", h.ListRules, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/rules/{id}", h.GetRule, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/rules", h.CreateRule, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/rules/apply", h.ApplyRules, handler.RouteOwnerRole),
+		handler.NewRoute("PUT /api/core/rules/{id}", h.UpdateRule, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/rules/{id}", h.DeleteRule, handler.RouteOwnerRole),
+	}
+}
+
+func (h *RuleHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	data, err := h.service.ListRules(r.Context())
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *RuleHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.GetRule(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "rule not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *RuleHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var data RuleRequest
+	err := h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.CreateRule(r.Context(), &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
+
+func (h *RuleHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data RuleRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.UpdateRule(r.Context(), id, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "rule not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
+
+func (h *RuleHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.service.DeleteRule(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ApplyRules applies the rules to existing events, the event filters are the same as in GET /api/core/events.
+// With ?dryRun the changes are only previewed.
+func (h *RuleHandler) ApplyRules(w http.ResponseWriter, r *http.Request) {
+	query := &EventQueryBuilder{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ApplyRules(r.Context(), query, r.URL.Query().Has("dryRun"))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
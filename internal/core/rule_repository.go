@@ -0,0 +1,132 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RuleRepository struct {
+	db db.DBTX
+}
+
+func NewRuleRepository(db *pgxpool.Pool) *RuleRepository {
+	return &RuleRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *RuleRepository) WithTx(tx pgx.Tx) *RuleRepository {
+	return &RuleRepository{db: tx}
+}
+
+const ruleColumns = "id, name, reference, conditions, tags, derive, active, created, updated"
+
+func scanRule(row pgx.Row) (*Rule, error) {
+	rule := Rule{}
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Reference, &rule.Conditions, &rule.Tags, &rule.Derive, &rule.Active, &rule.Created, &rule.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
+
+// ListRules returns the rules in the order they are evaluated, only active ones when activeOnly is set
+func (r *RuleRepository) ListRules(ctx context.Context, activeOnly bool) ([]Rule, error) {
+	where := ""
+	if activeOnly {
+		where = "WHERE active"
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+ruleColumns+`
+		FROM rules
+		`+where+`
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *RuleRepository) GetRule(ctx context.Context, id int64) (*Rule, error) {
+	rule, err := scanRule(r.db.QueryRow(ctx, `
+		SELECT `+ruleColumns+`
+		FROM rules
+		WHERE id = $1
+	`, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (r *RuleRepository) CreateRule(ctx context.Context, rule *Rule) (*Rule, error) {
+	return scanRule(r.db.QueryRow(ctx, `
+		INSERT INTO rules (name, reference, conditions, tags, derive, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+ruleColumns,
+		rule.Name, rule.Reference, rule.Conditions, rule.Tags, rule.Derive, rule.Active,
+	))
+}
+
+func (r *RuleRepository) UpdateRule(ctx context.Context, rule *Rule) (*Rule, error) {
+	result, err := scanRule(r.db.QueryRow(ctx, `
+		UPDATE rules
+		SET name = $2,
+		    reference = $3,
+		    conditions = $4,
+		    tags = $5,
+		    derive = $6,
+		    active = $7
+		WHERE id = $1
+		RETURNING `+ruleColumns,
+		rule.ID, rule.Name, rule.Reference, rule.Conditions, rule.Tags, rule.Derive, rule.Active,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+func (r *RuleRepository) DeleteRule(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM rules
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("RuleRepository.DeleteRule: no rows affected")
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// events loaded at once when rules are applied retroactively
+const ruleApplyPageSize = 500
+
+type RuleService struct {
+	txManager *db.TxManager
+	ruleRepo  *RuleRepository
+	eventRepo *EventRepository
+	journal   *EventJournal
+	rules     *RuleEngine
+}
+
+func NewRuleService(txManager *db.TxManager, ruleRepo *RuleRepository, eventRepo *EventRepository, journal *EventJournal, rules *RuleEngine) *RuleService {
+	return &RuleService{
+		txManager: txManager,
+		ruleRepo:  ruleRepo,
+		eventRepo: eventRepo,
+		journal:   journal,
+		rules:     rules,
+	}
+}
+
+func (s *RuleService) ListRules(ctx context.Context) ([]RuleResponse, error) {
+	rules, err := s.ruleRepo.ListRules(ctx, false)
+	if err != nil {
+		return nil, fmt.Errorf("RuleService.ListRules: %v", err)
+	}
+
+	result := make([]RuleResponse, len(rules))
+	for i, rule := range rules {
+		result[i] = *rule.ToRuleResponse()
+	}
+
+	return result, nil
+}
+
+func (s *RuleService) GetRule(ctx context.Context, id int64) (*RuleResponse, error) {
+	rule, err := s.ruleRepo.GetRule(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("RuleService.GetRule: %v", err)
+	}
+
+	if rule == nil {
+		return nil, nil
+	}
+
+	return rule.ToRuleResponse(), nil
+}
+
+func (s *RuleService) CreateRule(ctx context.Context, request *RuleRequest) (*RuleResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	rule, err := s.ruleRepo.CreateRule(ctx, request.ToRule())
+	if err != nil {
+		return nil, fmt.Errorf("RuleService.CreateRule: %v", err)
+	}
+
+	return rule.ToRuleResponse(), nil
+}
+
+func (s *RuleService) UpdateRule(ctx context.Context, id int64, request *RuleRequest) (*RuleResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	rule := request.ToRule()
+	rule.ID = id
+
+	rule, err = s.ruleRepo.UpdateRule(ctx, rule)
+	if err != nil {
+		return nil, fmt.Errorf("RuleService.UpdateRule: %v", err)
+	}
+
+	if rule == nil {
+		return nil, nil
+	}
+
+	return rule.ToRuleResponse(), nil
+}
+
+func (s *RuleService) DeleteRule(ctx context.Context, id int64) error {
+	return s.ruleRepo.DeleteRule(ctx, id)
+}
+
+// ApplyRules evaluates the active rules over every event matching the query, adds the missing tags and creates
+// the missing derived events. Every page of ruleApplyPageSize events is written in its own transaction, so a long
+// range neither holds its locks until the end nor is rolled back as a whole, the pages written before an error stay.
+// With dryRun nothing is written, the response previews the changes.
+func (s *RuleService) ApplyRules(ctx context.Context, query *EventQueryBuilder, dryRun bool) (*RuleApplyResponse, error) {
+	response := &RuleApplyResponse{
+		DryRun:  dryRun,
+		Changes: make([]RuleApplyChange, 0),
+	}
+
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query.Search = ""
+	query.Order = SortOrderAsc
+	query.Limit = ruleApplyPageSize
+	query.Cursor = nil
+
+	for {
+		var page *EventPage[Event]
+		err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+			events, err := s.eventRepo.WithTx(tx).ListEvents(ctx, query)
+			if err != nil {
+				return fmt.Errorf("RuleService.ApplyRules: failed to load events, %v", err)
+			}
+
+			page = NewEventPage(events, query, func(e *Event) *EventResponse { return e.ToEventResponse() })
+			return s.applyRules(ctx, tx, rules, page.Data, response)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Next) == 0 {
+			return response, nil
+		}
+
+		query.Cursor = NewEventCursor(page.Data[len(page.Data)-1].ToEventResponse())
+	}
+}
+
+// applyRules evaluates the rules over a page of events, the extras of the page are loaded at once
+func (s *RuleService) applyRules(ctx context.Context, tx pgx.Tx, rules RuleSet, events []Event, response *RuleApplyResponse) error {
+	ids := make([]int64, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+
+	snapshots, err := s.journal.Snapshots(ctx, tx, ids)
+	if err != nil {
+		return err
+	}
+
+	// derived events which exist already are not reported again
+	derived := make(map[int64][]DerivedEvent)
+	clientIDs := make([]string, 0)
+	for _, id := range ids {
+		if snapshots[id] == nil {
+			continue
+		}
+
+		derived[id] = DeriveEvents(rules, snapshots[id])
+		for _, item := range derived[id] {
+			clientIDs = append(clientIDs, *item.Event.ClientID)
+		}
+	}
+
+	existing := make(map[string]bool)
+	if len(clientIDs) > 0 {
+		existing, err = s.eventRepo.WithTx(tx).ListClientIDs(ctx, clientIDs)
+		if err != nil {
+			return fmt.Errorf("RuleService.ApplyRules: failed to load derived events, %v", err)
+		}
+	}
+
+	added := make(map[int64][]string)
+	changed := make([]int64, 0)
+	sources := make([]*EventSnapshot, 0)
+	for _, id := range ids {
+		snapshot := snapshots[id]
+		if snapshot == nil {
+			continue
+		}
+		response.Checked++
+
+		change := RuleApplyChange{
+			EventID: id,
+			Tags: EvaluateRules(rules, &RuleInput{
+				Type:      snapshot.Type,
+				Tags:      snapshot.Tags,
+				Note:      snapshot.Note,
+				Reference: snapshot.Reference,
+				Extras:    snapshot.Extras,
+			}),
+		}
+
+		for _, item := range derived[id] {
+			if !existing[*item.Event.ClientID] {
+				change.DerivedBy = append(change.DerivedBy, item.RuleID)
+			}
+		}
+
+		if len(change.Tags) == 0 && len(change.DerivedBy) == 0 {
+			continue
+		}
+		response.Changes = append(response.Changes, change)
+
+		if len(change.Tags) > 0 {
+			added[id] = change.Tags
+			changed = append(changed, id)
+		}
+
+		if len(change.DerivedBy) > 0 {
+			sources = append(sources, snapshot)
+			if response.DryRun {
+				response.Derived += len(change.DerivedBy)
+			}
+		}
+	}
+
+	if response.DryRun {
+		return nil
+	}
+
+	if len(changed) > 0 {
+		err = s.eventRepo.WithTx(tx).AddTags(ctx, added)
+		if err != nil {
+			return fmt.Errorf("RuleService.ApplyRules: failed to update events, %v", err)
+		}
+
+		err = s.journal.RecordUpdates(ctx, tx, changed, snapshots)
+		if err != nil {
+			return err
+		}
+	}
+
+	count, err := s.rules.Derive(ctx, tx, rules, sources)
+	if err != nil {
+		return err
+	}
+	response.Derived += count
+
+	return nil
+}
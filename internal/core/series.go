@@ -0,0 +1,177 @@
+package core
+
+import (
+	"errors"
+	"time"
+)
+
+// Series is a recurring event, its occurrences are virtual until they are materialized
+type Series struct {
+	ID       int64
+	RRule    string
+	Start    time.Time
+	Duration int64
+	Timezone *string
+	Tags     []string
+	Note     string
+	Created  time.Time
+	Updated  time.Time
+}
+
+func (s *Series) Type() EventType {
+	if s.Duration > 0 {
+		return EventTypeInterval
+	}
+
+	return EventTypeMoment
+}
+
+// Occurrences returns the occurrences of the series overlapping [from, to)
+func (s *Series) Occurrences(from, to time.Time) ([]time.Time, error) {
+	rule, err := ParseRecurrenceRule(s.RRule)
+	if err != nil {
+		return nil, err
+	}
+
+	start, err := s.localStart()
+	if err != nil {
+		return nil, err
+	}
+
+	// intervals starting before the range may still reach into it
+	return rule.Between(start, from.Add(-time.Duration(s.Duration)*time.Second+time.Nanosecond), to), nil
+}
+
+// HasOccurrence reports whether the series has an occurrence starting at the time
+func (s *Series) HasOccurrence(occurrence time.Time) (bool, error) {
+	rule, err := ParseRecurrenceRule(s.RRule)
+	if err != nil {
+		return false, err
+	}
+
+	start, err := s.localStart()
+	if err != nil {
+		return false, err
+	}
+
+	return rule.Contains(start, occurrence), nil
+}
+
+func (s *Series) localStart() (time.Time, error) {
+	if s.Timezone == nil {
+		return s.Start.UTC(), nil
+	}
+
+	location, err := LoadLocation(*s.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return s.Start.In(location), nil
+}
+
+// ToEventRequest returns the event of the occurrence
+func (s *Series) ToEventRequest(occurrence time.Time) *EventRequest {
+	request := &EventRequest{
+		Type:       s.Type(),
+		Timestamp:  &occurrence,
+		Tags:       s.Tags,
+		Note:       s.Note,
+		Timezone:   s.Timezone,
+		SeriesID:   &s.ID,
+		Occurrence: &occurrence,
+	}
+
+	if s.Duration > 0 {
+		until := occurrence.Add(time.Duration(s.Duration) * time.Second)
+		request.Until = &until
+	}
+
+	return request
+}
+
+func (s *Series) ToSeriesResponse() *SeriesResponse {
+	return &SeriesResponse{
+		ID:       s.ID,
+		Type:     s.Type(),
+		RRule:    s.RRule,
+		Start:    s.Start,
+		Duration: s.Duration,
+		Timezone: s.Timezone,
+		Tags:     s.Tags,
+		Note:     s.Note,
+		Created:  s.Created,
+		Updated:  s.Updated,
+	}
+}
+
+type SeriesRequest struct {
+	// RFC 5545 recurrence rule, see RecurrenceRule for the supported parts
+	RRule string    `json:"rrule"`
+	Start time.Time `json:"start"`
+	// seconds, occurrences of series with a duration are intervals
+	Duration int64    `json:"duration"`
+	Timezone *string  `json:"timezone,omitempty"`
+	Tags     []string `json:"tags"`
+	Note     string   `json:"note"`
+}
+
+func (r *SeriesRequest) Validate() error {
+	_, err := ParseRecurrenceRule(r.RRule)
+	if err != nil {
+		return err
+	}
+
+	if r.Start.IsZero() {
+		return errors.New("SeriesRequest.Validate: missing start")
+	}
+
+	if r.Duration < 0 {
+		return errors.New("SeriesRequest.Validate: invalid duration")
+	}
+
+	if r.Timezone != nil {
+		_, err := LoadLocation(*r.Timezone)
+		if err != nil || len(*r.Timezone) == 0 {
+			return errors.New("SeriesRequest.Validate: invalid timezone " + *r.Timezone)
+		}
+	}
+
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+
+	return nil
+}
+
+func (r *SeriesRequest) ToSeries() *Series {
+	return &Series{
+		RRule:    r.RRule,
+		Start:    r.Start,
+		Duration: r.Duration,
+		Timezone: r.Timezone,
+		Tags:     r.Tags,
+		Note:     r.Note,
+	}
+}
+
+type SeriesResponse struct {
+	ID       int64     `json:"id"`
+	Type     EventType `json:"type"`
+	RRule    string    `json:"rrule"`
+	Start    time.Time `json:"start"`
+	Duration int64     `json:"duration"`
+	Timezone *string   `json:"timezone,omitempty"`
+	Tags     []string  `json:"tags"`
+	Note     string    `json:"note"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+// OccurrenceRequest materializes an occurrence of a series, the note replaces the one of the series
+type OccurrenceRequest struct {
+	Occurrence time.Time `json:"occurrence"`
+	Note       *string   `json:"note,omitempty"`
+	// added to the tags of the series, e.g. "done"
+	Tags []string `json:"tags,omitempty"`
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+)
+
+type SeriesHandler struct {
+	handler.BaseHandler
+
+	service *SeriesService
+}
+
+func NewSeriesHandler(service *SeriesService) *SeriesHandler {
+	return &SeriesHandler{service: service}
+}
+
+func (h *SeriesHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/series/{$}", h.ListSeries, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/series/{id}", h.GetSeries, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/series", h.CreateSeries, handler.RouteOwnerRole),
+		handler.NewRoute("PUT /api/core/series/{id}", h.UpdateSeries, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/series/{id}", h.DeleteSeries, handler.RouteOwnerRole),
+	}
+}
+
+func (h *SeriesHandler) ListSeries(w http.ResponseWriter, r *http.Request) {
+	data, err := h.service.ListSeries(r.Context())
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *SeriesHandler) GetSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.GetSeries(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "series not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *SeriesHandler) CreateSeries(w http.ResponseWriter, r *http.Request) {
+	var data SeriesRequest
+	err := h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.CreateSeries(r.Context(), &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
+
+func (h *SeriesHandler) UpdateSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data SeriesRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.UpdateSeries(r.Context(), id, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "series not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
+
+// DeleteSeries removes the series, its materialized occurrences stay as standalone events
+func (h *SeriesHandler) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.service.DeleteSeries(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
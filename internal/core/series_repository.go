@@ -0,0 +1,212 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SeriesRepository struct {
+	db db.DBTX
+}
+
+func NewSeriesRepository(db *pgxpool.Pool) *SeriesRepository {
+	return &SeriesRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *SeriesRepository) WithTx(tx pgx.Tx) *SeriesRepository {
+	return &SeriesRepository{db: tx}
+}
+
+const seriesColumns = "id, rrule, start, duration, timezone, tags, note, created, updated"
+
+func scanSeries(row pgx.Row) (*Series, error) {
+	series := Series{}
+	err := row.Scan(&series.ID, &series.RRule, &series.Start, &series.Duration, &series.Timezone, &series.Tags, &series.Note, &series.Created, &series.Updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+}
+
+func (r *SeriesRepository) ListSeries(ctx context.Context) ([]Series, error) {
+	return r.querySeries(ctx, `
+		SELECT `+seriesColumns+`
+		FROM series
+		ORDER BY id ASC
+	`)
+}
+
+// ListMatchingSeries returns the series whose occurrences match the tag and visibility filters of the query
+func (r *SeriesRepository) ListMatchingSeries(ctx context.Context, query *EventQueryBuilder) ([]Series, error) {
+	params := make([]any, 0)
+	and := []string{"series.start < $1"}
+	params = append(params, query.To)
+
+	if condition := query.Visibility.TagsCondition("series.tags"); len(condition) > 0 {
+		and = append(and, condition)
+	}
+
+	if len(query.Tags) > 0 && query.TagsDeep {
+		for _, tag := range query.Tags {
+			params = append(params, tag)
+			and = append(and, "series.tags && "+tagSubtree(fmt.Sprintf("$%v", len(params))))
+		}
+	} else if len(query.Tags) > 0 {
+		params = append(params, query.Tags)
+		and = append(and, fmt.Sprintf("series.tags @> $%v", len(params)))
+	}
+
+	return r.querySeries(ctx, `
+		SELECT `+seriesColumns+`
+		FROM series
+		WHERE `+strings.Join(and, " AND ")+`
+		ORDER BY id ASC
+	`, params...)
+}
+
+func (r *SeriesRepository) querySeries(ctx context.Context, query string, params ...any) ([]Series, error) {
+	rows, err := r.db.Query(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]Series, 0)
+	for rows.Next() {
+		series, err := scanSeries(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *series)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *SeriesRepository) GetSeries(ctx context.Context, id int64) (*Series, error) {
+	series, err := scanSeries(r.db.QueryRow(ctx, `
+		SELECT `+seriesColumns+`
+		FROM series
+		WHERE id = $1
+	`, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+func (r *SeriesRepository) CreateSeries(ctx context.Context, series *Series) (*Series, error) {
+	return scanSeries(r.db.QueryRow(ctx, `
+		INSERT INTO series (rrule, start, duration, timezone, tags, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+seriesColumns,
+		series.RRule, series.Start, series.Duration, series.Timezone, series.Tags, series.Note,
+	))
+}
+
+func (r *SeriesRepository) UpdateSeries(ctx context.Context, series *Series) (*Series, error) {
+	result, err := scanSeries(r.db.QueryRow(ctx, `
+		UPDATE series
+		SET rrule = $2,
+		    start = $3,
+		    duration = $4,
+		    timezone = $5,
+		    tags = $6,
+		    note = $7
+		WHERE id = $1
+		RETURNING `+seriesColumns,
+		series.ID, series.RRule, series.Start, series.Duration, series.Timezone, series.Tags, series.Note,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+// DeleteSeries removes the series, the materialized occurrences are kept as standalone events
+func (r *SeriesRepository) DeleteSeries(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM series
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("SeriesRepository.DeleteSeries: no rows affected")
+	}
+
+	return nil
+}
+
+// SeriesOccurrence identifies an occurrence of a series
+type SeriesOccurrence struct {
+	SeriesID   int64
+	Occurrence time.Time
+}
+
+// ListMaterialized returns the occurrences of the series in [from, to) that have an event, trashed ones included
+func (r *SeriesRepository) ListMaterialized(ctx context.Context, seriesIDs []int64, from, to time.Time) (map[SeriesOccurrence]bool, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT series_id, occurrence
+		FROM events
+		WHERE series_id = ANY($1) AND occurrence >= $2 AND occurrence < $3
+	`, seriesIDs, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[SeriesOccurrence]bool)
+	for rows.Next() {
+		occurrence := SeriesOccurrence{}
+		err = rows.Scan(&occurrence.SeriesID, &occurrence.Occurrence)
+		if err != nil {
+			return nil, err
+		}
+
+		result[SeriesOccurrence{SeriesID: occurrence.SeriesID, Occurrence: occurrence.Occurrence.UTC()}] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetOccurrenceEventID returns the event of the materialized occurrence, 0 when there is none
+func (r *SeriesRepository) GetOccurrenceEventID(ctx context.Context, seriesID int64, occurrence time.Time) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		SELECT id
+		FROM events
+		WHERE series_id = $1 AND occurrence = $2
+	`, seriesID, occurrence).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return 0, nil
+	}
+
+	return id, err
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+type SeriesService struct {
+	seriesRepo *SeriesRepository
+}
+
+func NewSeriesService(seriesRepo *SeriesRepository) *SeriesService {
+	return &SeriesService{seriesRepo: seriesRepo}
+}
+
+func (s *SeriesService) ListSeries(ctx context.Context) ([]SeriesResponse, error) {
+	data, err := s.seriesRepo.ListSeries(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("SeriesService.ListSeries: %v", err)
+	}
+
+	result := make([]SeriesResponse, len(data))
+	for i, series := range data {
+		result[i] = *series.ToSeriesResponse()
+	}
+
+	return result, nil
+}
+
+func (s *SeriesService) GetSeries(ctx context.Context, id int64) (*SeriesResponse, error) {
+	series, err := s.seriesRepo.GetSeries(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("SeriesService.GetSeries: %v", err)
+	}
+
+	if series == nil {
+		return nil, nil
+	}
+
+	return series.ToSeriesResponse(), nil
+}
+
+func (s *SeriesService) CreateSeries(ctx context.Context, request *SeriesRequest) (*SeriesResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	series, err := s.seriesRepo.CreateSeries(ctx, request.ToSeries())
+	if err != nil {
+		return nil, fmt.Errorf("SeriesService.CreateSeries: %v", err)
+	}
+
+	return series.ToSeriesResponse(), nil
+}
+
+// UpdateSeries changes the definition, materialized occurrences keep their state
+func (s *SeriesService) UpdateSeries(ctx context.Context, id int64, request *SeriesRequest) (*SeriesResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	series := request.ToSeries()
+	series.ID = id
+
+	series, err = s.seriesRepo.UpdateSeries(ctx, series)
+	if err != nil {
+		return nil, fmt.Errorf("SeriesService.UpdateSeries: %v", err)
+	}
+
+	if series == nil {
+		return nil, nil
+	}
+
+	return series.ToSeriesResponse(), nil
+}
+
+func (s *SeriesService) DeleteSeries(ctx context.Context, id int64) error {
+	return s.seriesRepo.DeleteSeries(ctx, id)
+}
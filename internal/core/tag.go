@@ -1,16 +1,24 @@
 package core
 
+import "strings"
+
+// namespaced tags like "health:sleep:nap" are children of "health:sleep"
+const TagSeparator = ":"
+
 type Tag struct {
 	Tag         string  `json:"tag"`
 	Description *string `json:"description,omitempty"`
 	Parent      *string `json:"parent,omitempty"`
 	Private     bool    `json:"private"`
+	// starting an interval with the tag stops the running ones, see EventService.StartEvent
+	Exclusive bool `json:"exclusive"`
 }
 
 type CreateTagRequest struct {
 	Tag         string  `json:"tag"`
 	Description *string `json:"description"`
 	Private     bool    `json:"private"`
+	Exclusive   bool    `json:"exclusive"`
 }
 
 type UpdateTagRequest struct {
@@ -18,4 +26,116 @@ type UpdateTagRequest struct {
 	NewTag      *string `json:"newTag,omitempty"`
 	Description *string `json:"description"`
 	Private     bool    `json:"private"`
+	Exclusive   bool    `json:"exclusive"`
+}
+
+type MergeTagRequest struct {
+	Tag    string `json:"-"`
+	Target string `json:"target"`
+}
+
+type MergeTagResponse struct {
+	Tag    *Tag  `json:"tag"`
+	Events int64 `json:"events"`
+}
+
+type TagNode struct {
+	Tag
+	Children []*TagNode `json:"children,omitempty"`
+}
+
+// ParentTag returns the parent of a namespaced tag, or nil for top level tags
+func ParentTag(tag string) *string {
+	index := strings.LastIndex(tag, TagSeparator)
+	if index <= 0 {
+		return nil
+	}
+
+	parent := tag[:index]
+	return &parent
+}
+
+// TagAncestors returns all parents of the tag, starting with the top level one
+func TagAncestors(tag string) []string {
+	ancestors := make([]string, 0)
+	for parent := ParentTag(tag); parent != nil; parent = ParentTag(*parent) {
+		ancestors = append([]string{*parent}, ancestors...)
+	}
+
+	return ancestors
+}
+
+// BuildTagTree nests the tags under their parents, tags with a missing parent become roots
+func BuildTagTree(tags []Tag) []*TagNode {
+	nodes := make(map[string]*TagNode, len(tags))
+	for _, tag := range tags {
+		nodes[tag.Tag] = &TagNode{Tag: tag}
+	}
+
+	roots := make([]*TagNode, 0)
+	for _, tag := range tags {
+		node := nodes[tag.Tag]
+		if tag.Parent != nil {
+			parent, ok := nodes[*tag.Parent]
+			if ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	return roots
+}
+
+// TagIndex answers the tag conditions of EventQueryBuilder in memory, see EventQueryBuilder.Matches
+type TagIndex struct {
+	private  []string
+	children map[string][]string
+}
+
+func NewTagIndex(tags []Tag) *TagIndex {
+	index := &TagIndex{children: make(map[string][]string)}
+	for _, tag := range tags {
+		if tag.Private {
+			index.private = append(index.private, tag.Tag)
+		}
+		if tag.Parent != nil {
+			index.children[*tag.Parent] = append(index.children[*tag.Parent], tag.Tag)
+		}
+	}
+
+	return index
+}
+
+// Hidden reports whether any of the tags is private or namespaced below a private tag, like Visibility.TagsCondition
+func (i *TagIndex) Hidden(tags []string) bool {
+	for _, tag := range tags {
+		for _, private := range i.private {
+			if tag == private || strings.HasPrefix(tag, private+TagSeparator) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Subtree returns the tag and all its descendants following the parents of the tags
+func (i *TagIndex) Subtree(tag string) map[string]bool {
+	subtree := map[string]bool{tag: true}
+	pending := []string{tag}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		for _, child := range i.children[current] {
+			if !subtree[child] {
+				subtree[child] = true
+				pending = append(pending, child)
+			}
+		}
+	}
+
+	return subtree
 }
@@ -23,13 +23,25 @@ func (h *TagHandler) GetRoutes() []handler.Route {
 		handler.NewRoute("PUT /api/core/tags/{tag}", h.UpdateTag, handler.RouteOwnerRole),
 		handler.NewRoute("DELETE /api/core/tags/{tag}", h.DeleteTag, handler.RouteOwnerRole),
 		handler.NewRoute("POST /api/core/tags/sync", h.SyncTags, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/tags/{tag}/merge", h.MergeTag, handler.RouteOwnerRole),
 	}
 }
 
 func (h *TagHandler) ListTags(w http.ResponseWriter, r *http.Request) {
-	private := r.URL.Query().Has("private")
+	visibility := VisibilityFromRequest(r)
 
-	tags, err := h.service.ListTags(private)
+	if r.URL.Query().Has("tree") {
+		tree, err := h.service.ListTagTree(r.Context(), visibility)
+		if err != nil {
+			h.SendJSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		h.SendJSON(w, http.StatusOK, tree)
+		return
+	}
+
+	tags, err := h.service.ListTags(r.Context(), visibility)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -42,15 +54,17 @@ func (h *TagHandler) GetTag(w http.ResponseWriter, r *http.Request) {
 	id, err := h.GetStringFromPath(r, "tag")
 	if err != nil {
 		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	tag, err := h.service.GetTag(id)
+	tag, err := h.service.GetTag(r.Context(), id, VisibilityFromRequest(r))
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	if tag == nil {
 		h.SendJSON(w, http.StatusNotFound, "tag not found")
+		return
 	}
 
 	h.SendJSON(w, http.StatusOK, tag)
@@ -64,7 +78,7 @@ func (h *TagHandler) CreateTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.CreateTag(&body)
+	result, err := h.service.CreateTag(r.Context(), &body)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -89,7 +103,7 @@ func (h *TagHandler) UpdateTag(w http.ResponseWriter, r *http.Request) {
 
 	body.Tag = tag
 
-	result, err := h.service.UpdateTag(&body)
+	result, err := h.service.UpdateTag(r.Context(), &body)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -105,7 +119,7 @@ func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.DeleteTag(tag)
+	err = h.service.DeleteTag(r.Context(), tag)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -114,8 +128,33 @@ func (h *TagHandler) DeleteTag(w http.ResponseWriter, r *http.Request) {
 	h.SendJSON(w, http.StatusAccepted, nil)
 }
 
+func (h *TagHandler) MergeTag(w http.ResponseWriter, r *http.Request) {
+	tag, err := h.GetStringFromPath(r, "tag")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body MergeTagRequest
+	err = h.ParseJSON(r, &body)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body.Tag = tag
+
+	result, err := h.service.MergeTag(r.Context(), &body)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
+
 func (h *TagHandler) SyncTags(w http.ResponseWriter, r *http.Request) {
-	err := h.service.SynchronizeTags()
+	err := h.service.SynchronizeTags(r.Context())
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
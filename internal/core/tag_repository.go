@@ -1,32 +1,43 @@
 package core
 
 import (
+	"backend/internal/db"
 	"context"
 	"errors"
-	"fmt"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TagRepository struct {
-	db *pgxpool.Pool
+	db db.DBTX
 }
 
 func NewTagRepository(db *pgxpool.Pool) *TagRepository {
 	return &TagRepository{db}
 }
 
-func (r *TagRepository) ListTags(private bool) ([]Tag, error) {
-	rows, err := r.db.Query(context.Background(), `
-		SELECT tag, description, parent, private
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *TagRepository) WithTx(tx pgx.Tx) *TagRepository {
+	return &TagRepository{tx}
+}
+
+func (r *TagRepository) ListTags(ctx context.Context, visibility Visibility) ([]Tag, error) {
+	where := ""
+	if condition := visibility.TagCondition(); len(condition) > 0 {
+		where = "WHERE " + condition
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT tag, description, parent, private, exclusive
 		FROM tags
-		WHERE $1 OR NOT private
+		`+where+`
 		ORDER BY tag ASC
-	`, private)
+	`)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	tags := make([]Tag, 0)
 	for rows.Next() {
@@ -36,6 +47,7 @@ func (r *TagRepository) ListTags(private bool) ([]Tag, error) {
 			&tag.Description,
 			&tag.Parent,
 			&tag.Private,
+			&tag.Exclusive,
 		)
 		if err != nil {
 			return nil, err
@@ -43,20 +55,29 @@ func (r *TagRepository) ListTags(private bool) ([]Tag, error) {
 		tags = append(tags, tag)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return tags, nil
 }
 
-func (r *TagRepository) GetTag(tag string) (*Tag, error) {
+func (r *TagRepository) GetTag(ctx context.Context, tag string, visibility Visibility) (*Tag, error) {
+	where := "WHERE tag = $1"
+	if condition := visibility.TagCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
 	var result Tag
-	err := r.db.QueryRow(context.Background(), `
-		SELECT tag, description, parent, private
+	err := r.db.QueryRow(ctx, `
+		SELECT tag, description, parent, private, exclusive
 		FROM tags
-		WHERE tag = $1
-	`, tag).Scan(
+		`+where, tag).Scan(
 		&result.Tag,
 		&result.Description,
 		&result.Parent,
 		&result.Private,
+		&result.Exclusive,
 	)
 	if err == pgx.ErrNoRows {
 		return nil, nil
@@ -68,44 +89,41 @@ func (r *TagRepository) GetTag(tag string) (*Tag, error) {
 	return &result, nil
 }
 
-func (r *TagRepository) CreateTag(data *Tag) (*Tag, error) {
+func (r *TagRepository) CreateTag(ctx context.Context, data *Tag) (*Tag, error) {
 	var tag string
-	err := r.db.QueryRow(context.Background(), `
-		INSERT INTO tags (tag, description, parent, private)
-		VALUES ($1, $2, $3, $4)
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO tags (tag, description, parent, private, exclusive)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING tag
-	`, data.Tag, data.Description, data.Parent, data.Private).Scan(&tag)
+	`, data.Tag, data.Description, data.Parent, data.Private, data.Exclusive).Scan(&tag)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetTag(tag)
+	return r.GetTag(ctx, tag, FullVisibility)
 }
 
-func (r *TagRepository) UpdateTag(data *Tag, rename *string) (*Tag, error) {
-	newTag := data.Tag
-	if rename != nil {
-		newTag = *rename
-	}
+// UpdateTag changes the attributes of the tag, renames go through RenameTags
+func (r *TagRepository) UpdateTag(ctx context.Context, data *Tag) (*Tag, error) {
 	var tag string
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		UPDATE tags
-		SET tag = $2,
-			description = $3,
-			parent = $4,
-			private = $5
+		SET description = $2,
+			parent = $3,
+			private = $4,
+			exclusive = $5
 		WHERE tag = $1
 		RETURNING tag
-	`, &data.Tag, newTag, &data.Description, &data.Parent, &data.Private).Scan(&tag)
+	`, &data.Tag, &data.Description, &data.Parent, &data.Private, &data.Exclusive).Scan(&tag)
 	if err != nil {
 		return nil, err
 	}
 
-	return r.GetTag(tag)
+	return r.GetTag(ctx, tag, FullVisibility)
 }
 
-func (r *TagRepository) DeleteTag(tag string) error {
-	cmd, err := r.db.Exec(context.Background(), `
+func (r *TagRepository) DeleteTag(ctx context.Context, tag string) error {
+	cmd, err := r.db.Exec(ctx, `
 		DELETE FROM tags
 		WHERE tag = $1
 	`, tag)
@@ -120,16 +138,73 @@ func (r *TagRepository) DeleteTag(tag string) error {
 	return nil
 }
 
-func (r *TagRepository) SynchronizeTags(tags []string) error {
-	cmd, err := r.db.Exec(context.Background(), `
-		INSERT INTO tags (tag)
-		SELECT unnest($1::TEXT[])
-		ON CONFLICT (tag) DO NOTHING;
-	`, tags)
+// RenameTags moves the tag and its namespaced descendants, e.g. "health:sleep", from source to target.
+// Missing target tags are created, existing ones are merged: they become private and exclusive when the source was,
+// and keep their description. The source tags are deleted afterwards.
+func (r *TagRepository) RenameTags(ctx context.Context, source, target string) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT $2 || substr(tag, length($1) + 1)
+		FROM tags
+		WHERE tag = $1 OR starts_with(tag, $1 || '`+TagSeparator+`')
+	`, source, target)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print(cmd.RowsAffected())
-	return nil
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return err
+	}
+
+	err = r.SynchronizeTags(ctx, append(names, target))
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		UPDATE tags
+		SET description = COALESCE(tags.description, source.description),
+			private = tags.private OR source.private,
+			exclusive = tags.exclusive OR source.exclusive
+		FROM tags AS source
+		WHERE (source.tag = $1 OR starts_with(source.tag, $1 || '`+TagSeparator+`'))
+			AND tags.tag = $2 || substr(source.tag, length($1) + 1)
+	`, source, target)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		DELETE FROM tags
+		WHERE tag = $1 OR starts_with(tag, $1 || '`+TagSeparator+`')
+	`, source)
+
+	return err
+}
+
+// SynchronizeTags inserts missing tags together with their parents, existing tags without a parent get one
+func (r *TagRepository) SynchronizeTags(ctx context.Context, tags []string) error {
+	unique := make(map[string]bool)
+	names := make([]string, 0)
+	parents := make([]*string, 0)
+	for _, tag := range tags {
+		for _, name := range append(TagAncestors(tag), tag) {
+			if unique[name] {
+				continue
+			}
+			unique[name] = true
+			names = append(names, name)
+			parents = append(parents, ParentTag(name))
+		}
+	}
+
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO tags (tag, parent)
+		SELECT * FROM unnest($1::TEXT[], $2::TEXT[])
+		ON CONFLICT (tag) DO UPDATE
+		SET parent = EXCLUDED.parent
+		WHERE tags.parent IS NULL AND EXCLUDED.parent IS NOT NULL;
+	`, names, parents)
+
+	return err
 }
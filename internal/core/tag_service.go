@@ -1,28 +1,66 @@
 package core
 
+import (
+	"backend/internal/db"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// events renamed at once when a tag is renamed or merged
+const tagReplaceChunkSize = 500
+
 type TagService struct {
+	txManager *db.TxManager
 	tagRepo   *TagRepository
 	eventRepo *EventRepository
+	journal   *EventJournal
 }
 
-func NewTagService(tagRepo *TagRepository, eventRepo *EventRepository) *TagService {
-	return &TagService{tagRepo, eventRepo}
+func NewTagService(txManager *db.TxManager, tagRepo *TagRepository, eventRepo *EventRepository, journal *EventJournal) *TagService {
+	return &TagService{txManager, tagRepo, eventRepo, journal}
 }
 
-func (s *TagService) ListTags(private bool) ([]Tag, error) {
-	return s.tagRepo.ListTags(private)
+func (s *TagService) ListTags(ctx context.Context, visibility Visibility) ([]Tag, error) {
+	return s.tagRepo.ListTags(ctx, visibility)
+}
+
+func (s *TagService) ListTagTree(ctx context.Context, visibility Visibility) ([]*TagNode, error) {
+	tags, err := s.tagRepo.ListTags(ctx, visibility)
+	if err != nil {
+		return nil, err
+	}
+
+	return BuildTagTree(tags), nil
 }
 
-func (s *TagService) GetTag(tag string) (*Tag, error) {
-	return s.tagRepo.GetTag(tag)
+func (s *TagService) GetTag(ctx context.Context, tag string, visibility Visibility) (*Tag, error) {
+	return s.tagRepo.GetTag(ctx, tag, visibility)
 }
 
-func (s *TagService) CreateTag(data *CreateTagRequest) (*Tag, error) {
-	// TODO: find parent based on tag string
-	tag, err := s.tagRepo.CreateTag(&Tag{
-		Tag:         data.Tag,
-		Description: data.Description,
-		Private:     data.Private,
+func (s *TagService) CreateTag(ctx context.Context, data *CreateTagRequest) (*Tag, error) {
+	var tag *Tag
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		tagRepo := s.tagRepo.WithTx(tx)
+
+		// make sure the whole parent chain exists
+		err := tagRepo.SynchronizeTags(ctx, TagAncestors(data.Tag))
+		if err != nil {
+			return err
+		}
+
+		tag, err = tagRepo.CreateTag(ctx, &Tag{
+			Tag:         data.Tag,
+			Description: data.Description,
+			Parent:      ParentTag(data.Tag),
+			Private:     data.Private,
+			Exclusive:   data.Exclusive,
+		})
+		return err
 	})
 	if err != nil {
 		return nil, err
@@ -31,13 +69,64 @@ func (s *TagService) CreateTag(data *CreateTagRequest) (*Tag, error) {
 	return tag, nil
 }
 
-func (s *TagService) UpdateTag(data *UpdateTagRequest) (*Tag, error) {
-	// TODO: find and update parent based on tag string
-	tag, err := s.tagRepo.UpdateTag(&Tag{
-		Tag:         data.Tag,
-		Description: data.Description,
-		Private:     data.Private,
-	}, data.NewTag)
+// UpdateTag changes the attributes of the tag, a new name renames its namespaced descendants as well
+func (s *TagService) UpdateTag(ctx context.Context, data *UpdateTagRequest) (*Tag, error) {
+	name := data.Tag
+	if data.NewTag != nil {
+		name = *data.NewTag
+	}
+
+	var tag *Tag
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		tagRepo := s.tagRepo.WithTx(tx)
+
+		if name != data.Tag {
+			if isTagDescendant(name, data.Tag) {
+				return errors.New("TagService.UpdateTag: cannot rename tag below itself")
+			}
+
+			source, err := tagRepo.GetTag(ctx, data.Tag, FullVisibility)
+			if err != nil {
+				return err
+			}
+			if source == nil {
+				return fmt.Errorf("TagService.UpdateTag: tag %s not found", data.Tag)
+			}
+
+			existing, err := tagRepo.GetTag(ctx, name, FullVisibility)
+			if err != nil {
+				return err
+			}
+			if existing != nil {
+				return fmt.Errorf("TagService.UpdateTag: tag %s already exists, merge the tags instead", name)
+			}
+
+			// keep the events in sync with the renamed tags
+			_, err = s.replaceTag(ctx, tx, data.Tag, name)
+			if err != nil {
+				return fmt.Errorf("TagService.UpdateTag: failed to update events, %v", err)
+			}
+
+			err = tagRepo.RenameTags(ctx, data.Tag, name)
+			if err != nil {
+				return fmt.Errorf("TagService.UpdateTag: failed to rename tags, %v", err)
+			}
+		}
+
+		err := tagRepo.SynchronizeTags(ctx, TagAncestors(name))
+		if err != nil {
+			return err
+		}
+
+		tag, err = tagRepo.UpdateTag(ctx, &Tag{
+			Tag:         name,
+			Description: data.Description,
+			Parent:      ParentTag(name),
+			Private:     data.Private,
+			Exclusive:   data.Exclusive,
+		})
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -45,17 +134,85 @@ func (s *TagService) UpdateTag(data *UpdateTagRequest) (*Tag, error) {
 	return tag, nil
 }
 
-func (s *TagService) DeleteTag(tag string) error {
-	return s.tagRepo.DeleteTag(tag)
+// MergeTag rewrites all events tagged with the source tag or its namespaced descendants to the target tag,
+// e.g. "health:sleep" becomes "target:sleep", and deletes the source tags
+func (s *TagService) MergeTag(ctx context.Context, data *MergeTagRequest) (*MergeTagResponse, error) {
+	if len(data.Target) == 0 {
+		return nil, errors.New("TagService.MergeTag: missing target")
+	}
+	if data.Tag == data.Target || isTagDescendant(data.Target, data.Tag) {
+		return nil, errors.New("TagService.MergeTag: cannot merge tag into itself")
+	}
+
+	result := &MergeTagResponse{}
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		tagRepo := s.tagRepo.WithTx(tx)
+
+		var err error
+		result.Events, err = s.replaceTag(ctx, tx, data.Tag, data.Target)
+		if err != nil {
+			return fmt.Errorf("TagService.MergeTag: failed to update events, %v", err)
+		}
+
+		err = tagRepo.RenameTags(ctx, data.Tag, data.Target)
+		if err != nil {
+			return fmt.Errorf("TagService.MergeTag: failed to merge tags, %v", err)
+		}
+
+		result.Tag, err = tagRepo.GetTag(ctx, data.Target, FullVisibility)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// replaceTag renames the tag and its namespaced descendants in every event, each change is recorded in the journal.
+// The events are handled in chunks of tagReplaceChunkSize, returns the number of updated events.
+func (s *TagService) replaceTag(ctx context.Context, tx pgx.Tx, tag, target string) (int64, error) {
+	ids, err := s.eventRepo.WithTx(tx).ListTagged(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+
+	for chunk := range slices.Chunk(ids, tagReplaceChunkSize) {
+		previous, err := s.journal.Snapshots(ctx, tx, chunk)
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.eventRepo.WithTx(tx).ReplaceTag(ctx, chunk, tag, target)
+		if err != nil {
+			return 0, err
+		}
+
+		err = s.journal.RecordUpdates(ctx, tx, chunk, previous)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(ids)), nil
+}
+
+// isTagDescendant reports whether the tag is a namespaced descendant of ancestor, e.g. "health:sleep" of "health"
+func isTagDescendant(tag, ancestor string) bool {
+	return strings.HasPrefix(tag, ancestor+TagSeparator)
+}
+
+func (s *TagService) DeleteTag(ctx context.Context, tag string) error {
+	return s.tagRepo.DeleteTag(ctx, tag)
 }
 
-func (s *TagService) SynchronizeTags() error {
-	tags, err := s.eventRepo.UsedTags()
+func (s *TagService) SynchronizeTags(ctx context.Context) error {
+	tags, err := s.eventRepo.UsedTags(ctx)
 	if err != nil {
 		return err
 	}
 
-	err = s.tagRepo.SynchronizeTags(tags)
+	err = s.tagRepo.SynchronizeTags(ctx, tags)
 
 	return err
 }
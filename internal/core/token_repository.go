@@ -16,9 +16,9 @@ func NewTokenRepository(db *pgxpool.Pool) *TokenRepository {
 	}
 }
 
-func (r *TokenRepository) Get(jti string) (*Token, error) {
+func (r *TokenRepository) Get(ctx context.Context, jti string) (*Token, error) {
 	token := &Token{}
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
 			id, user_id, jti, created, expiration, blocked
 		FROM tokens
@@ -31,8 +31,8 @@ func (r *TokenRepository) Get(jti string) (*Token, error) {
 	return token, nil
 }
 
-func (r *TokenRepository) Create(token Token) error {
-	err := r.db.QueryRow(context.Background(), `
+func (r *TokenRepository) Create(ctx context.Context, token Token) error {
+	err := r.db.QueryRow(ctx, `
 		INSERT INTO tokens (user_id, jti, expiration)
 		VALUES ($1, $2, $3)
 		RETURNING id
@@ -41,8 +41,8 @@ func (r *TokenRepository) Create(token Token) error {
 	return err
 }
 
-func (r *TokenRepository) Invalidate(id int64) error {
-	_, err := r.db.Exec(context.Background(), `
+func (r *TokenRepository) Invalidate(ctx context.Context, id int64) error {
+	_, err := r.db.Exec(ctx, `
         UPDATE tokens
         SET blocked = TRUE
         WHERE id = $1
@@ -51,8 +51,8 @@ func (r *TokenRepository) Invalidate(id int64) error {
 	return err
 }
 
-func (r *TokenRepository) Delete(jti string) error {
-	_, err := r.db.Exec(context.Background(), `
+func (r *TokenRepository) Delete(ctx context.Context, jti string) error {
+	_, err := r.db.Exec(ctx, `
 		DELETE FROM tokens
 		WHERE jti = $1
 	`, jti)
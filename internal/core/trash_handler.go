@@ -0,0 +1,106 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+	"time"
+)
+
+type TrashHandler struct {
+	handler.BaseHandler
+
+	service *TrashService
+}
+
+func NewTrashHandler(service *TrashService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+func (h *TrashHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/trash/{$}", h.ListTrash, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/trash/{$}", h.PurgeTrash, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/trash/{id}/restore", h.RestoreEvent, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/trash/{id}", h.PurgeEvent, handler.RouteOwnerRole),
+	}
+}
+
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	query := &EventQueryBuilder{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListTrash(r.Context(), query)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *TrashHandler) RestoreEvent(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	event, err := h.service.RestoreEvent(r.Context(), eventId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if event == nil {
+		h.SendJSON(w, http.StatusNotFound, "event not found in trash")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, event)
+}
+
+func (h *TrashHandler) PurgeEvent(w http.ResponseWriter, r *http.Request) {
+	eventId, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	purged, err := h.service.PurgeEvent(r.Context(), eventId, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if !purged {
+		h.SendJSON(w, http.StatusNotFound, "event not found in trash")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// PurgeTrash empties the trash, only events trashed before the optional "before" time are purged
+func (h *TrashHandler) PurgeTrash(w http.ResponseWriter, r *http.Request) {
+	before := time.Now()
+	if r.URL.Query().Has("before") {
+		var err error
+		before, err = time.Parse(time.RFC3339, r.URL.Query().Get("before"))
+		if err != nil {
+			h.SendJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	count, err := h.service.PurgeTrash(r.Context(), before, VisibilityFromRequest(r))
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, map[string]int{"purged": count})
+}
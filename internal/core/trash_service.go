@@ -0,0 +1,173 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type TrashService struct {
+	txManager *db.TxManager
+	repo      *EventRepository
+	journal   *EventJournal
+}
+
+func NewTrashService(txManager *db.TxManager, repo *EventRepository, journal *EventJournal) *TrashService {
+	return &TrashService{
+		txManager: txManager,
+		repo:      repo,
+		journal:   journal,
+	}
+}
+
+func (s *TrashService) ListTrash(ctx context.Context, query *EventQueryBuilder) (*EventPage[EventResponse], error) {
+	query.Trash = true
+
+	events, err := s.repo.ListEvents(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]EventResponse, len(events))
+	for i, event := range events {
+		result[i] = *event.ToEventResponse()
+	}
+
+	return NewEventPage(result, query, func(e *EventResponse) *EventResponse { return e }), nil
+}
+
+// RestoreEvent takes the event out of the trash, returns nil when the event is not in the trash
+func (s *TrashService) RestoreEvent(ctx context.Context, id int64, visibility Visibility) (*EventResponse, error) {
+	var event *Event
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		trashed, err := s.getTrashed(ctx, tx, id, visibility)
+		if err != nil || trashed == nil {
+			return err
+		}
+
+		previous, err := s.journal.Snapshot(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = s.repo.WithTx(tx).UndeleteEvent(ctx, id)
+		if err != nil {
+			return fmt.Errorf("TrashService.RestoreEvent: %v", err)
+		}
+
+		err = s.journal.Record(ctx, tx, RevisionActionRestore, id, previous)
+		if err != nil {
+			return err
+		}
+
+		event, err = s.repo.WithTx(tx).GetEvent(ctx, id, FullVisibility)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	return event.ToEventResponse(), nil
+}
+
+// PurgeEvent permanently deletes the event from the trash, returns false when the event is not in the trash
+func (s *TrashService) PurgeEvent(ctx context.Context, id int64, visibility Visibility) (bool, error) {
+	purged := false
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		trashed, err := s.getTrashed(ctx, tx, id, visibility)
+		if err != nil || trashed == nil {
+			return err
+		}
+
+		err = s.purge(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		purged = true
+		return nil
+	})
+
+	return purged, err
+}
+
+// PurgeTrash permanently deletes every visible event trashed before the given time and returns their number
+func (s *TrashService) PurgeTrash(ctx context.Context, before time.Time, visibility Visibility) (int, error) {
+	count := 0
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		ids, err := s.repo.WithTx(tx).ListTrashed(ctx, before, visibility)
+		if err != nil {
+			return fmt.Errorf("TrashService.PurgeTrash: %v", err)
+		}
+
+		for _, id := range ids {
+			err = s.purge(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+		}
+
+		count = len(ids)
+		return nil
+	})
+
+	return count, err
+}
+
+// RunAutoPurge periodically purges events that have been in the trash for longer than purgeAfter days.
+// It blocks until the context is cancelled.
+func (s *TrashService) RunAutoPurge(ctx context.Context, purgeAfter int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		before := time.Now().AddDate(0, 0, -purgeAfter)
+		count, err := s.PurgeTrash(ctx, before, FullVisibility)
+		if err != nil {
+			fmt.Println("TrashService.RunAutoPurge:", err)
+		} else if count > 0 {
+			fmt.Println("TrashService.RunAutoPurge: purged", count, "events")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *TrashService) getTrashed(ctx context.Context, tx pgx.Tx, id int64, visibility Visibility) (*Event, error) {
+	visibility.Trashed = true
+	event, err := s.repo.WithTx(tx).GetEvent(ctx, id, visibility)
+	if err != nil {
+		return nil, fmt.Errorf("TrashService: failed to load event, %v", err)
+	}
+
+	if event == nil || event.DeletedAt == nil {
+		return nil, nil
+	}
+
+	return event, nil
+}
+
+func (s *TrashService) purge(ctx context.Context, tx pgx.Tx, id int64) error {
+	previous, err := s.journal.Snapshot(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.WithTx(tx).PurgeEvent(ctx, id)
+	if err != nil {
+		return fmt.Errorf("TrashService.purge: %v", err)
+	}
+
+	return s.journal.Record(ctx, tx, RevisionActionPurge, id, previous)
+}
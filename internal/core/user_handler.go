@@ -23,7 +23,7 @@ func (h *UserHandler) GetRoutes() []handler.Route {
 }
 
 func (h *UserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.ListUsers()
+	users, err := h.service.ListUsers(r.Context())
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -39,7 +39,7 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.GetUser(userID)
+	user, err := h.service.GetUser(r.Context(), userID)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -16,9 +16,9 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) GetUser(id int64) (*User, error) {
+func (r *UserRepository) GetUser(ctx context.Context, id int64) (*User, error) {
 	user := &User{}
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
 			id, created, updated, username, password
 		FROM users
@@ -37,9 +37,9 @@ func (r *UserRepository) GetUser(id int64) (*User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) GetByUsername(username string) (*User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
 	user := &User{}
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
 			id, created, updated, username, password
 		FROM users
@@ -58,10 +58,10 @@ func (r *UserRepository) GetByUsername(username string) (*User, error) {
 	return user, nil
 }
 
-func (r *UserRepository) Create(username, password string) (*User, error) {
+func (r *UserRepository) Create(ctx context.Context, username, password string) (*User, error) {
 	user := &User{}
 
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		INSERT INTO users (username, password)
 		VALUES ($1, $2)
 		RETURNING id, created, updated, username, password
@@ -73,8 +73,8 @@ func (r *UserRepository) Create(username, password string) (*User, error) {
 	return user, err
 }
 
-func (r *UserRepository) ListUsers() ([]User, error) {
-	rows, err := r.db.Query(context.Background(), `
+func (r *UserRepository) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := r.db.Query(ctx, `
 		SELECT
 			id, created, updated, username
 		FROM users
@@ -95,5 +95,9 @@ func (r *UserRepository) ListUsers() ([]User, error) {
 		users = append(users, user)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return users, nil
 }
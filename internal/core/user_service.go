@@ -1,5 +1,7 @@
 package core
 
+import "context"
+
 type UserService struct {
 	repo *UserRepository
 }
@@ -8,10 +10,10 @@ func NewUserService(repo *UserRepository) *UserService {
 	return &UserService{repo}
 }
 
-func (s *UserService) ListUsers() ([]User, error) {
-	return s.repo.ListUsers()
+func (s *UserService) ListUsers(ctx context.Context) ([]User, error) {
+	return s.repo.ListUsers(ctx)
 }
 
-func (s *UserService) GetUser(id int64) (*User, error) {
-	return s.repo.GetUser(id)
+func (s *UserService) GetUser(ctx context.Context, id int64) (*User, error) {
+	return s.repo.GetUser(ctx, id)
 }
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"backend/pkg/jwt"
+	"net/http"
+	"strings"
+)
+
+// Visibility decides which events and tags the caller may see.
+// A tag is private when it or any of its parents is marked as private,
+// an event is private when it carries a private tag.
+type Visibility struct {
+	// private events and tags are visible
+	Private bool
+	// events in the trash are visible
+	Trashed bool
+}
+
+// FullVisibility is used for internal reads that are not returned to a caller as is
+var FullVisibility = Visibility{Private: true, Trashed: true}
+
+// NewVisibility allows private events only for the user, and only when they opt in.
+// Providers never see private events.
+func NewVisibility(claims jwt.Claims, optIn bool) Visibility {
+	return Visibility{
+		Private: claims.Type == jwt.UserClaim && optIn,
+	}
+}
+
+// VisibilityFromRequest reads the claims from the request context and the "private" query opt-in
+func VisibilityFromRequest(r *http.Request) Visibility {
+	claims, ok := r.Context().Value(handler.RequestClaims).(jwt.Claims)
+	if !ok {
+		return Visibility{}
+	}
+
+	return NewVisibility(claims, r.URL.Query().Has("private"))
+}
+
+// EventCondition returns the SQL condition hiding events the caller may not see, or empty string
+func (v Visibility) EventCondition() string {
+	and := make([]string, 0)
+
+	if !v.Trashed {
+		and = append(and, "events.deleted_at IS NULL")
+	}
+
+	if condition := v.TagsCondition("events.tags"); len(condition) > 0 {
+		and = append(and, condition)
+	}
+
+	return strings.Join(and, " AND ")
+}
+
+// TagsCondition returns the SQL condition hiding rows whose tag array expression contains a private tag, or empty string
+func (v Visibility) TagsCondition(tags string) string {
+	if v.Private {
+		return ""
+	}
+
+	return `NOT EXISTS (
+		SELECT 1 FROM UNNEST(` + tags + `) AS event_tag
+		INNER JOIN tags AS private_tags ON event_tag = private_tags.tag OR starts_with(event_tag, private_tags.tag || '` + TagSeparator + `')
+		WHERE private_tags.private = TRUE
+	)`
+}
+
+// TagCondition returns the SQL condition hiding tags the caller may not see, or empty string
+func (v Visibility) TagCondition() string {
+	if v.Private {
+		return ""
+	}
+
+	return `NOT EXISTS (
+		SELECT 1 FROM tags AS private_tags
+		WHERE private_tags.private = TRUE AND (tags.tag = private_tags.tag OR starts_with(tags.tag, private_tags.tag || '` + TagSeparator + `'))
+	)`
+}
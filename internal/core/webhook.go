@@ -0,0 +1,159 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Webhook struct {
+	ID     int64
+	URL    string
+	Secret string
+	// events carrying any of the tags or their descendants, all events when empty
+	Tags []string
+	// events of any of the types, all types when empty
+	Types []EventType
+	// deliver events with private tags
+	Private bool
+	Active  bool
+	Created time.Time
+	Updated time.Time
+}
+
+func (w *Webhook) ToWebhookResponse() *WebhookResponse {
+	return &WebhookResponse{
+		ID:      w.ID,
+		URL:     w.URL,
+		Tags:    w.Tags,
+		Types:   w.Types,
+		Private: w.Private,
+		Active:  w.Active,
+		Created: w.Created,
+		Updated: w.Updated,
+	}
+}
+
+type WebhookRequest struct {
+	URL string `json:"url"`
+	// generated when empty
+	Secret  string      `json:"secret,omitempty"`
+	Tags    []string    `json:"tags,omitempty"`
+	Types   []EventType `json:"types,omitempty"`
+	Private bool        `json:"private"`
+	Active  bool        `json:"active"`
+}
+
+func (r *WebhookRequest) Validate() error {
+	target, err := url.Parse(r.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || len(target.Host) == 0 {
+		return errors.New("WebhookRequest.Validate: invalid url")
+	}
+
+	for _, eventType := range r.Types {
+		if eventType != EventTypeMoment && eventType != EventTypeInterval {
+			return errors.New("WebhookRequest.Validate: invalid type " + string(eventType))
+		}
+	}
+
+	return nil
+}
+
+func (r *WebhookRequest) ToWebhook() *Webhook {
+	webhook := &Webhook{
+		URL:     r.URL,
+		Secret:  r.Secret,
+		Tags:    r.Tags,
+		Types:   r.Types,
+		Private: r.Private,
+		Active:  r.Active,
+	}
+
+	if webhook.Tags == nil {
+		webhook.Tags = []string{}
+	}
+	if webhook.Types == nil {
+		webhook.Types = []EventType{}
+	}
+
+	return webhook
+}
+
+type WebhookResponse struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+	// returned only when the webhook is created
+	Secret  string      `json:"secret,omitempty"`
+	Tags    []string    `json:"tags"`
+	Types   []EventType `json:"types"`
+	Private bool        `json:"private"`
+	Active  bool        `json:"active"`
+	Created time.Time   `json:"created"`
+	Updated time.Time   `json:"updated"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	WebhookID      int64                 `json:"webhookId"`
+	RevisionID     int64                 `json:"revisionId"`
+	EventID        int64                 `json:"eventId"`
+	Action         RevisionAction        `json:"action"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttempt    time.Time             `json:"nextAttempt"`
+	ResponseStatus *int                  `json:"responseStatus,omitempty"`
+	Error          *string               `json:"error,omitempty"`
+	Created        time.Time             `json:"created"`
+	Delivered      *time.Time            `json:"delivered,omitempty"`
+}
+
+// WebhookPayload is the body posted to the webhook URL
+type WebhookPayload struct {
+	Revision int64          `json:"revision"`
+	Action   RevisionAction `json:"action"`
+	Created  time.Time      `json:"created"`
+	// state after the change, for deletes the state before the delete
+	Event *EventSnapshot `json:"event"`
+}
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookActionHeader    = "X-Webhook-Action"
+)
+
+// SignWebhookPayload returns the value of the signature header, a hex HMAC-SHA256 of "timestamp.body"
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 10
+	webhookTimeout      = 10 * time.Second
+	webhookMaxAttempts  = 10
+	// first retry after 30s, doubled with every attempt up to webhookMaxBackoff
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 6 * time.Hour
+	// response bodies are cut in the delivery log
+	webhookMaxErrorLength = 1024
+	// a claimed delivery is not sent by another worker for this long, it is sent again when the result is never stored
+	webhookClaimLease = webhookBatchSize*webhookTimeout + time.Minute
+)
+
+// WebhookDispatcher posts queued deliveries to the webhook URLs. Several instances can run
+// side by side, each delivery is claimed by the instance sending it.
+type WebhookDispatcher struct {
+	repo   *WebhookRepository
+	client *http.Client
+}
+
+func NewWebhookDispatcher(repo *WebhookRepository) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		repo:   repo,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Run sends due deliveries until the context is cancelled
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			count, err := d.dispatch(ctx)
+			if err != nil {
+				fmt.Println("WebhookDispatcher.Run:", err)
+			}
+			// a full batch means there may be more due deliveries
+			if err != nil || count < webhookBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch claims due deliveries in a short statement and sends them without holding locks,
+// each result is stored on its own so a failing update does not resend the others
+func (d *WebhookDispatcher) dispatch(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.ClaimPendingDeliveries(ctx, webhookBatchSize, webhookClaimLease)
+	if err != nil {
+		return 0, fmt.Errorf("WebhookDispatcher.dispatch: failed to claim deliveries, %v", err)
+	}
+
+	webhooks := make(map[int64]*Webhook)
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = d.repo.GetWebhook(ctx, delivery.WebhookID)
+			if err != nil {
+				return 0, fmt.Errorf("WebhookDispatcher.dispatch: failed to load webhook, %v", err)
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+
+		// deleted in the meantime, the delivery is removed with it
+		if webhook == nil {
+			continue
+		}
+
+		d.send(ctx, webhook, delivery)
+
+		err = d.repo.UpdateDelivery(ctx, delivery)
+		if err != nil {
+			// the claim expires and the delivery is sent again
+			fmt.Println("WebhookDispatcher.dispatch: failed to update delivery,", err)
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// send posts the payload and records the result in the delivery
+func (d *WebhookDispatcher) send(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) {
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+	delivery.Error = nil
+
+	statusCode, err := d.post(ctx, webhook, delivery)
+	if statusCode > 0 {
+		delivery.ResponseStatus = &statusCode
+	}
+
+	if err == nil {
+		now := time.Now()
+		delivery.Status = WebhookDeliveryDelivered
+		delivery.Delivered = &now
+		return
+	}
+
+	message := truncateWebhookError(err.Error())
+	delivery.Error = &message
+
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = WebhookDeliveryFailed
+		return
+	}
+
+	delivery.NextAttempt = time.Now().Add(webhookBackoff(delivery.Attempts))
+}
+
+func (d *WebhookDispatcher) post(ctx context.Context, webhook *Webhook, delivery *WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(WebhookActionHeader, string(delivery.Action))
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, webhookMaxErrorLength))
+		return response.StatusCode, fmt.Errorf("unexpected status %d: %s", response.StatusCode, body)
+	}
+
+	return response.StatusCode, nil
+}
+
+// truncateWebhookError makes the message storable as TEXT, response bodies may hold invalid UTF-8 or NUL bytes,
+// and cuts it to webhookMaxErrorLength bytes on a rune boundary
+func truncateWebhookError(message string) string {
+	message = strings.ToValidUTF8(message, "\uFFFD")
+	message = strings.ReplaceAll(message, "\x00", "")
+
+	if len(message) <= webhookMaxErrorLength {
+		return message
+	}
+
+	cut := webhookMaxErrorLength
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut]
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, webhookMaxBackoff)
+}
@@ -0,0 +1,124 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	body := []byte(`{"revision":1}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if signature := SignWebhookPayload("secret", 1700000000, body); signature != expected {
+		t.Fatalf("signature %s, expected %s", signature, expected)
+	}
+
+	if SignWebhookPayload("other", 1700000000, body) == expected {
+		t.Fatal("signature does not depend on the secret")
+	}
+
+	if SignWebhookPayload("secret", 1700000001, body) == expected {
+		t.Fatal("signature does not depend on the timestamp")
+	}
+}
+
+func TestWebhookDispatcherSend(t *testing.T) {
+	webhook := &Webhook{ID: 1, Secret: "secret"}
+	delivery := &WebhookDelivery{ID: 7, WebhookID: 1, Action: RevisionActionCreate, Payload: []byte(`{"revision":3}`), Status: WebhookDeliveryPending}
+
+	// the first request fails with a body of invalid UTF-8 longer than the log, the second succeeds
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+		if err != nil {
+			t.Errorf("invalid timestamp header, %v", err)
+		}
+
+		if r.Header.Get(WebhookSignatureHeader) != SignWebhookPayload(webhook.Secret, timestamp, body) {
+			t.Error("invalid signature")
+		}
+
+		if r.Header.Get(WebhookDeliveryHeader) != "7" || r.Header.Get(WebhookActionHeader) != string(RevisionActionCreate) {
+			t.Error("missing delivery headers")
+		}
+
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(strings.Repeat("é", webhookMaxErrorLength) + "\xff\x00"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	webhook.URL = receiver.URL
+
+	dispatcher := NewWebhookDispatcher(nil)
+
+	before := time.Now()
+	dispatcher.send(t.Context(), webhook, delivery)
+
+	if delivery.Status != WebhookDeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("status %s after %d attempts, expected a pending retry", delivery.Status, delivery.Attempts)
+	}
+
+	if delivery.ResponseStatus == nil || *delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Fatal("missing response status")
+	}
+
+	if delivery.Error == nil || !utf8.ValidString(*delivery.Error) || strings.Contains(*delivery.Error, "\x00") || len(*delivery.Error) > webhookMaxErrorLength {
+		t.Fatalf("error is not storable: %q", *delivery.Error)
+	}
+
+	if delivery.NextAttempt.Before(before.Add(webhookBaseBackoff)) {
+		t.Fatal("retry is not delayed")
+	}
+
+	dispatcher.send(t.Context(), webhook, delivery)
+
+	if delivery.Status != WebhookDeliveryDelivered || delivery.Attempts != 2 || delivery.Delivered == nil || delivery.Error != nil {
+		t.Fatalf("status %s after %d attempts, expected delivered", delivery.Status, delivery.Attempts)
+	}
+
+	if requests != 2 {
+		t.Fatalf("%d requests, expected 2", requests)
+	}
+}
+
+func TestWebhookDispatcherSendFailsAfterMaxAttempts(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer receiver.Close()
+
+	webhook := &Webhook{ID: 1, URL: receiver.URL, Secret: "secret"}
+	delivery := &WebhookDelivery{ID: 1, Payload: []byte(`{}`), Status: WebhookDeliveryPending, Attempts: webhookMaxAttempts - 1}
+
+	NewWebhookDispatcher(nil).send(t.Context(), webhook, delivery)
+
+	if delivery.Status != WebhookDeliveryFailed {
+		t.Fatalf("status %s, expected failed", delivery.Status)
+	}
+}
+
+func TestTruncateWebhookError(t *testing.T) {
+	message := truncateWebhookError(strings.Repeat("a", webhookMaxErrorLength-1) + "€")
+	if !utf8.ValidString(message) || len(message) != webhookMaxErrorLength-1 {
+		t.Fatalf("cut inside a rune, %d bytes", len(message))
+	}
+}
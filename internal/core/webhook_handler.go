@@ -0,0 +1,163 @@
+package core
+
+import (
+	"backend/pkg/handler"
+	"net/http"
+)
+
+type WebhookHandler struct {
+	handler.BaseHandler
+
+	service *WebhookService
+}
+
+func NewWebhookHandler(service *WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("GET /api/core/webhooks/{$}", h.ListWebhooks, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/webhooks/{id}", h.GetWebhook, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/webhooks", h.CreateWebhook, handler.RouteOwnerRole),
+		handler.NewRoute("PUT /api/core/webhooks/{id}", h.UpdateWebhook, handler.RouteOwnerRole),
+		handler.NewRoute("DELETE /api/core/webhooks/{id}", h.DeleteWebhook, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/core/webhooks/{id}/deliveries", h.ListDeliveries, handler.RouteOwnerRole),
+		handler.NewRoute("POST /api/core/webhooks/{id}/deliveries/{delivery}/redeliver", h.Redeliver, handler.RouteOwnerRole),
+	}
+}
+
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	data, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.GetWebhook(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var data WebhookRequest
+	err := h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.CreateWebhook(r.Context(), &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusCreated, result)
+}
+
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var data WebhookRequest
+	err = h.ParseJSON(r, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.service.UpdateWebhook(r.Context(), id, &data)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if result == nil {
+		h.SendJSON(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, result)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.service.DeleteWebhook(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.ListDeliveries(r.Context(), id)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, http.StatusOK, data)
+}
+
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := h.GetInt64FromPath(r, "id")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveryId, err := h.GetInt64FromPath(r, "delivery")
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := h.service.Redeliver(r.Context(), id, deliveryId)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if data == nil {
+		h.SendJSON(w, http.StatusNotFound, "delivery not found")
+		return
+	}
+
+	h.SendJSON(w, http.StatusAccepted, data)
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"backend/internal/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type WebhookRepository struct {
+	db db.DBTX
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *WebhookRepository) WithTx(tx pgx.Tx) *WebhookRepository {
+	return &WebhookRepository{db: tx}
+}
+
+const webhookColumns = "id, url, secret, tags, types, private, active, created, updated"
+
+func scanWebhook(row pgx.Row) (*Webhook, error) {
+	webhook := Webhook{}
+	err := row.Scan(
+		&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.Tags, &webhook.Types,
+		&webhook.Private, &webhook.Active, &webhook.Created, &webhook.Updated,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, *webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *WebhookRepository) GetWebhook(ctx context.Context, id int64) (*Webhook, error) {
+	webhook, err := scanWebhook(r.db.QueryRow(ctx, `
+		SELECT `+webhookColumns+`
+		FROM webhooks
+		WHERE id = $1
+	`, id))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error) {
+	return scanWebhook(r.db.QueryRow(ctx, `
+		INSERT INTO webhooks (url, secret, tags, types, private, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+webhookColumns,
+		webhook.URL, webhook.Secret, webhook.Tags, webhook.Types, webhook.Private, webhook.Active,
+	))
+}
+
+// UpdateWebhook updates the webhook, the secret is kept when empty
+func (r *WebhookRepository) UpdateWebhook(ctx context.Context, webhook *Webhook) (*Webhook, error) {
+	result, err := scanWebhook(r.db.QueryRow(ctx, `
+		UPDATE webhooks
+		SET url = $2,
+		    secret = COALESCE(NULLIF($3, ''), secret),
+		    tags = $4,
+		    types = $5,
+		    private = $6,
+		    active = $7
+		WHERE id = $1
+		RETURNING `+webhookColumns,
+		webhook.ID, webhook.URL, webhook.Secret, webhook.Tags, webhook.Types, webhook.Private, webhook.Active,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM webhooks
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	if cmd.RowsAffected() != 1 {
+		return errors.New("WebhookRepository.DeleteWebhook: no rows affected")
+	}
+
+	return nil
+}
+
+// QueueDeliveries queues the insert of the payload for every active webhook matching the event
+func (r *WebhookRepository) QueueDeliveries(batch *pgx.Batch, payload *WebhookPayload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	batch.Queue(`
+		INSERT INTO webhook_deliveries (webhook_id, revision_id, event_id, action, payload)
+		SELECT webhooks.id, $1, $2, $3, $4
+		FROM webhooks
+		WHERE webhooks.active
+			AND (cardinality(webhooks.types) = 0 OR $5 = ANY(webhooks.types))
+			AND (cardinality(webhooks.tags) = 0 OR EXISTS (
+				SELECT 1 FROM UNNEST(webhooks.tags) AS webhook_tag, UNNEST($6::TEXT[]) AS event_tag
+				WHERE event_tag = webhook_tag OR starts_with(event_tag, webhook_tag || '`+TagSeparator+`')
+			))
+			AND (webhooks.private OR `+Visibility{}.TagsCondition("$6::TEXT[]")+`)
+	`, payload.Revision, payload.Event.ID, payload.Action, data, payload.Event.Type, payload.Event.Tags)
+
+	return nil
+}
+
+const webhookDeliveryColumns = "id, webhook_id, revision_id, event_id, action, payload, status, attempts, next_attempt, response_status, error, created, delivered"
+
+func scanWebhookDelivery(row pgx.Row) (*WebhookDelivery, error) {
+	delivery := WebhookDelivery{}
+	err := row.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.RevisionID, &delivery.EventID, &delivery.Action, &delivery.Payload,
+		&delivery.Status, &delivery.Attempts, &delivery.NextAttempt, &delivery.ResponseStatus, &delivery.Error,
+		&delivery.Created, &delivery.Delivered,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2
+	`, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// ClaimPendingDeliveries returns due deliveries of active webhooks and moves their next attempt by the lease,
+// so other workers skip them while they are sent. Rows locked by another worker are skipped.
+func (r *WebhookRepository) ClaimPendingDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE webhook_deliveries
+		SET next_attempt = CURRENT_TIMESTAMP + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt <= CURRENT_TIMESTAMP
+				AND webhook_id IN (SELECT id FROM webhooks WHERE active)
+			ORDER BY next_attempt ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+webhookDeliveryColumns,
+		limit, lease.Milliseconds(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, *delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the result of a delivery attempt
+func (r *WebhookRepository) UpdateDelivery(ctx context.Context, delivery *WebhookDelivery) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+		    attempts = $3,
+		    next_attempt = $4,
+		    response_status = $5,
+		    error = $6,
+		    delivered = $7
+		WHERE id = $1
+	`, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.ResponseStatus, delivery.Error, delivery.Delivered)
+
+	return err
+}
+
+// ResetDelivery queues the delivery again with a fresh retry budget, returns nil when it does not exist
+func (r *WebhookRepository) ResetDelivery(ctx context.Context, webhookID, deliveryID int64, now time.Time) (*WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'pending',
+		    attempts = 0,
+		    next_attempt = $3
+		WHERE webhook_id = $1 AND id = $2
+		RETURNING `+webhookDeliveryColumns,
+		webhookID, deliveryID, now,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
+	return delivery, err
+}
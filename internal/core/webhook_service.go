@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const webhookDeliveryLogLimit = 100
+
+type WebhookService struct {
+	repo *WebhookRepository
+}
+
+func NewWebhookService(repo *WebhookRepository) *WebhookService {
+	return &WebhookService{repo: repo}
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]WebhookResponse, error) {
+	webhooks, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookService.ListWebhooks: %v", err)
+	}
+
+	result := make([]WebhookResponse, len(webhooks))
+	for i, webhook := range webhooks {
+		result[i] = *webhook.ToWebhookResponse()
+	}
+
+	return result, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id int64) (*WebhookResponse, error) {
+	webhook, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookService.GetWebhook: %v", err)
+	}
+
+	if webhook == nil {
+		return nil, nil
+	}
+
+	return webhook.ToWebhookResponse(), nil
+}
+
+// CreateWebhook registers the webhook, the response contains the secret used to sign the payloads
+func (s *WebhookService) CreateWebhook(ctx context.Context, request *WebhookRequest) (*WebhookResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := request.ToWebhook()
+	if len(webhook.Secret) == 0 {
+		webhook.Secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, fmt.Errorf("WebhookService.CreateWebhook: failed to generate secret, %v", err)
+		}
+	}
+
+	webhook, err = s.repo.CreateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookService.CreateWebhook: %v", err)
+	}
+
+	result := webhook.ToWebhookResponse()
+	result.Secret = webhook.Secret
+
+	return result, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id int64, request *WebhookRequest) (*WebhookResponse, error) {
+	err := request.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := request.ToWebhook()
+	webhook.ID = id
+
+	webhook, err = s.repo.UpdateWebhook(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("WebhookService.UpdateWebhook: %v", err)
+	}
+
+	if webhook == nil {
+		return nil, nil
+	}
+
+	return webhook.ToWebhookResponse(), nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id int64) error {
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// ListDeliveries returns the latest deliveries of the webhook
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID int64) ([]WebhookDelivery, error) {
+	return s.repo.ListDeliveries(ctx, webhookID, webhookDeliveryLogLimit)
+}
+
+// Redeliver queues the delivery again regardless of its status
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID int64) (*WebhookDelivery, error) {
+	delivery, err := s.repo.ResetDelivery(ctx, webhookID, deliveryID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("WebhookService.Redeliver: %v", err)
+	}
+
+	return delivery, nil
+}
@@ -0,0 +1,20 @@
+package locations
+
+import (
+	"backend/pkg/track"
+	"time"
+)
+
+const (
+	// DefaultHistoryExportGap splits the exported track where no point was recorded for longer
+	DefaultHistoryExportGap = 10 * time.Minute
+	// HistoryExportName is the title of exported files
+	HistoryExportName = "Location history"
+	// HistoryExportCreator names the application in the creator attribute of GPX files
+	HistoryExportCreator = "backend"
+)
+
+type HistoryExportRequest struct {
+	Format track.Format
+	Gap    time.Duration
+}
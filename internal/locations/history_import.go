@@ -0,0 +1,69 @@
+package locations
+
+import (
+	"backend/internal/core"
+	"backend/pkg/track"
+	"net/http"
+)
+
+const (
+	// points are created in transactions of this size, a failure later in the file keeps the earlier chunks
+	historyImportChunkSize = 500
+	// MaxHistoryImportErrors limits the errors listed in the response, all of them are counted
+	MaxHistoryImportErrors = 100
+)
+
+type HistoryImportRequest struct {
+	Format     track.Format
+	Tags       []string
+	ProviderID *int64
+}
+
+type HistoryImportResponse struct {
+	Imported int `json:"imported"`
+	// points with the timestamp of an existing gps history, also repeated points of the file
+	Skipped int      `json:"skipped"`
+	Failed  int      `json:"failed"`
+	Errors  []string `json:"errors"`
+}
+
+// StatusCode returns 201 when there was no error, 207 for partial success and 422 when nothing was imported
+func (r *HistoryImportResponse) StatusCode() int {
+	if r.Failed == 0 {
+		return http.StatusCreated
+	}
+
+	if r.Imported > 0 {
+		return http.StatusMultiStatus
+	}
+
+	return http.StatusUnprocessableEntity
+}
+
+func (r *HistoryImportResponse) addError(message string) {
+	r.Failed++
+	if len(r.Errors) < MaxHistoryImportErrors {
+		r.Errors = append(r.Errors, message)
+	}
+}
+
+// toRequest builds the gps history of the point, registerHistory adds the module tag
+func (r *HistoryImportRequest) toRequest(point *track.Point) CreateLocationEventRequest {
+	timestamp := point.Time
+	tags := make([]string, len(r.Tags))
+	copy(tags, r.Tags)
+
+	return CreateLocationEventRequest{
+		CreateEventRequest: core.CreateEventRequest{EventRequest: core.EventRequest{
+			Type:       core.EventTypeMoment,
+			Timestamp:  &timestamp,
+			Tags:       tags,
+			ProviderID: r.ProviderID,
+		}},
+		Extras: LocationRequest{
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			Accuracy:  point.Accuracy,
+		},
+	}
+}
@@ -3,7 +3,11 @@ package locations
 import (
 	"backend/internal/core"
 	"backend/pkg/handler"
+	"backend/pkg/track"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 )
 
 type LocationHandler struct {
@@ -20,7 +24,10 @@ func (h *LocationHandler) GetRoutes() []handler.Route {
 	return []handler.Route{
 		handler.NewRoute("GET /api/locations/history/{$}", h.ListHistory, handler.RouteOwnerRole),
 		handler.NewRoute("GET /api/locations/history/{id}", h.GetHistory, handler.RouteOwnerRole),
+		handler.NewRoute("GET /api/locations/history/export", h.ExportHistory, handler.RouteOwnerRole),
 		handler.NewRoute("POST /api/locations/history", h.RegisterHistory, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/locations/history/batch", h.RegisterHistoryBatch, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/locations/history/import", h.ImportHistory, handler.RouteProviderRole),
 		handler.NewRoute("PUT /api/locations/history/{id}", h.UpdateHistory, handler.RouteProviderRole),
 		handler.NewRoute("DELETE /api/locations/history/{id}", h.DeleteHistory, handler.RouteProviderRole),
 	}
@@ -34,7 +41,7 @@ func (h *LocationHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.service.ListHistory(query)
+	data, err := h.service.ListHistory(r.Context(), query)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -50,7 +57,7 @@ func (h *LocationHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.service.GetHistory(id)
+	data, err := h.service.GetHistory(r.Context(), id, core.VisibilityFromRequest(r))
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -79,8 +86,9 @@ func (h *LocationHandler) RegisterHistory(w http.ResponseWriter, r *http.Request
 	}
 
 	data.ProviderID = claims.ProviderID
+	data.SetIdempotencyKey(r)
 
-	result, err := h.service.RegisterHistory(&data)
+	result, err := h.service.RegisterHistory(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -89,6 +97,142 @@ func (h *LocationHandler) RegisterHistory(w http.ResponseWriter, r *http.Request
 	h.SendJSON(w, http.StatusCreated, result)
 }
 
+func (h *LocationHandler) RegisterHistoryBatch(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	data, atomic, err := core.BatchFromRequest[CreateLocationEventRequest](r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i := range data {
+		data[i].ProviderID = claims.ProviderID
+		data[i].SetBatchIdempotencyKey(r, i)
+	}
+
+	result, err := h.service.RegisterHistoryBatch(r.Context(), data, atomic)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, result.StatusCode(), result)
+}
+
+// ExportHistory streams the gps history matched by the usual filters as a GPX, KML or GeoJSON file, gpx by default.
+// The gap parameter, e.g. "30m", sets the pause after which the track starts a new segment.
+func (h *LocationHandler) ExportHistory(w http.ResponseWriter, r *http.Request) {
+	query := &core.EventQueryBuilder{}
+	err := query.FromRequest(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(query.Search) > 0 {
+		h.SendJSON(w, http.StatusBadRequest, "search is not supported by the export, the track needs the time order")
+		return
+	}
+
+	request := &HistoryExportRequest{
+		Format: track.Format(r.URL.Query().Get("format")),
+		Gap:    DefaultHistoryExportGap,
+	}
+
+	switch request.Format {
+	case "":
+		request.Format = track.FormatGPX
+	case track.FormatGPX, track.FormatKML, track.FormatGeoJSON:
+	default:
+		h.SendJSON(w, http.StatusBadRequest, track.ErrUnsupportedFormat.Error())
+		return
+	}
+
+	if r.URL.Query().Has("gap") {
+		request.Gap, err = time.ParseDuration(r.URL.Query().Get("gap"))
+		if err != nil || request.Gap <= 0 {
+			h.SendJSON(w, http.StatusBadRequest, "invalid gap")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", track.ContentType(request.Format))
+	w.Header().Set("Content-Disposition", `attachment; filename="location-history.`+string(request.Format)+`"`)
+
+	// the status is sent with the first bytes, later errors can only end the stream
+	output := &exportWriter{ResponseWriter: w}
+	err = h.service.ExportHistory(r.Context(), query, request, output)
+	if err != nil && !output.written {
+		w.Header().Del("Content-Disposition")
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err != nil {
+		fmt.Println("LocationHandler.ExportHistory:", err)
+	}
+}
+
+// exportWriter tells whether the response was started
+type exportWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (e *exportWriter) Write(data []byte) (int, error) {
+	e.written = true
+	return e.ResponseWriter.Write(data)
+}
+
+// ImportHistory reads a GPX, KML or GeoJSON file from the body, the format comes from the format parameter or
+// the Content-Type header. The tags parameter lists tags added to every point.
+func (h *LocationHandler) ImportHistory(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	format := track.Format(r.URL.Query().Get("format"))
+	if len(format) == 0 {
+		var ok bool
+		format, ok = track.FormatFromContentType(r.Header.Get("Content-Type"))
+		if !ok {
+			h.SendJSON(w, http.StatusUnsupportedMediaType, track.ErrUnsupportedFormat.Error())
+			return
+		}
+	}
+
+	switch format {
+	case track.FormatGPX, track.FormatKML, track.FormatGeoJSON:
+	default:
+		h.SendJSON(w, http.StatusBadRequest, track.ErrUnsupportedFormat.Error())
+		return
+	}
+
+	request := &HistoryImportRequest{
+		Format:     format,
+		Tags:       []string{},
+		ProviderID: claims.ProviderID,
+	}
+	if r.URL.Query().Has("tags") {
+		request.Tags = strings.Split(r.URL.Query().Get("tags"), ",")
+	}
+
+	result, err := h.service.ImportHistory(r.Context(), request, r.Body)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, result.StatusCode(), result)
+}
+
 func (h *LocationHandler) UpdateHistory(w http.ResponseWriter, r *http.Request) {
 	claims, err := h.GetClaimsFromContext(r)
 	if err != nil {
@@ -111,7 +255,7 @@ func (h *LocationHandler) UpdateHistory(w http.ResponseWriter, r *http.Request)
 	data.ID = id
 	data.ProviderID = claims.ProviderID
 
-	result, err := h.service.UpdateHistory(&data)
+	result, err := h.service.UpdateHistory(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -127,7 +271,7 @@ func (h *LocationHandler) DeleteHistory(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = h.service.DeleteHistory(id)
+	err = h.service.DeleteHistory(r.Context(), id)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -2,9 +2,12 @@ package locations
 
 import (
 	"backend/internal/core"
+	"backend/internal/db"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -14,60 +17,162 @@ const LocationGPSHistoryTable string = "locations_history"
 const LocationPlacesTable string = "locations_places"
 
 type LocationRepository struct {
-	db *pgxpool.Pool
+	db db.DBTX
 }
 
 func NewLocationRepository(db *pgxpool.Pool) *LocationRepository {
 	return &LocationRepository{db}
 }
 
-func (r *LocationRepository) ListHistory(queryBuilder *core.EventQueryBuilder) ([]LocationEvent, error) {
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *LocationRepository) WithTx(tx pgx.Tx) *LocationRepository {
+	return &LocationRepository{tx}
+}
+
+// NewLocationExtras exposes the gps history of events to core, see core.EventExtras
+func NewLocationExtras(conn db.DBTX) core.EventExtras {
+	return &LocationRepository{conn}
+}
+
+func (r *LocationRepository) GetExtras(ctx context.Context, eventID int64) (json.RawMessage, error) {
+	var data LocationResponse
+	err := r.db.QueryRow(ctx, `
+		SELECT latitude, longitude, accuracy
+		FROM locations_history
+		WHERE event_id = $1
+	`, eventID).Scan(&data.Latitude, &data.Longitude, &data.Accuracy)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
+
+func (r *LocationRepository) ListExtras(ctx context.Context, eventIDs []int64) (map[int64]json.RawMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT event_id, latitude, longitude, accuracy
+		FROM locations_history
+		WHERE event_id = ANY($1)
+	`, eventIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[int64]json.RawMessage)
+	for rows.Next() {
+		var eventID int64
+		var data LocationResponse
+		err = rows.Scan(&eventID, &data.Latitude, &data.Longitude, &data.Accuracy)
+		if err != nil {
+			return nil, err
+		}
+
+		result[eventID], err = json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *LocationRepository) RestoreExtras(ctx context.Context, eventID int64, data json.RawMessage) error {
+	var location LocationRequest
+	err := json.Unmarshal(data, &location)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO locations_history (latitude, longitude, accuracy, event_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (event_id) DO UPDATE
+		SET latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			accuracy = EXCLUDED.accuracy
+	`, location.Latitude, location.Longitude, location.Accuracy, eventID)
+
+	return err
+}
+
+func (r *LocationRepository) ListHistory(ctx context.Context, queryBuilder *core.EventQueryBuilder) ([]LocationEvent, error) {
+	history := make([]LocationEvent, 0)
+	err := r.StreamHistory(ctx, queryBuilder, func(location *LocationEvent) error {
+		history = append(history, *location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return history, nil
+}
+
+// StreamHistory calls fn for every row as it is read, without holding the result in memory.
+// An error of fn stops the query and is returned.
+func (r *LocationRepository) StreamHistory(ctx context.Context, queryBuilder *core.EventQueryBuilder, fn func(location *LocationEvent) error) error {
 	where, params := queryBuilder.Build()
 	query := fmt.Sprintf(`
 		SELECT
-		    events.id as e_id, type, timestamp, until, tags, note, reference,
-			event_id, latitude, longitude, accuracy
+		    events.id as e_id, type, timestamp, until, tags, note, reference, timezone,
+			event_id, latitude, longitude, accuracy,
+			%s
 		FROM locations_history
 		INNER JOIN events ON locations_history.event_id = events.id
 		%s
-		ORDER BY timestamp ASC
-	`, where)
+		%s
+	`, queryBuilder.BuildColumns(), where, queryBuilder.BuildOrder())
 
-	rows, err := r.db.Query(context.Background(), query, params...)
+	rows, err := r.db.Query(ctx, query, params...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
 
-	history := make([]LocationEvent, 0)
 	for rows.Next() {
 		location := LocationEvent{}
 
 		err := rows.Scan(
-			&location.ID, &location.Type, &location.Timestamp, &location.Until, &location.Tags, &location.Note, &location.Reference,
+			&location.ID, &location.Type, &location.Timestamp, &location.Until, &location.Tags, &location.Note, &location.Reference, &location.Timezone,
 			&location.Extras.EventID, &location.Extras.Latitude, &location.Extras.Longitude, &location.Extras.Accuracy,
+			&location.Rank, &location.Snippet,
 		)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		history = append(history, location)
+		err = fn(&location)
+		if err != nil {
+			return err
+		}
 	}
 
-	return history, nil
+	return rows.Err()
 }
 
-func (r *LocationRepository) GetHistory(eventId int64) (*LocationEvent, error) {
+func (r *LocationRepository) GetHistory(ctx context.Context, eventId int64, visibility core.Visibility) (*LocationEvent, error) {
+	where := "WHERE events.id = $1"
+	if condition := visibility.EventCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
 	var data LocationEvent
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
-		    events.id as e_id, type, timestamp, until, tags, note, reference,
+		    events.id as e_id, type, timestamp, until, tags, note, reference, timezone,
 			event_id, latitude, longitude, accuracy
 		FROM locations_history
 		INNER JOIN events ON locations_history.event_id = events.id
-		WHERE events.id = $1
-	`, eventId).Scan(
-		&data.ID, &data.Type, &data.Timestamp, &data.Until, &data.Tags, &data.Note, &data.Reference,
+		`+where, eventId).Scan(
+		&data.ID, &data.Type, &data.Timestamp, &data.Until, &data.Tags, &data.Note, &data.Reference, &data.Timezone,
 		&data.Extras.EventID, &data.Extras.Latitude, &data.Extras.Longitude, &data.Extras.Accuracy,
 	)
 
@@ -82,34 +187,103 @@ func (r *LocationRepository) GetHistory(eventId int64) (*LocationEvent, error) {
 	return &data, nil
 }
 
-func (r *LocationRepository) CreateHistory(history *Location) (*Location, error) {
-	var result Location
-	err := r.db.QueryRow(context.Background(), `
-		INSERT INTO locations_history (latitude, longitude, accuracy, event_id)
-		VALUES ($1, $2, $3, $4)
-		RETURNING latitude, longitude, accuracy, event_id
-	`, history.Latitude, history.Longitude, history.Accuracy, history.EventID).Scan(
-		&result.Latitude,
-		&result.Longitude,
-		&result.Accuracy,
-		&result.EventID,
+// GetHistoryAround returns the last point at or before the time and the first one after it, each within the window.
+// Points of events in the trash are ignored, missing points are nil.
+func (r *LocationRepository) GetHistoryAround(ctx context.Context, timestamp time.Time, window time.Duration) (*LocationEvent, *LocationEvent, error) {
+	before, err := r.getHistoryNear(ctx, "events.timestamp <= $1 AND events.timestamp >= $2", "DESC", timestamp, timestamp.Add(-window))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := r.getHistoryNear(ctx, "events.timestamp > $1 AND events.timestamp <= $2", "ASC", timestamp, timestamp.Add(window))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return before, after, nil
+}
+
+func (r *LocationRepository) getHistoryNear(ctx context.Context, condition, order string, timestamp, bound time.Time) (*LocationEvent, error) {
+	var data LocationEvent
+	err := r.db.QueryRow(ctx, `
+		SELECT
+		    events.id as e_id, type, timestamp, until, tags, note, reference, timezone,
+			event_id, latitude, longitude, accuracy
+		FROM locations_history
+		INNER JOIN events ON locations_history.event_id = events.id
+		WHERE events.deleted_at IS NULL AND `+condition+`
+		ORDER BY events.timestamp `+order+`
+		LIMIT 1
+	`, timestamp, bound).Scan(
+		&data.ID, &data.Type, &data.Timestamp, &data.Until, &data.Tags, &data.Note, &data.Reference, &data.Timezone,
+		&data.Extras.EventID, &data.Extras.Latitude, &data.Extras.Longitude, &data.Extras.Accuracy,
 	)
+
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
-	return &result, nil
+	return &data, nil
 }
 
-func (r *LocationRepository) UpdateHistory(history *Location) (*Location, error) {
+// ListHistoryTimestamps returns which of the timestamps already have gps history outside the trash
+func (r *LocationRepository) ListHistoryTimestamps(ctx context.Context, timestamps []time.Time) ([]time.Time, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT DISTINCT events.timestamp
+		FROM locations_history
+		INNER JOIN events ON locations_history.event_id = events.id
+		WHERE events.deleted_at IS NULL AND events.timestamp = ANY($1)
+	`, timestamps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]time.Time, 0)
+	for rows.Next() {
+		var timestamp time.Time
+		err = rows.Scan(&timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, timestamp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CreateHistory inserts the gps history of the events with one COPY
+func (r *LocationRepository) CreateHistory(ctx context.Context, history []*Location) error {
+	if len(history) == 0 {
+		return nil
+	}
+
+	_, err := r.db.CopyFrom(ctx, pgx.Identifier{"locations_history"}, []string{"latitude", "longitude", "accuracy", "event_id"},
+		pgx.CopyFromSlice(len(history), func(i int) ([]any, error) {
+			return []any{history[i].Latitude, history[i].Longitude, history[i].Accuracy, history[i].EventID}, nil
+		}))
+
+	return err
+}
+
+func (r *LocationRepository) UpdateHistory(ctx context.Context, history *Location) (*Location, error) {
 	var result Location
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		UPDATE locations_history
 		SET latitude = $1,
 			longitude = $2,
 			accuracy = $3
 		WHERE event_id = $4
-		RETURNING id, latitude, longitude, accuracy, event_id
+		RETURNING latitude, longitude, accuracy, event_id
 	`, history.Latitude, history.Longitude, history.Accuracy, history.EventID).Scan(
 		&result.Latitude,
 		&result.Longitude,
@@ -123,12 +297,13 @@ func (r *LocationRepository) UpdateHistory(history *Location) (*Location, error)
 	return &result, nil
 }
 
-func (r *LocationRepository) DeleteHistory(event_id int64) error {
-	// NOTE: this function is actually not necessary because the event can be deleted directly and history will be deleted thanks to the db constraint
-	cmd, err := r.db.Exec(context.Background(), `
-		DELETE FROM events
-		USING locations_history
-		WHERE events.id = locations_history.event_id AND locations_history.event_id = $1
+func (r *LocationRepository) DeleteHistory(ctx context.Context, event_id int64) error {
+	// the event is moved to the trash, history is deleted thanks to the db constraint once the event is purged
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET deleted_at = CURRENT_TIMESTAMP
+		FROM locations_history
+		WHERE events.id = locations_history.event_id AND locations_history.event_id = $1 AND events.deleted_at IS NULL
 	`, event_id)
 	if err != nil {
 		return err
@@ -2,24 +2,40 @@ package locations
 
 import (
 	"backend/internal/core"
+	"backend/internal/db"
+	"backend/pkg/track"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 type LocationService struct {
+	txManager    *db.TxManager
 	locationRepo *LocationRepository
+	placeRepo    *PlaceRepository
 	eventRepo    *core.EventRepository
+	journal      *core.EventJournal
+	rules        *core.RuleEngine
 }
 
-func NewLocationService(locationRepo *LocationRepository, eventRepo *core.EventRepository) *LocationService {
+func NewLocationService(txManager *db.TxManager, locationRepo *LocationRepository, placeRepo *PlaceRepository, eventRepo *core.EventRepository, journal *core.EventJournal, rules *core.RuleEngine) *LocationService {
 	return &LocationService{
+		txManager:    txManager,
 		locationRepo: locationRepo,
+		placeRepo:    placeRepo,
 		eventRepo:    eventRepo,
+		journal:      journal,
+		rules:        rules,
 	}
 }
 
-func (s *LocationService) ListHistory(query *core.EventQueryBuilder) ([]LocationEventResponse, error) {
-	data, err := s.locationRepo.ListHistory(query)
+func (s *LocationService) ListHistory(ctx context.Context, query *core.EventQueryBuilder) (*core.EventPage[LocationEventResponse], error) {
+	data, err := s.locationRepo.ListHistory(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -32,53 +48,368 @@ func (s *LocationService) ListHistory(query *core.EventQueryBuilder) ([]Location
 		}
 	}
 
-	return result, nil
+	return core.NewEventPage(result, query, func(e *LocationEventResponse) *core.EventResponse { return &e.EventResponse }), nil
 }
 
-func (s *LocationService) GetHistory(id int64) (*LocationEventResponse, error) {
-	data, err := s.locationRepo.GetHistory(id)
+func (s *LocationService) GetHistory(ctx context.Context, id int64, visibility core.Visibility) (*LocationEventResponse, error) {
+	data, err := s.locationRepo.GetHistory(ctx, id, visibility)
 	if err != nil {
 		return nil, fmt.Errorf("LocationService.GetHistory: failed to retrieve LocationEvent, %v", err)
 	}
 
+	if data == nil {
+		return nil, nil
+	}
+
 	return &LocationEventResponse{
 		EventResponse: *data.ToEventResponse(),
 		Extras:        *data.Extras.ToLocationResponse(),
 	}, nil
 }
 
-func (s *LocationService) RegisterHistory(request *CreateLocationEventRequest) (*LocationEventResponse, error) {
+func (s *LocationService) RegisterHistory(ctx context.Context, request *CreateLocationEventRequest) (*LocationEventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("LocationService.RegisterHistory: validation failed, %v", err)
 	}
 
+	var result *LocationEventResponse
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		result, err = s.registerHistory(ctx, tx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RegisterHistoryBatch creates the gps history in one transaction and reports the result of every item, see core.RunBatch
+func (s *LocationService) RegisterHistoryBatch(ctx context.Context, requests []CreateLocationEventRequest, atomic bool) (*core.BatchResponse[LocationEventResponse], error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.RunBatch(ctx, s.txManager, requests, atomic, func(request *CreateLocationEventRequest) error {
+		err := request.Validate()
+		if err != nil {
+			return fmt.Errorf("LocationService.RegisterHistoryBatch: validation failed, %v", err)
+		}
+
+		return prepareHistory(rules, request)
+	}, func(tx pgx.Tx, requests []*CreateLocationEventRequest) ([]*LocationEventResponse, error) {
+		return s.registerHistories(ctx, tx, rules, requests)
+	})
+}
+
+// ExportHistory writes the places as waypoints and the gps history matched by the query as a track, oldest first.
+// The history is streamed from the database, a segment ends where the time to the next point exceeds request.Gap.
+func (s *LocationService) ExportHistory(ctx context.Context, query *core.EventQueryBuilder, request *HistoryExportRequest, w io.Writer) error {
+	places, err := s.placeRepo.ListPlaces(ctx)
+	if err != nil {
+		return fmt.Errorf("LocationService.ExportHistory: failed to retrieve places, %v", err)
+	}
+
+	writer, err := track.NewWriter(request.Format, w, HistoryExportCreator, HistoryExportName)
+	if err != nil {
+		return fmt.Errorf("LocationService.ExportHistory: %v", err)
+	}
+
+	// the writer is closed also after an error, it releases its temporary file
+	err = s.writeHistory(ctx, query, request, places, writer)
+	closeErr := writer.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("LocationService.ExportHistory: %v", err)
+	}
+
+	return nil
+}
+
+func (s *LocationService) writeHistory(ctx context.Context, query *core.EventQueryBuilder, request *HistoryExportRequest, places []Place, writer track.Writer) error {
+	for _, place := range places {
+		err := writer.WriteWaypoint(&track.Waypoint{
+			Name:        place.Name,
+			Description: place.Note,
+			Latitude:    place.Latitude,
+			Longitude:   place.Longitude,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// the whole history in time order
+	query.Limit = 0
+	query.Cursor = nil
+	query.Order = core.SortOrderAsc
+
+	segmenter := track.NewSegmenter(writer, request.Gap)
+	err := s.locationRepo.StreamHistory(ctx, query, func(location *LocationEvent) error {
+		if location.Timestamp == nil {
+			return nil
+		}
+
+		return segmenter.Add(track.Point{
+			Time:      *location.Timestamp,
+			Latitude:  location.Extras.Latitude,
+			Longitude: location.Extras.Longitude,
+			Accuracy:  location.Extras.Accuracy,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return segmenter.Flush()
+}
+
+// ImportHistory streams the points of a track file into gps history, in chunks of historyImportChunkSize.
+// Points with the timestamp of existing gps history are skipped, invalid points are reported and skipped as well.
+// A malformed file stops the import, the points of the chunks before stay imported.
+func (s *LocationService) ImportHistory(ctx context.Context, request *HistoryImportRequest, body io.Reader) (*HistoryImportResponse, error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &HistoryImportResponse{Errors: make([]string, 0)}
+	chunk := make([]*track.Point, 0, historyImportChunkSize)
+	// timestamps of the file seen so far, in microseconds like the database
+	seen := make(map[int64]bool)
+
+	err = track.Read(request.Format, body, func(point *track.Point, err error) error {
+		if err != nil {
+			result.addError(err.Error())
+			return nil
+		}
+
+		point.Time = point.Time.Truncate(time.Microsecond)
+		if seen[point.Time.UnixMicro()] {
+			result.Skipped++
+			return nil
+		}
+		seen[point.Time.UnixMicro()] = true
+
+		chunk = append(chunk, point)
+		if len(chunk) < historyImportChunkSize {
+			return nil
+		}
+
+		err = s.importChunk(ctx, rules, request, chunk, result)
+		chunk = chunk[:0]
+		return err
+	})
+
+	if errors.Is(err, track.ErrMalformed) {
+		// the points read so far are still imported
+		result.addError(err.Error())
+	} else if err != nil {
+		return nil, fmt.Errorf("LocationService.ImportHistory: %v", err)
+	}
+
+	err = s.importChunk(ctx, rules, request, chunk, result)
+	if err != nil {
+		return nil, fmt.Errorf("LocationService.ImportHistory: %v", err)
+	}
+
+	return result, nil
+}
+
+func (s *LocationService) importChunk(ctx context.Context, rules core.RuleSet, request *HistoryImportRequest, chunk []*track.Point, result *HistoryImportResponse) error {
+	if len(chunk) == 0 {
+		return nil
+	}
+
+	timestamps := make([]time.Time, len(chunk))
+	for i, point := range chunk {
+		timestamps[i] = point.Time
+	}
+
+	existing, err := s.locationRepo.ListHistoryTimestamps(ctx, timestamps)
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[int64]bool, len(existing))
+	for _, timestamp := range existing {
+		skip[timestamp.UnixMicro()] = true
+	}
+
+	requests := make([]CreateLocationEventRequest, 0, len(chunk))
+	for _, point := range chunk {
+		if skip[point.Time.UnixMicro()] {
+			result.Skipped++
+			continue
+		}
+
+		requests = append(requests, request.toRequest(point))
+	}
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	batch, err := core.RunBatch(ctx, s.txManager, requests, false, func(request *CreateLocationEventRequest) error {
+		err := request.Validate()
+		if err != nil {
+			return err
+		}
+
+		return prepareHistory(rules, request)
+	}, func(tx pgx.Tx, requests []*CreateLocationEventRequest) ([]*LocationEventResponse, error) {
+		return s.registerHistories(ctx, tx, rules, requests)
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Imported += batch.Created
+	for _, item := range batch.Items {
+		if item.Status == core.BatchItemFailed {
+			result.addError(fmt.Sprintf("point at %s: %s", requests[item.Index].Timestamp.Format(time.RFC3339), item.Error))
+		}
+	}
+
+	return nil
+}
+
+func (s *LocationService) registerHistory(ctx context.Context, tx pgx.Tx, request *CreateLocationEventRequest) (*LocationEventResponse, error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = prepareHistory(rules, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.registerHistories(ctx, tx, rules, []*CreateLocationEventRequest{request})
+	if err != nil {
+		return nil, err
+	}
+
+	return result[0], nil
+}
+
+// prepareHistory marks the request as gps history and adds the tags of the matching rules
+func prepareHistory(rules core.RuleSet, request *CreateLocationEventRequest) error {
 	request.Reference = LocationGPSHistoryTable
 	request.Tags = append(request.Tags, "module:locations")
 
-	event, err := s.eventRepo.CreateEvent(request.CreateEventRequest.ToEvent())
+	extras, err := json.Marshal(&request.Extras)
 	if err != nil {
-		return nil, errors.New("LocationService.RegisterHistory: failed to create event\n" + err.Error())
+		return fmt.Errorf("LocationService.prepareHistory: %v", err)
 	}
 
-	// create gps history
-	history, err := s.locationRepo.CreateHistory(&Location{
-		Latitude:  request.Extras.Latitude,
-		Longitude: request.Extras.Longitude,
-		Accuracy:  request.Extras.Accuracy,
-		EventID:   event.ID,
-	})
+	rules.Apply(&request.EventRequest, extras)
+	return nil
+}
+
+// registerHistories creates the events of the requests prepared by prepareHistory, their gps history
+// and the events derived by the rules, the statements of all requests are sent at once
+func (s *LocationService) registerHistories(ctx context.Context, tx pgx.Tx, rules core.RuleSet, requests []*CreateLocationEventRequest) ([]*LocationEventResponse, error) {
+	events := make([]*core.Event, len(requests))
+	for i, request := range requests {
+		events[i] = request.CreateEventRequest.ToEvent()
+	}
+
+	events, err := s.eventRepo.WithTx(tx).CreateEvents(ctx, events)
 	if err != nil {
-		return nil, errors.New("LocationService.RegisterHistory: failed to create gps history\n" + err.Error())
+		return nil, fmt.Errorf("LocationService.RegisterHistory: failed to create event, %w", err)
+	}
+
+	result := make([]*LocationEventResponse, len(requests))
+	history := make([]*Location, 0, len(requests))
+	created := make([]*core.EventSnapshot, 0, len(requests))
+	for i, event := range events {
+		if event == nil {
+			// retried submission, return the original event
+			result[i], err = s.getHistoryByClientID(ctx, tx, requests[i].ProviderID, *requests[i].ClientID)
+			if err != nil {
+				return nil, &core.BatchItemError{Index: i, Err: err}
+			}
+			continue
+		}
+
+		location := &Location{
+			Latitude:  requests[i].Extras.Latitude,
+			Longitude: requests[i].Extras.Longitude,
+			Accuracy:  requests[i].Extras.Accuracy,
+			EventID:   event.ID,
+		}
+		history = append(history, location)
+
+		result[i] = &LocationEventResponse{
+			EventResponse: *event.ToEventResponse(),
+			Extras:        *location.ToLocationResponse(),
+		}
+
+		extras, err := json.Marshal(&result[i].Extras)
+		if err != nil {
+			return nil, fmt.Errorf("LocationService.RegisterHistory: %v", err)
+		}
+		created = append(created, &core.EventSnapshot{EventResponse: result[i].EventResponse, Extras: extras})
+	}
+
+	err = s.locationRepo.WithTx(tx).CreateHistory(ctx, history)
+	if err != nil {
+		return nil, fmt.Errorf("LocationService.RegisterHistory: failed to create gps history, %v", err)
+	}
+
+	err = s.journal.RecordCreated(ctx, tx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.rules.Derive(ctx, tx, rules, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *LocationService) applyRules(ctx context.Context, tx pgx.Tx, request *core.EventRequest, extras *LocationRequest) error {
+	data, err := json.Marshal(extras)
+	if err != nil {
+		return fmt.Errorf("LocationService.applyRules: %v", err)
+	}
+
+	return s.rules.Apply(ctx, tx, request, data)
+}
+
+func (s *LocationService) getHistoryByClientID(ctx context.Context, tx pgx.Tx, providerID *int64, clientID string) (*LocationEventResponse, error) {
+	event, err := s.eventRepo.WithTx(tx).GetEventByClientID(ctx, providerID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("LocationService.getHistoryByClientID: %v", err)
+	}
+
+	if event == nil || event.Reference != LocationGPSHistoryTable {
+		return nil, fmt.Errorf("LocationService.getHistoryByClientID: client id %s is used by another event", clientID)
+	}
+
+	data, err := s.locationRepo.WithTx(tx).GetHistory(ctx, event.ID, core.FullVisibility)
+	if err != nil {
+		return nil, fmt.Errorf("LocationService.getHistoryByClientID: %v", err)
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("LocationService.getHistoryByClientID: missing gps history of event %d", event.ID)
 	}
 
 	return &LocationEventResponse{
 		EventResponse: *event.ToEventResponse(),
-		Extras:        *history.ToLocationResponse(),
+		Extras:        *data.Extras.ToLocationResponse(),
 	}, nil
 }
 
-func (s *LocationService) UpdateHistory(request *UpdateLocationEventRequest) (*LocationEventResponse, error) {
+func (s *LocationService) UpdateHistory(ctx context.Context, request *UpdateLocationEventRequest) (*LocationEventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("LocationService.UpdateHistory: validation failed, %v", err)
@@ -86,21 +417,40 @@ func (s *LocationService) UpdateHistory(request *UpdateLocationEventRequest) (*L
 
 	request.Reference = LocationGPSHistoryTable
 
-	// update event
-	event, err := s.eventRepo.UpdateEvent(request.UpdateEventRequest.ToEvent())
-	if err != nil {
-		return nil, errors.New("LocationService.UpdateHistory: failed to update event\n" + err.Error())
-	}
+	var event *core.Event
+	var history *Location
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, request.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.applyRules(ctx, tx, &request.EventRequest, &request.Extras)
+		if err != nil {
+			return err
+		}
 
-	// update gps history
-	history, err := s.locationRepo.UpdateHistory(&Location{
-		Latitude:  request.Extras.Latitude,
-		Longitude: request.Extras.Longitude,
-		Accuracy:  request.Extras.Accuracy,
-		EventID:   event.ID,
+		// update event
+		event, err = s.eventRepo.WithTx(tx).UpdateEvent(ctx, request.UpdateEventRequest.ToEvent())
+		if err != nil {
+			return errors.New("LocationService.UpdateHistory: failed to update event\n" + err.Error())
+		}
+
+		// update gps history
+		history, err = s.locationRepo.WithTx(tx).UpdateHistory(ctx, &Location{
+			Latitude:  request.Extras.Latitude,
+			Longitude: request.Extras.Longitude,
+			Accuracy:  request.Extras.Accuracy,
+			EventID:   event.ID,
+		})
+		if err != nil {
+			return errors.New("LocationService.UpdateHistory: failed to update location\n" + err.Error())
+		}
+
+		return s.journal.Record(ctx, tx, core.RevisionActionUpdate, event.ID, previous)
 	})
 	if err != nil {
-		return nil, errors.New("LocationService.UpdateHistory: failed to update location\n" + err.Error())
+		return nil, err
 	}
 
 	return &LocationEventResponse{
@@ -109,6 +459,18 @@ func (s *LocationService) UpdateHistory(request *UpdateLocationEventRequest) (*L
 	}, nil
 }
 
-func (s *LocationService) DeleteHistory(id int64) error {
-	return s.locationRepo.DeleteHistory(id)
+func (s *LocationService) DeleteHistory(ctx context.Context, id int64) error {
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		err = s.locationRepo.WithTx(tx).DeleteHistory(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		return s.journal.Record(ctx, tx, core.RevisionActionDelete, id, previous)
+	})
 }
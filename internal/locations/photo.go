@@ -0,0 +1,71 @@
+package locations
+
+import (
+	"backend/internal/core"
+	"errors"
+	"math"
+	"time"
+)
+
+// PhotoTag is added to the events created from photos
+const PhotoTag = "photo"
+
+// PhotoInterpolationWindow bounds how far in time the gps history used for photos without GPS tags may be
+const PhotoInterpolationWindow = time.Hour
+
+var (
+	ErrPhotoType = errors.New("photo must be a JPEG or HEIC file")
+	ErrPhotoTime = errors.New("photo has no DateTimeOriginal tag")
+)
+
+var photoContentTypes = []string{"image/jpeg", "image/heic", "image/heif"}
+
+// PhotoRequest describes a single file of a photo import
+type PhotoRequest struct {
+	Filename    string
+	ContentType string
+	ProviderID  *int64
+	// visibility of the caller, a duplicate of an event they may not see is skipped without the event
+	Visibility core.Visibility
+	// timezone of the photos without OffsetTimeOriginal, also stored on the event when set
+	Timezone *time.Location
+}
+
+type PhotoResponse struct {
+	Filename string `json:"filename"`
+	// moment created for the photo, or the one of the same photo imported before when skipped
+	Event      *core.EventResponse      `json:"event,omitempty"`
+	Attachment *core.AttachmentResponse `json:"attachment,omitempty"`
+	// nil when the photo has no GPS tags and no gps history is close enough to its time
+	Location *LocationResponse `json:"location,omitempty"`
+	// the location was derived from the gps history around the time of the photo
+	Interpolated bool `json:"interpolated,omitempty"`
+}
+
+// interpolateLocation places the photo on the line between the history points by time,
+// a single point is used as is. The accuracy is the worse one of the two points.
+func interpolateLocation(timestamp time.Time, before, after *LocationEvent) *LocationRequest {
+	if before == nil && after == nil {
+		return nil
+	}
+
+	if before == nil {
+		before = after
+	}
+
+	if after == nil {
+		after = before
+	}
+
+	ratio := 0.0
+	span := after.Timestamp.Sub(*before.Timestamp)
+	if span > 0 {
+		ratio = float64(timestamp.Sub(*before.Timestamp)) / float64(span)
+	}
+
+	return &LocationRequest{
+		Latitude:  before.Extras.Latitude + (after.Extras.Latitude-before.Extras.Latitude)*ratio,
+		Longitude: before.Extras.Longitude + (after.Extras.Longitude-before.Extras.Longitude)*ratio,
+		Accuracy:  math.Max(before.Extras.Accuracy, after.Extras.Accuracy),
+	}
+}
@@ -0,0 +1,118 @@
+package locations
+
+import (
+	"backend/internal/core"
+	"backend/pkg/handler"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type PhotoHandler struct {
+	handler.BaseHandler
+
+	service *PhotoService
+}
+
+func NewPhotoHandler(service *PhotoService) *PhotoHandler {
+	return &PhotoHandler{service: service}
+}
+
+func (h *PhotoHandler) GetRoutes() []handler.Route {
+	return []handler.Route{
+		handler.NewRoute("POST /api/locations/photos", h.ImportPhotos, handler.RouteProviderRole),
+	}
+}
+
+// ImportPhotos reads every "file" part of a multipart/form-data body, the parts are streamed and not buffered in memory.
+// Every photo is imported on its own, duplicates are reported as skipped. The parts after MaxBatchSize photos are
+// not read, the first of them is reported as failed. The optional tz parameter is the timezone of the photos
+// whose EXIF data has no offset, UTC by default.
+func (h *PhotoHandler) ImportPhotos(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	var timezone *time.Location
+	if name := r.URL.Query().Get("tz"); len(name) > 0 {
+		timezone, err = core.LoadLocation(name)
+		if err != nil {
+			h.SendJSON(w, http.StatusBadRequest, "invalid timezone "+name)
+			return
+		}
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &core.BatchResponse[PhotoResponse]{Items: make([]core.BatchItemResult[PhotoResponse], 0)}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			h.SendJSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		request := &PhotoRequest{
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			ProviderID:  claims.ProviderID,
+			Visibility:  core.NewVisibility(claims, false),
+			Timezone:    timezone,
+		}
+
+		// the photos before are committed, their results are returned and the rest of the body is not read
+		if len(response.Items) == core.MaxBatchSize {
+			part.Close()
+			response.Items = append(response.Items, core.BatchItemResult[PhotoResponse]{
+				Index:  len(response.Items),
+				Status: core.BatchItemFailed,
+				Data:   &PhotoResponse{Filename: request.Filename},
+				Error:  fmt.Sprintf("too many photos, at most %d are imported per request", core.MaxBatchSize),
+			})
+			response.Failed++
+			break
+		}
+
+		data, skipped, err := h.service.ImportPhoto(r.Context(), request, part)
+		part.Close()
+
+		item := core.BatchItemResult[PhotoResponse]{Index: len(response.Items), Data: data}
+		switch {
+		case err != nil:
+			item.Status = core.BatchItemFailed
+			item.Data = &PhotoResponse{Filename: request.Filename}
+			item.Error = err.Error()
+			response.Failed++
+		case skipped:
+			item.Status = core.BatchItemSkipped
+		default:
+			item.Status = core.BatchItemCreated
+			response.Created++
+		}
+
+		response.Items = append(response.Items, item)
+	}
+
+	if len(response.Items) == 0 {
+		h.SendJSON(w, http.StatusBadRequest, "missing file part")
+		return
+	}
+
+	h.SendJSON(w, response.StatusCode(), response)
+}
@@ -0,0 +1,202 @@
+package locations
+
+import (
+	"backend/internal/core"
+	"backend/internal/db"
+	"backend/pkg/exif"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type PhotoService struct {
+	txManager       *db.TxManager
+	locationService *LocationService
+	locationRepo    *LocationRepository
+	eventRepo       *core.EventRepository
+	journal         *core.EventJournal
+	rules           *core.RuleEngine
+	attachments     *core.AttachmentService
+}
+
+func NewPhotoService(txManager *db.TxManager, locationService *LocationService, locationRepo *LocationRepository, eventRepo *core.EventRepository, journal *core.EventJournal, rules *core.RuleEngine, attachments *core.AttachmentService) *PhotoService {
+	return &PhotoService{
+		txManager:       txManager,
+		locationService: locationService,
+		locationRepo:    locationRepo,
+		eventRepo:       eventRepo,
+		journal:         journal,
+		rules:           rules,
+		attachments:     attachments,
+	}
+}
+
+// ImportPhoto creates a moment at the time the photo was taken with the photo attached.
+// Photos with a location, from the GPS tags or interpolated from the gps history, become gps history.
+// A photo whose content was imported before is skipped, the result then holds the earlier event when the caller
+// may see it.
+func (s *PhotoService) ImportPhoto(ctx context.Context, request *PhotoRequest, body io.Reader) (*PhotoResponse, bool, error) {
+	upload, err := s.attachments.Spool(body, request.Filename, request.ContentType)
+	if err != nil {
+		return nil, false, err
+	}
+	defer upload.Close()
+
+	result := &PhotoResponse{Filename: upload.Filename}
+
+	if !slices.Contains(photoContentTypes, upload.ContentType) {
+		return nil, false, fmt.Errorf("%w: %s", ErrPhotoType, upload.ContentType)
+	}
+
+	duplicate, err := s.attachments.FindByHash(ctx, upload.SHA256)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if duplicate != nil {
+		return s.duplicate(ctx, result, duplicate, request.Visibility)
+	}
+
+	content, err := upload.Open()
+	if err != nil {
+		return nil, false, fmt.Errorf("PhotoService.ImportPhoto: %v", err)
+	}
+
+	timezone := time.UTC
+	if request.Timezone != nil {
+		timezone = request.Timezone
+	}
+
+	metadata, err := exif.Decode(content, timezone)
+	if errors.Is(err, exif.ErrNotFound) {
+		return nil, false, ErrPhotoTime
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("PhotoService.ImportPhoto: %v", err)
+	}
+
+	if metadata.Taken == nil {
+		return nil, false, ErrPhotoTime
+	}
+
+	var location *LocationRequest
+	if metadata.Latitude != nil && metadata.Longitude != nil {
+		location = &LocationRequest{Latitude: *metadata.Latitude, Longitude: *metadata.Longitude}
+		if metadata.Accuracy != nil {
+			location.Accuracy = *metadata.Accuracy
+		}
+	} else {
+		before, after, err := s.locationRepo.GetHistoryAround(ctx, *metadata.Taken, PhotoInterpolationWindow)
+		if err != nil {
+			return nil, false, fmt.Errorf("PhotoService.ImportPhoto: failed to retrieve gps history, %v", err)
+		}
+
+		location = interpolateLocation(*metadata.Taken, before, after)
+		result.Interpolated = location != nil
+	}
+
+	event := core.CreateEventRequest{EventRequest: core.EventRequest{
+		Type:       core.EventTypeMoment,
+		Timestamp:  metadata.Taken,
+		Tags:       []string{PhotoTag},
+		ProviderID: request.ProviderID,
+	}}
+	if request.Timezone != nil {
+		name := request.Timezone.String()
+		event.Timezone = &name
+	}
+
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		// concurrent uploads of the same photo wait here, the later one finds the attachment of the first
+		duplicate, err = s.attachments.LockHash(ctx, tx, upload.SHA256)
+		if err != nil || duplicate != nil {
+			return err
+		}
+
+		var eventID int64
+		if location != nil {
+			history, err := s.locationService.registerHistory(ctx, tx, &CreateLocationEventRequest{CreateEventRequest: event, Extras: *location})
+			if err != nil {
+				return err
+			}
+
+			result.Event = &history.EventResponse
+			result.Location = &history.Extras
+			eventID = history.ID
+		} else {
+			created, err := s.createEvent(ctx, tx, &event)
+			if err != nil {
+				return err
+			}
+
+			result.Event = created.ToEventResponse()
+			eventID = created.ID
+		}
+
+		attachment, err := s.attachments.Store(ctx, tx, eventID, upload)
+		if err != nil {
+			return err
+		}
+		result.Attachment = attachment.ToAttachmentResponse()
+
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if duplicate != nil {
+		return s.duplicate(ctx, &PhotoResponse{Filename: upload.Filename}, duplicate, request.Visibility)
+	}
+
+	return result, false, nil
+}
+
+// duplicate returns the skipped result of a photo imported before, with its event when the caller may see it
+func (s *PhotoService) duplicate(ctx context.Context, result *PhotoResponse, duplicate *core.Attachment, visibility core.Visibility) (*PhotoResponse, bool, error) {
+	event, err := s.eventRepo.GetEvent(ctx, duplicate.EventID, visibility)
+	if err != nil {
+		return nil, false, fmt.Errorf("PhotoService.duplicate: %v", err)
+	}
+
+	if event != nil {
+		result.Event = event.ToEventResponse()
+		result.Attachment = duplicate.ToAttachmentResponse()
+	}
+
+	return result, true, nil
+}
+
+// createEvent creates the moment of a photo without location, it has no module data
+func (s *PhotoService) createEvent(ctx context.Context, tx pgx.Tx, request *core.CreateEventRequest) (*core.Event, error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules.Apply(&request.EventRequest, nil)
+
+	event, err := s.eventRepo.WithTx(tx).CreateEvent(ctx, request.ToEvent())
+	if err != nil {
+		return nil, fmt.Errorf("PhotoService.createEvent: failed to create event, %v", err)
+	}
+
+	created := []*core.EventSnapshot{{EventResponse: *event.ToEventResponse()}}
+	err = s.journal.RecordCreated(ctx, tx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.rules.Derive(ctx, tx, rules, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
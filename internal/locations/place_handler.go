@@ -26,7 +26,7 @@ func (h *PlaceHandler) GetRoutes() []handler.Route {
 }
 
 func (h *PlaceHandler) ListPlaces(w http.ResponseWriter, r *http.Request) {
-	data, err := h.service.ListPlaces()
+	data, err := h.service.ListPlaces(r.Context())
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -42,7 +42,7 @@ func (h *PlaceHandler) GetPlace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.service.GetPlace(id)
+	data, err := h.service.GetPlace(r.Context(), id)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -64,7 +64,7 @@ func (h *PlaceHandler) CreatePlace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.service.CreatePlace(&data)
+	result, err := h.service.CreatePlace(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -88,7 +88,7 @@ func (h *PlaceHandler) UpdatePlace(w http.ResponseWriter, r *http.Request) {
 
 	data.ID = id
 
-	result, err := h.service.UpdateHistory(&data)
+	result, err := h.service.UpdateHistory(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -104,7 +104,7 @@ func (h *PlaceHandler) DeletePlace(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.DeleteHistory(id)
+	err = h.service.DeleteHistory(r.Context(), id)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -16,8 +16,8 @@ func NewPlaceRepository(db *pgxpool.Pool) *PlaceRepository {
 	return &PlaceRepository{db}
 }
 
-func (r *PlaceRepository) ListPlaces() ([]Place, error) {
-	rows, err := r.db.Query(context.Background(), `
+func (r *PlaceRepository) ListPlaces(ctx context.Context) ([]Place, error) {
+	rows, err := r.db.Query(ctx, `
 		SELECT id, name, note, latitude, longitude, radius, created, updated
 		FROM locations_places
 		ORDER BY created ASC
@@ -39,12 +39,16 @@ func (r *PlaceRepository) ListPlaces() ([]Place, error) {
 		places = append(places, place)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return places, nil
 }
 
-func (r *PlaceRepository) GetPlace(id int64) (*Place, error) {
+func (r *PlaceRepository) GetPlace(ctx context.Context, id int64) (*Place, error) {
 	var data Place
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT id, name, note, latitude, longitude, radius, created, updated
 		FROM locations_places
 		WHERE id = $1
@@ -61,9 +65,9 @@ func (r *PlaceRepository) GetPlace(id int64) (*Place, error) {
 	return &data, nil
 }
 
-func (r *PlaceRepository) CreatePlace(place *Place) (*Place, error) {
+func (r *PlaceRepository) CreatePlace(ctx context.Context, place *Place) (*Place, error) {
 	var result Place
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		INSERT INTO locations_places (name, note, latitude, longitude, radius)
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id, name, note, latitude, longitude, radius, created, updated
@@ -78,9 +82,9 @@ func (r *PlaceRepository) CreatePlace(place *Place) (*Place, error) {
 	return &result, nil
 }
 
-func (r *PlaceRepository) UpdatePlace(place *Place) (*Place, error) {
+func (r *PlaceRepository) UpdatePlace(ctx context.Context, place *Place) (*Place, error) {
 	var result Place
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		UPDATE locations_places
 		SET name = $2,
 			note = $3,
@@ -99,8 +103,8 @@ func (r *PlaceRepository) UpdatePlace(place *Place) (*Place, error) {
 	return &result, nil
 }
 
-func (r *PlaceRepository) DeletePlace(id int64) error {
-	cmd, err := r.db.Exec(context.Background(), `
+func (r *PlaceRepository) DeletePlace(ctx context.Context, id int64) error {
+	cmd, err := r.db.Exec(ctx, `
 		DELETE FROM locations_places
 		WHERE id = $1
 	`, id)
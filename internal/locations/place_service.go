@@ -1,6 +1,7 @@
 package locations
 
 import (
+	"context"
 	"errors"
 )
 
@@ -12,17 +13,17 @@ func NewPlaceService(placeRepo *PlaceRepository) *PlaceService {
 	return &PlaceService{placeRepo}
 }
 
-func (s *PlaceService) ListPlaces() ([]Place, error) {
-	return s.placeRepo.ListPlaces()
+func (s *PlaceService) ListPlaces(ctx context.Context) ([]Place, error) {
+	return s.placeRepo.ListPlaces(ctx)
 }
 
-func (s *PlaceService) GetPlace(id int64) (*Place, error) {
-	return s.placeRepo.GetPlace(id)
+func (s *PlaceService) GetPlace(ctx context.Context, id int64) (*Place, error) {
+	return s.placeRepo.GetPlace(ctx, id)
 }
 
-func (s *PlaceService) CreatePlace(request *CreatePlaceRequest) (*PlaceResponse, error) {
+func (s *PlaceService) CreatePlace(ctx context.Context, request *CreatePlaceRequest) (*PlaceResponse, error) {
 	// create
-	place, err := s.placeRepo.CreatePlace(&Place{
+	place, err := s.placeRepo.CreatePlace(ctx, &Place{
 		Name:      request.Name,
 		Note:      request.Note,
 		Latitude:  request.Latitude,
@@ -45,10 +46,10 @@ func (s *PlaceService) CreatePlace(request *CreatePlaceRequest) (*PlaceResponse,
 	}, nil
 }
 
-func (s *PlaceService) UpdateHistory(data *Place) (*Place, error) {
-	return s.placeRepo.UpdatePlace(data)
+func (s *PlaceService) UpdateHistory(ctx context.Context, data *Place) (*Place, error) {
+	return s.placeRepo.UpdatePlace(ctx, data)
 }
 
-func (s *PlaceService) DeleteHistory(id int64) error {
-	return s.placeRepo.DeletePlace(id)
+func (s *PlaceService) DeleteHistory(ctx context.Context, id int64) error {
+	return s.placeRepo.DeletePlace(ctx, id)
 }
@@ -20,6 +20,7 @@ func (h *RawHandler) GetRoutes() []handler.Route {
 		handler.NewRoute("GET /api/raw/{$}", h.ListRawEvents, handler.RouteOwnerRole),
 		handler.NewRoute("GET /api/raw/{id}", h.GetRawEvent, handler.RouteOwnerRole),
 		handler.NewRoute("POST /api/raw", h.CreateRawEvent, handler.RouteProviderRole),
+		handler.NewRoute("POST /api/raw/batch", h.CreateRawEvents, handler.RouteProviderRole),
 		handler.NewRoute("PUT /api/raw/{id}", h.UpdateRawEvent, handler.RouteOwnerRole),
 		handler.NewRoute("DELETE /api/raw/{id}", h.DeleteRawEvent, handler.RouteOwnerRole),
 	}
@@ -33,7 +34,7 @@ func (h *RawHandler) ListRawEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.service.ListRawEvents(query)
+	data, err := h.service.ListRawEvents(r.Context(), query)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -49,7 +50,7 @@ func (h *RawHandler) GetRawEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.service.GetRawEvent(id)
+	data, err := h.service.GetRawEvent(r.Context(), id, core.VisibilityFromRequest(r))
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -77,8 +78,9 @@ func (h *RawHandler) CreateRawEvent(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data.ProviderID = claims.ProviderID
+	data.SetIdempotencyKey(r)
 
-	result, err := h.service.RegisterRawEvent(&data)
+	result, err := h.service.RegisterRawEvent(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -87,6 +89,33 @@ func (h *RawHandler) CreateRawEvent(w http.ResponseWriter, r *http.Request) {
 	h.SendJSON(w, http.StatusCreated, result)
 }
 
+func (h *RawHandler) CreateRawEvents(w http.ResponseWriter, r *http.Request) {
+	claims, err := h.GetClaimsFromContext(r)
+	if err != nil {
+		h.SendJSON(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	data, atomic, err := core.BatchFromRequest[CreateRawEventRequest](r)
+	if err != nil {
+		h.SendJSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for i := range data {
+		data[i].ProviderID = claims.ProviderID
+		data[i].SetBatchIdempotencyKey(r, i)
+	}
+
+	result, err := h.service.RegisterRawEvents(r.Context(), data, atomic)
+	if err != nil {
+		h.SendJSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.SendJSON(w, result.StatusCode(), result)
+}
+
 func (h *RawHandler) UpdateRawEvent(w http.ResponseWriter, r *http.Request) {
 	claims, err := h.GetClaimsFromContext(r)
 	if err != nil {
@@ -109,7 +138,7 @@ func (h *RawHandler) UpdateRawEvent(w http.ResponseWriter, r *http.Request) {
 	data.ID = id
 	data.ProviderID = claims.ProviderID
 
-	result, err := h.service.UpdateRawEvent(&data)
+	result, err := h.service.UpdateRawEvent(r.Context(), &data)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -125,7 +154,7 @@ func (h *RawHandler) DeleteRawEvent(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.service.DeleteRawEvent(id)
+	err = h.service.DeleteRawEvent(r.Context(), id)
 	if err != nil {
 		h.SendJSON(w, http.StatusInternalServerError, err.Error())
 		return
@@ -2,47 +2,124 @@ package raw
 
 import (
 	"backend/internal/core"
+	"backend/internal/db"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const RawTable string = "raw"
 
 type RawRepository struct {
-	db *pgxpool.Pool
+	db db.DBTX
 }
 
 func NewRawRepository(db *pgxpool.Pool) *RawRepository {
 	return &RawRepository{db}
 }
 
-func (r *RawRepository) ListRawEvents(queryBuilder *core.EventQueryBuilder) ([]RawEvent, error) {
+// WithTx returns a copy of the repository bound to the given transaction
+func (r *RawRepository) WithTx(tx pgx.Tx) *RawRepository {
+	return &RawRepository{tx}
+}
+
+// NewRawExtras exposes the raw data of events to core, see core.EventExtras
+func NewRawExtras(conn db.DBTX) core.EventExtras {
+	return &RawRepository{conn}
+}
+
+func (r *RawRepository) GetExtras(ctx context.Context, eventID int64) (json.RawMessage, error) {
+	var data json.RawMessage
+	err := r.db.QueryRow(ctx, `
+		SELECT data
+		FROM raw
+		WHERE event_id = $1
+	`, eventID).Scan(&data)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("RawRepository.GetExtras: %v", err)
+	}
+
+	return data, nil
+}
+
+func (r *RawRepository) ListExtras(ctx context.Context, eventIDs []int64) (map[int64]json.RawMessage, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT event_id, data
+		FROM raw
+		WHERE event_id = ANY($1)
+	`, eventIDs)
+	if err != nil {
+		return nil, fmt.Errorf("RawRepository.ListExtras: %v", err)
+	}
+	defer rows.Close()
+
+	result := make(map[int64]json.RawMessage)
+	for rows.Next() {
+		var eventID int64
+		var data json.RawMessage
+		err = rows.Scan(&eventID, &data)
+		if err != nil {
+			return nil, fmt.Errorf("RawRepository.ListExtras: %v", err)
+		}
+
+		result[eventID] = data
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("RawRepository.ListExtras: %v", err)
+	}
+
+	return result, nil
+}
+
+func (r *RawRepository) RestoreExtras(ctx context.Context, eventID int64, data json.RawMessage) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO raw (event_id, data)
+		VALUES ($1, $2)
+		ON CONFLICT (event_id) DO UPDATE
+		SET data = EXCLUDED.data
+	`, eventID, data)
+	if err != nil {
+		return fmt.Errorf("RawRepository.RestoreExtras: %v", err)
+	}
+
+	return nil
+}
+
+func (r *RawRepository) ListRawEvents(ctx context.Context, queryBuilder *core.EventQueryBuilder) ([]RawEvent, error) {
 	where, params := queryBuilder.Build()
 	query := fmt.Sprintf(`
 		SELECT
-		    events.id as e_id, type, timestamp, until, tags, note, reference,
-			event_id, data
+		    events.id as e_id, type, timestamp, until, tags, note, reference, timezone,
+			event_id, data,
+			%s
 		FROM raw
 		INNER JOIN events ON raw.event_id = events.id
 		%s
-		ORDER BY timestamp ASC
-	`, where)
+		%s
+	`, queryBuilder.BuildColumns(), where, queryBuilder.BuildOrder())
 
-	rows, err := r.db.Query(context.Background(), query, params...)
+	rows, err := r.db.Query(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("RawRepository.ListRawEvents: %v", err)
 	}
+	defer rows.Close()
 
 	result := make([]RawEvent, 0)
 	for rows.Next() {
 		var data RawEvent
 
 		err := rows.Scan(
-			&data.ID, &data.Type, &data.Timestamp, &data.Until, &data.Tags, &data.Note, &data.Reference,
+			&data.ID, &data.Type, &data.Timestamp, &data.Until, &data.Tags, &data.Note, &data.Reference, &data.Timezone,
 			&data.Extras.EventID, &data.Extras.Data,
+			&data.Rank, &data.Snippet,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("RawRepository.ListRawEvents - failed to parse row: %v", err)
@@ -51,23 +128,33 @@ func (r *RawRepository) ListRawEvents(queryBuilder *core.EventQueryBuilder) ([]R
 		result = append(result, data)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("RawRepository.ListRawEvents: %v", err)
+	}
+
 	return result, nil
 }
 
-func (r *RawRepository) GetRawEvent(eventID int64) (*RawEvent, error) {
+func (r *RawRepository) GetRawEvent(ctx context.Context, eventID int64, visibility core.Visibility) (*RawEvent, error) {
+	where := "WHERE events.id = $1"
+	if condition := visibility.EventCondition(); len(condition) > 0 {
+		where += " AND " + condition
+	}
+
 	result := RawEvent{}
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		SELECT
-  			events.id as e_id, type, timestamp, until, tags, note, reference,
+  			events.id as e_id, type, timestamp, until, tags, note, reference, timezone,
      		event_id, data
 		FROM raw
 		INNER JOIN events ON raw.event_id = events.id
-		WHERE
-		WHERE events.id = $1
-	`, eventID).Scan(
-		&result.ID, &result.Type, &result.Timestamp, &result.Until, &result.Tags, &result.Note, &result.Reference,
+		`+where, eventID).Scan(
+		&result.ID, &result.Type, &result.Timestamp, &result.Until, &result.Tags, &result.Note, &result.Reference, &result.Timezone,
 		&result.Extras.EventID, &result.Extras.Data,
 	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("RawRepository.GetRawEvent: %v", err)
 	}
@@ -75,23 +162,26 @@ func (r *RawRepository) GetRawEvent(eventID int64) (*RawEvent, error) {
 	return &result, nil
 }
 
-func (r *RawRepository) CreateRaw(data *Raw) (*Raw, error) {
-	var result Raw
-	err := r.db.QueryRow(context.Background(), `
-		INSERT INTO raw (event_id, data)
-		VALUES ($1, $2)
-		RETURNING event_id, data
-	`, data.EventID, data.Data).Scan(&result.EventID, &result.Data)
+// CreateRaw inserts the raw data of the events with one COPY
+func (r *RawRepository) CreateRaw(ctx context.Context, data []*Raw) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	_, err := r.db.CopyFrom(ctx, pgx.Identifier{"raw"}, []string{"event_id", "data"},
+		pgx.CopyFromSlice(len(data), func(i int) ([]any, error) {
+			return []any{data[i].EventID, data[i].Data}, nil
+		}))
 	if err != nil {
-		return nil, fmt.Errorf("RawRepository.CreateRaw: %v", err)
+		return fmt.Errorf("RawRepository.CreateRaw: %v", err)
 	}
 
-	return &result, nil
+	return nil
 }
 
-func (r *RawRepository) UpdateRaw(data *Raw) (*Raw, error) {
+func (r *RawRepository) UpdateRaw(ctx context.Context, data *Raw) (*Raw, error) {
 	var result Raw
-	err := r.db.QueryRow(context.Background(), `
+	err := r.db.QueryRow(ctx, `
 		UPDATE raw
 		SET data = $2
 		WHERE event_id = $1
@@ -104,11 +194,12 @@ func (r *RawRepository) UpdateRaw(data *Raw) (*Raw, error) {
 	return &result, nil
 }
 
-func (r *RawRepository) DeleteRawEvent(event_id int64) error {
-	cmd, err := r.db.Exec(context.Background(), `
-		DELETE FROM events
-		USING raw
-		WHERE events.id = raw.event_id AND raw.event_id = $1
+func (r *RawRepository) DeleteRawEvent(ctx context.Context, event_id int64) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE events
+		SET deleted_at = CURRENT_TIMESTAMP
+		FROM raw
+		WHERE events.id = raw.event_id AND raw.event_id = $1 AND events.deleted_at IS NULL
 	`, event_id)
 	if err != nil {
 		return fmt.Errorf("RawRepository.DelteRaw: %v", err)
@@ -2,20 +2,27 @@ package raw
 
 import (
 	"backend/internal/core"
+	"backend/internal/db"
+	"context"
 	"fmt"
+
+	"github.com/jackc/pgx/v5"
 )
 
 type RawService struct {
+	txManager *db.TxManager
 	rawRepo   *RawRepository
 	eventRepo *core.EventRepository
+	journal   *core.EventJournal
+	rules     *core.RuleEngine
 }
 
-func NewRawService(rawRepo *RawRepository, eventRepo *core.EventRepository) *RawService {
-	return &RawService{rawRepo, eventRepo}
+func NewRawService(txManager *db.TxManager, rawRepo *RawRepository, eventRepo *core.EventRepository, journal *core.EventJournal, rules *core.RuleEngine) *RawService {
+	return &RawService{txManager, rawRepo, eventRepo, journal, rules}
 }
 
-func (s *RawService) ListRawEvents(query *core.EventQueryBuilder) ([]RawEventResponse, error) {
-	data, err := s.rawRepo.ListRawEvents(query)
+func (s *RawService) ListRawEvents(ctx context.Context, query *core.EventQueryBuilder) (*core.EventPage[RawEventResponse], error) {
+	data, err := s.rawRepo.ListRawEvents(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("RawService.ListRawEvents: %v", err)
 	}
@@ -28,47 +35,166 @@ func (s *RawService) ListRawEvents(query *core.EventQueryBuilder) ([]RawEventRes
 		}
 	}
 
-	return result, nil
+	return core.NewEventPage(result, query, func(e *RawEventResponse) *core.EventResponse { return &e.EventResponse }), nil
 }
 
-func (s *RawService) GetRawEvent(eventID int64) (*RawEventResponse, error) {
-	data, err := s.rawRepo.GetRawEvent(eventID)
+func (s *RawService) GetRawEvent(ctx context.Context, eventID int64, visibility core.Visibility) (*RawEventResponse, error) {
+	data, err := s.rawRepo.GetRawEvent(ctx, eventID, visibility)
 	if err != nil {
 		return nil, fmt.Errorf("RawService.GetRawEvent: %v", err)
 	}
 
+	if data == nil {
+		return nil, nil
+	}
+
 	return &RawEventResponse{
 		EventResponse: *data.ToEventResponse(),
 		Extras:        data.Extras.Data,
 	}, nil
 }
 
-func (s *RawService) RegisterRawEvent(request *CreateRawEventRequest) (*RawEventResponse, error) {
+func (s *RawService) RegisterRawEvent(ctx context.Context, request *CreateRawEventRequest) (*RawEventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("RawService.RegisterRawEvent: validation failed, %v", err)
 	}
 
+	var result *RawEventResponse
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		result, err = s.registerRawEvent(ctx, tx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// RegisterRawEvents creates the raw events in one transaction and reports the result of every item, see core.RunBatch
+func (s *RawService) RegisterRawEvents(ctx context.Context, requests []CreateRawEventRequest, atomic bool) (*core.BatchResponse[RawEventResponse], error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.RunBatch(ctx, s.txManager, requests, atomic, func(request *CreateRawEventRequest) error {
+		err := request.Validate()
+		if err != nil {
+			return fmt.Errorf("RawService.RegisterRawEvents: validation failed, %v", err)
+		}
+
+		prepareRawEvent(rules, request)
+		return nil
+	}, func(tx pgx.Tx, requests []*CreateRawEventRequest) ([]*RawEventResponse, error) {
+		return s.registerRawEvents(ctx, tx, rules, requests)
+	})
+}
+
+func (s *RawService) registerRawEvent(ctx context.Context, tx pgx.Tx, request *CreateRawEventRequest) (*RawEventResponse, error) {
+	rules, err := s.rules.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prepareRawEvent(rules, request)
+
+	result, err := s.registerRawEvents(ctx, tx, rules, []*CreateRawEventRequest{request})
+	if err != nil {
+		return nil, err
+	}
+
+	return result[0], nil
+}
+
+// prepareRawEvent marks the request as raw event and adds the tags of the matching rules
+func prepareRawEvent(rules core.RuleSet, request *CreateRawEventRequest) {
 	request.Reference = RawTable
 	request.Tags = append(request.Tags, "module:raw")
 
-	event, err := s.eventRepo.CreateEvent(request.CreateEventRequest.ToEvent())
+	rules.Apply(&request.EventRequest, request.Extras)
+}
+
+// registerRawEvents creates the events of the requests prepared by prepareRawEvent, their raw data
+// and the events derived by the rules, the statements of all requests are sent at once
+func (s *RawService) registerRawEvents(ctx context.Context, tx pgx.Tx, rules core.RuleSet, requests []*CreateRawEventRequest) ([]*RawEventResponse, error) {
+	events := make([]*core.Event, len(requests))
+	for i, request := range requests {
+		events[i] = request.CreateEventRequest.ToEvent()
+	}
+
+	events, err := s.eventRepo.WithTx(tx).CreateEvents(ctx, events)
+	if err != nil {
+		return nil, fmt.Errorf("RawService.RegisterRawEvent: failed to create event, %w", err)
+	}
+
+	result := make([]*RawEventResponse, len(requests))
+	data := make([]*Raw, 0, len(requests))
+	created := make([]*core.EventSnapshot, 0, len(requests))
+	for i, event := range events {
+		if event == nil {
+			// retried submission, return the original event
+			result[i], err = s.getRawEventByClientID(ctx, tx, requests[i].ProviderID, *requests[i].ClientID)
+			if err != nil {
+				return nil, &core.BatchItemError{Index: i, Err: err}
+			}
+			continue
+		}
+
+		data = append(data, &Raw{EventID: event.ID, Data: requests[i].Extras})
+
+		result[i] = &RawEventResponse{
+			EventResponse: *event.ToEventResponse(),
+			Extras:        requests[i].Extras,
+		}
+		created = append(created, &core.EventSnapshot{EventResponse: result[i].EventResponse, Extras: requests[i].Extras})
+	}
+
+	err = s.rawRepo.WithTx(tx).CreateRaw(ctx, data)
+	if err != nil {
+		return nil, fmt.Errorf("RawService.RegisterRawEvent: failed to create raw data, %v", err)
+	}
+
+	err = s.journal.RecordCreated(ctx, tx, created)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.rules.Derive(ctx, tx, rules, created)
 	if err != nil {
-		return nil, fmt.Errorf("RawService.RegisterRawEvent: failed to create event, %v", err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (s *RawService) getRawEventByClientID(ctx context.Context, tx pgx.Tx, providerID *int64, clientID string) (*RawEventResponse, error) {
+	event, err := s.eventRepo.WithTx(tx).GetEventByClientID(ctx, providerID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("RawService.getRawEventByClientID: %v", err)
+	}
+
+	if event == nil || event.Reference != RawTable {
+		return nil, fmt.Errorf("RawService.getRawEventByClientID: client id %s is used by another event", clientID)
 	}
 
-	data, err := s.rawRepo.CreateRaw(&Raw{EventID: event.ID, Data: request.Extras})
+	data, err := s.rawRepo.WithTx(tx).GetRawEvent(ctx, event.ID, core.FullVisibility)
 	if err != nil {
-		return nil, fmt.Errorf("RawService.RegisterEvent: failed to create raw data, %v", err)
+		return nil, fmt.Errorf("RawService.getRawEventByClientID: %v", err)
+	}
+
+	if data == nil {
+		return nil, fmt.Errorf("RawService.getRawEventByClientID: missing raw data of event %d", event.ID)
 	}
 
 	return &RawEventResponse{
 		EventResponse: *event.ToEventResponse(),
-		Extras:        data.Data,
+		Extras:        data.Extras.Data,
 	}, nil
 }
 
-func (s *RawService) UpdateRawEvent(request *UpdateRawEventRequest) (*RawEventResponse, error) {
+func (s *RawService) UpdateRawEvent(ctx context.Context, request *UpdateRawEventRequest) (*RawEventResponse, error) {
 	err := request.Validate()
 	if err != nil {
 		return nil, fmt.Errorf("RawService.UpdateRawEvent: validation failed, %v", err)
@@ -76,14 +202,33 @@ func (s *RawService) UpdateRawEvent(request *UpdateRawEventRequest) (*RawEventRe
 
 	request.Reference = RawTable
 
-	event, err := s.eventRepo.UpdateEvent(request.UpdateEventRequest.ToEvent())
-	if err != nil {
-		return nil, fmt.Errorf("RawService.UpdateRawEvent: failed to update event, %v", err)
-	}
+	var event *core.Event
+	var data *Raw
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, request.ID)
+		if err != nil {
+			return err
+		}
+
+		err = s.rules.Apply(ctx, tx, &request.EventRequest, request.Extras)
+		if err != nil {
+			return err
+		}
 
-	data, err := s.rawRepo.UpdateRaw(&Raw{EventID: event.ID, Data: request.Extras})
+		event, err = s.eventRepo.WithTx(tx).UpdateEvent(ctx, request.UpdateEventRequest.ToEvent())
+		if err != nil {
+			return fmt.Errorf("RawService.UpdateRawEvent: failed to update event, %v", err)
+		}
+
+		data, err = s.rawRepo.WithTx(tx).UpdateRaw(ctx, &Raw{EventID: event.ID, Data: request.Extras})
+		if err != nil {
+			return fmt.Errorf("RawService.UpdateRawEvent: faile to update raw data, %v", err)
+		}
+
+		return s.journal.Record(ctx, tx, core.RevisionActionUpdate, event.ID, previous)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("RawService.UpdateRawEvent: faile to update raw data, %v", err)
+		return nil, err
 	}
 
 	return &RawEventResponse{
@@ -92,6 +237,18 @@ func (s *RawService) UpdateRawEvent(request *UpdateRawEventRequest) (*RawEventRe
 	}, nil
 }
 
-func (s *RawService) DeleteRawEvent(eventID int64) error {
-	return s.rawRepo.DeleteRawEvent(eventID)
+func (s *RawService) DeleteRawEvent(ctx context.Context, eventID int64) error {
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		previous, err := s.journal.Snapshot(ctx, tx, eventID)
+		if err != nil {
+			return err
+		}
+
+		err = s.rawRepo.WithTx(tx).DeleteRawEvent(ctx, eventID)
+		if err != nil {
+			return err
+		}
+
+		return s.journal.Record(ctx, tx, core.RevisionActionDelete, eventID, previous)
+	})
 }
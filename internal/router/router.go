@@ -3,32 +3,54 @@ package router
 import (
 	"backend/internal/config"
 	"backend/internal/core"
+	"backend/internal/db"
 	"backend/internal/locations"
 	"backend/internal/raw"
 	"backend/pkg/handler"
+	"backend/pkg/middleware"
+	"backend/pkg/storage"
+	"context"
+	"fmt"
+	"log"
 	"net/http"
 	"path/filepath"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func NewRouter(db *pgxpool.Pool, config *config.AuthConfig) *http.ServeMux {
+func NewRouter(conn *pgxpool.Pool, cfg *config.Config) *http.ServeMux {
 	r := http.NewServeMux()
 
 	routes := make([]handler.Route, 0)
 
+	txManager := db.NewTxManager(conn)
+
 	// repositories
-	tokenRepo := core.NewTokenRepository(db)
-	userRepo := core.NewUserRepository(db)
-	providerRepo := core.NewProviderRepository(db)
-	eventRepo := core.NewEventRepository(db)
-	tagRepo := core.NewTagRepository(db)
+	tokenRepo := core.NewTokenRepository(conn)
+	userRepo := core.NewUserRepository(conn)
+	providerRepo := core.NewProviderRepository(conn)
+	eventRepo := core.NewEventRepository(conn)
+	tagRepo := core.NewTagRepository(conn)
+	revisionRepo := core.NewRevisionRepository(conn)
+	changeRepo := core.NewChangeRepository(conn)
+	webhookRepo := core.NewWebhookRepository(conn)
+	ruleRepo := core.NewRuleRepository(conn)
+	seriesRepo := core.NewSeriesRepository(conn)
+	linkRepo := core.NewEventLinkRepository(conn)
+
+	// module data of events, used for revisions
+	extras := core.NewExtrasRegistry()
+	extras.Register(locations.LocationGPSHistoryTable, locations.NewLocationExtras)
+	extras.Register(raw.RawTable, raw.NewRawExtras)
+	journal := core.NewEventJournal(eventRepo, revisionRepo, webhookRepo, extras)
+	rules := core.NewRuleEngine(ruleRepo, eventRepo, linkRepo, journal)
 
 	// auth
-	authService := core.NewAuthService(userRepo, providerRepo, tokenRepo, config)
+	authService := core.NewAuthService(userRepo, providerRepo, tokenRepo, &cfg.Auth)
 	authenticationMiddleware := core.GetAuthenticationMiddleware(authService)
 	authorizationMiddleware := core.GetAuthorizationMiddleware(authService)
-	var authHandler handler.Handler = core.NewAuthHandler(authService, config)
+	var authHandler handler.Handler = core.NewAuthHandler(authService, &cfg.Auth)
 	routes = append(routes, authHandler.GetRoutes()...)
 
 	// users
@@ -42,30 +64,99 @@ func NewRouter(db *pgxpool.Pool, config *config.AuthConfig) *http.ServeMux {
 	routes = append(routes, providerHandler.GetRoutes()...)
 
 	// events
-	eventService := core.NewEventService(eventRepo)
+	eventService := core.NewEventService(txManager, eventRepo, seriesRepo, linkRepo, journal, rules)
 	var eventHandler handler.Handler = core.NewEventHandler(eventService)
 	routes = append(routes, eventHandler.GetRoutes()...)
 
+	// links between events
+	linkService := core.NewEventLinkService(linkRepo, eventRepo)
+	var linkHandler handler.Handler = core.NewEventLinkHandler(linkService)
+	routes = append(routes, linkHandler.GetRoutes()...)
+
+	// attachments
+	attachmentStorage, err := newStorage(&cfg.Attachments)
+	if err != nil {
+		log.Fatalf("Unable to set up the attachment storage, %v", err)
+	}
+	attachmentRepo := core.NewAttachmentRepository(conn)
+	attachmentService := core.NewAttachmentService(txManager, attachmentRepo, eventRepo, attachmentStorage, &cfg.Attachments)
+	var attachmentHandler handler.Handler = core.NewAttachmentHandler(attachmentService)
+	routes = append(routes, attachmentHandler.GetRoutes()...)
+	go attachmentService.RunCleanup(context.Background(), time.Minute)
+
+	// recurring events
+	seriesService := core.NewSeriesService(seriesRepo)
+	var seriesHandler handler.Handler = core.NewSeriesHandler(seriesService)
+	routes = append(routes, seriesHandler.GetRoutes()...)
+
+	// trash
+	trashService := core.NewTrashService(txManager, eventRepo, journal)
+	var trashHandler handler.Handler = core.NewTrashHandler(trashService)
+	routes = append(routes, trashHandler.GetRoutes()...)
+	if cfg.Trash.PurgeAfter > 0 {
+		go trashService.RunAutoPurge(context.Background(), cfg.Trash.PurgeAfter, time.Hour)
+	}
+
+	// live stream
+	broker := core.NewEventBroker(conn, revisionRepo, tagRepo)
+	go broker.Run(context.Background())
+	eventStreamService := core.NewEventStreamService(revisionRepo, tagRepo, broker)
+	var eventStreamHandler handler.Handler = core.NewEventStreamHandler(eventStreamService)
+	routes = append(routes, eventStreamHandler.GetRoutes()...)
+
+	// rules
+	ruleService := core.NewRuleService(txManager, ruleRepo, eventRepo, journal, rules)
+	var ruleHandler handler.Handler = core.NewRuleHandler(ruleService)
+	routes = append(routes, ruleHandler.GetRoutes()...)
+
+	// webhooks
+	webhookService := core.NewWebhookService(webhookRepo)
+	var webhookHandler handler.Handler = core.NewWebhookHandler(webhookService)
+	routes = append(routes, webhookHandler.GetRoutes()...)
+	webhookDispatcher := core.NewWebhookDispatcher(webhookRepo)
+	go webhookDispatcher.Run(context.Background())
+
+	// revisions
+	revisionService := core.NewRevisionService(txManager, revisionRepo, journal)
+	var revisionHandler handler.Handler = core.NewRevisionHandler(revisionService)
+	routes = append(routes, revisionHandler.GetRoutes()...)
+
+	// change feed
+	changeService := core.NewChangeService(txManager, changeRepo, journal)
+	var changeHandler handler.Handler = core.NewChangeHandler(changeService)
+	routes = append(routes, changeHandler.GetRoutes()...)
+
+	// reports
+	reportRepo := core.NewReportRepository(conn)
+	reportService := core.NewReportService(reportRepo)
+	var reportHandler handler.Handler = core.NewReportHandler(reportService)
+	routes = append(routes, reportHandler.GetRoutes()...)
+
 	// tags
-	tagService := core.NewTagService(tagRepo, eventRepo)
+	tagService := core.NewTagService(txManager, tagRepo, eventRepo, journal)
 	var tagHandler handler.Handler = core.NewTagHandler(tagService)
 	routes = append(routes, tagHandler.GetRoutes()...)
 
 	// location - history
-	locationRepo := locations.NewLocationRepository(db)
-	locationService := locations.NewLocationService(locationRepo, eventRepo)
+	locationRepo := locations.NewLocationRepository(conn)
+	placeRepo := locations.NewPlaceRepository(conn)
+	locationService := locations.NewLocationService(txManager, locationRepo, placeRepo, eventRepo, journal, rules)
 	var locationHandler handler.Handler = locations.NewLocationHandler(locationService)
 	routes = append(routes, locationHandler.GetRoutes()...)
 
+	// location - photos
+	photoService := locations.NewPhotoService(txManager, locationService, locationRepo, eventRepo, journal, rules, attachmentService)
+	var photoHandler handler.Handler = locations.NewPhotoHandler(photoService)
+	routes = append(routes, photoHandler.GetRoutes()...)
+
 	// location - places
-	placeRepo := locations.NewPlaceRepository(db)
 	placeService := locations.NewPlaceService(placeRepo)
 	var placeHandler handler.Handler = locations.NewPlaceHandler(placeService)
 	routes = append(routes, placeHandler.GetRoutes()...)
 
 	// raw events
-	rawRepo := raw.NewRawRepository(db)
-	rawService := raw.NewRawService(rawRepo, eventRepo)
+	rawRepo := raw.NewRawRepository(conn)
+	rawService := raw.NewRawService(txManager, rawRepo, eventRepo, journal, rules)
 	var rawHandler handler.Handler = raw.NewRawHandler(rawService)
 	routes = append(routes, rawHandler.GetRoutes()...)
 
@@ -80,6 +171,11 @@ func NewRouter(db *pgxpool.Pool, config *config.AuthConfig) *http.ServeMux {
 			handlerFunc = authenticationMiddleware(handlerFunc)
 		}
 
+		timeout := cfg.Server.GetRouteTimeout(route.Pattern)
+		if timeout > 0 {
+			handlerFunc = middleware.TimeoutMiddleware(handlerFunc, timeout)
+		}
+
 		r.Handle(route.Pattern, handlerFunc)
 	}
 
@@ -101,3 +197,22 @@ func NewRouter(db *pgxpool.Pool, config *config.AuthConfig) *http.ServeMux {
 
 	return r
 }
+
+// newStorage returns the blob storage selected by the configuration
+func newStorage(cfg *config.AttachmentsConfig) (storage.Storage, error) {
+	switch cfg.Storage {
+	case "", "fs":
+		return storage.NewFSStorage(cfg.Path)
+	case "s3":
+		return storage.NewS3Storage(storage.S3Options{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PathStyle: cfg.S3.PathStyle,
+		})
+	}
+
+	return nil, fmt.Errorf("newStorage: unknown storage %s", cfg.Storage)
+}
@@ -0,0 +1,9 @@
+-- full-text search over event notes
+ALTER TABLE events ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(note, ''))) STORED;
+
+CREATE INDEX events_search_idx ON events USING GIN(search);
+
+-- full-text search over string values of raw payloads
+ALTER TABLE raw ADD COLUMN search TSVECTOR GENERATED ALWAYS AS (jsonb_to_tsvector('simple', data, '["string"]')) STORED;
+
+CREATE INDEX raw_search_idx ON raw USING GIN(search);
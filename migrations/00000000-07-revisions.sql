@@ -0,0 +1,16 @@
+-- event revisions, event_id has no foreign key so the history outlives deleted events
+CREATE TABLE event_revisions (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    event_id BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    previous JSONB,
+    current JSONB,
+    user_id BIGINT,
+    provider_id BIGINT
+);
+
+CREATE INDEX event_revisions_event_id_idx ON event_revisions (event_id);
+
+ALTER TABLE event_revisions ADD CONSTRAINT fk_event_revisions_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE event_revisions ADD CONSTRAINT fk_event_revisions_provider_id FOREIGN KEY (provider_id) REFERENCES providers (id) ON DELETE SET NULL;
@@ -0,0 +1,4 @@
+-- soft delete
+ALTER TABLE events ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX events_deleted_at_idx ON events (deleted_at);
@@ -0,0 +1,4 @@
+-- client generated key of provider submissions, retries with the same key return the original event
+ALTER TABLE events ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX events_client_id_idx ON events (COALESCE(provider_id, 0), client_id) WHERE client_id IS NOT NULL;
@@ -0,0 +1,77 @@
+-- change feed, every write of an event or its module extras moves the event to the end of the sequence
+CREATE SEQUENCE events_change_seq;
+
+ALTER TABLE events ADD COLUMN change_seq BIGINT;
+UPDATE events SET change_seq = nextval('events_change_seq');
+ALTER TABLE events ALTER COLUMN change_seq SET NOT NULL;
+
+CREATE INDEX events_change_seq_idx ON events (change_seq);
+
+-- purged events
+CREATE TABLE event_tombstones (
+    event_id BIGINT PRIMARY KEY,
+    change_seq BIGINT NOT NULL
+);
+
+CREATE INDEX event_tombstones_change_seq_idx ON event_tombstones (change_seq);
+
+-- Writers wait for each other until commit, so the numbers become visible in order
+-- and a client reading the feed never skips a change committed later with a lower number.
+CREATE OR REPLACE FUNCTION next_event_change_seq()
+RETURNS BIGINT AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('events_change_seq'));
+    RETURN nextval('events_change_seq');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_event_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = next_event_change_seq();
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER insert_events_change_seq BEFORE INSERT ON events
+FOR EACH ROW EXECUTE FUNCTION update_event_change_seq();
+
+-- updates setting the sequence themselves, see touch_event_change_seq, are kept as they are
+CREATE TRIGGER update_events_change_seq BEFORE UPDATE ON events
+FOR EACH ROW WHEN (OLD.change_seq = NEW.change_seq) EXECUTE FUNCTION update_event_change_seq();
+
+CREATE OR REPLACE FUNCTION insert_event_tombstone()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO event_tombstones (event_id, change_seq)
+    VALUES (OLD.id, next_event_change_seq())
+    ON CONFLICT (event_id) DO UPDATE SET change_seq = EXCLUDED.change_seq;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER delete_events_tombstone AFTER DELETE ON events
+FOR EACH ROW EXECUTE FUNCTION insert_event_tombstone();
+
+-- module extras, the table needs an event_id column
+CREATE OR REPLACE FUNCTION touch_event_change_seq()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_event_id = OLD.event_id;
+    ELSE
+        changed_event_id = NEW.event_id;
+    END IF;
+
+    UPDATE events SET change_seq = next_event_change_seq() WHERE id = changed_event_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER touch_locations_history_change_seq AFTER INSERT OR UPDATE OR DELETE ON locations_history
+FOR EACH ROW EXECUTE FUNCTION touch_event_change_seq();
+
+CREATE TRIGGER touch_raw_change_seq AFTER INSERT OR UPDATE OR DELETE ON raw
+FOR EACH ROW EXECUTE FUNCTION touch_event_change_seq();
@@ -0,0 +1,37 @@
+-- webhook subscriptions, empty tags or types match every event
+CREATE TABLE webhooks (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    types TEXT[] NOT NULL DEFAULT '{}',
+    private BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_webhooks_updated BEFORE UPDATE ON webhooks
+FOR EACH ROW EXECUTE FUNCTION update_updated_column();
+
+-- delivery queue and log
+CREATE TABLE webhook_deliveries (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    webhook_id BIGINT NOT NULL,
+    revision_id BIGINT NOT NULL,
+    event_id BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    response_status INT,
+    error TEXT,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    delivered TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt) WHERE status = 'pending';
+
+ALTER TABLE webhook_deliveries ADD CONSTRAINT fk_webhook_deliveries_webhook_id FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE;
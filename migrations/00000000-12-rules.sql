@@ -0,0 +1,14 @@
+-- user defined rules adding tags to events, reference NULL matches events of every module
+CREATE TABLE rules (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    name TEXT NOT NULL,
+    reference TEXT,
+    conditions JSONB NOT NULL DEFAULT '[]',
+    tags TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_rules_updated BEFORE UPDATE ON rules
+FOR EACH ROW EXECUTE FUNCTION update_updated_column();
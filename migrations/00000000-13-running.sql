@@ -0,0 +1,5 @@
+-- starting an interval with an exclusive tag stops the running intervals carrying the same tag
+ALTER TABLE tags ADD COLUMN exclusive BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- running intervals
+CREATE INDEX events_running_idx ON events (timestamp) WHERE type = 'interval' AND until IS NULL;
@@ -0,0 +1,2 @@
+-- IANA timezone the event happened in, the timestamps stay in UTC
+ALTER TABLE events ADD COLUMN timezone TEXT;
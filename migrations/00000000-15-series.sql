@@ -0,0 +1,23 @@
+-- recurring events, the occurrences are expanded from the RFC 5545 RRULE when listing events
+CREATE TABLE series (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    rrule TEXT NOT NULL,
+    start TIMESTAMPTZ NOT NULL,
+    -- seconds, occurrences of series with a duration are intervals
+    duration BIGINT NOT NULL DEFAULT 0,
+    -- the occurrences keep the wall clock time of the start in this timezone
+    timezone TEXT,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    note TEXT NOT NULL DEFAULT '',
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_series_updated BEFORE UPDATE ON series
+FOR EACH ROW EXECUTE FUNCTION update_updated_column();
+
+-- materialized occurrences, a trashed one hides the occurrence
+ALTER TABLE events ADD COLUMN series_id BIGINT REFERENCES series(id) ON DELETE SET NULL;
+ALTER TABLE events ADD COLUMN occurrence TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX events_series_occurrence_idx ON events (series_id, occurrence) WHERE series_id IS NOT NULL;
@@ -0,0 +1,12 @@
+-- typed relations between events, links of trashed events are hidden and removed when the event is purged
+CREATE TABLE event_links (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    source_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    target_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (source_id, target_id, type),
+    CHECK (source_id <> target_id)
+);
+
+CREATE INDEX event_links_target_idx ON event_links (target_id);
@@ -0,0 +1,30 @@
+-- files attached to events, the blobs live in the configured storage under key
+CREATE TABLE attachments (
+    id BIGINT PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+    event_id BIGINT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    key TEXT NOT NULL UNIQUE,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 TEXT NOT NULL,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX attachments_event_id_idx ON attachments (event_id);
+
+-- blobs of deleted attachments, also of purged events, removed from the storage in the background
+CREATE TABLE attachment_deletions (
+    key TEXT PRIMARY KEY,
+    created TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO attachment_deletions (key) VALUES (OLD.key) ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER delete_attachments_blob AFTER DELETE ON attachments
+FOR EACH ROW EXECUTE FUNCTION queue_attachment_deletion();
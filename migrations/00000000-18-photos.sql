@@ -0,0 +1,2 @@
+-- duplicate photos are detected by the hash of their content
+CREATE INDEX attachments_sha256_idx ON attachments (sha256);
@@ -0,0 +1,46 @@
+-- Change sequence numbers are assigned at commit instead of on every write, see 00000000-10-changes.sql.
+-- Rows get a provisional number when they are written, deferred triggers replace it when the transaction commits.
+-- Only these triggers take the lock ordering the numbers, so writers wait for each other while committing,
+-- not for their whole transaction.
+DROP TRIGGER IF EXISTS update_events_change_seq ON events;
+DROP TRIGGER IF EXISTS delete_events_tombstone ON events;
+DROP TRIGGER IF EXISTS touch_locations_history_change_seq ON locations_history;
+DROP TRIGGER IF EXISTS touch_raw_change_seq ON raw;
+
+-- provisional number of written rows, never visible to other transactions
+CREATE OR REPLACE FUNCTION update_event_change_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.change_seq = nextval('events_change_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION sequence_event_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE events SET change_seq = next_event_change_seq() WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER insert_events_change_commit AFTER INSERT ON events
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION sequence_event_change();
+
+-- the update of sequence_event_change sets the sequence itself and is not queued again
+CREATE CONSTRAINT TRIGGER update_events_change_commit AFTER UPDATE ON events
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW WHEN (OLD.change_seq = NEW.change_seq) EXECUTE FUNCTION sequence_event_change();
+
+CREATE CONSTRAINT TRIGGER delete_events_tombstone AFTER DELETE ON events
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION insert_event_tombstone();
+
+CREATE CONSTRAINT TRIGGER touch_locations_history_change_seq AFTER INSERT OR UPDATE OR DELETE ON locations_history
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION touch_event_change_seq();
+
+CREATE CONSTRAINT TRIGGER touch_raw_change_seq AFTER INSERT OR UPDATE OR DELETE ON raw
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION touch_event_change_seq();
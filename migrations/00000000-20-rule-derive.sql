@@ -0,0 +1,2 @@
+-- rules creating an event for every matched event, see RuleDerivation
+ALTER TABLE rules ADD COLUMN derive JSONB;
@@ -0,0 +1,5 @@
+-- blobs are uploaded before the transaction adding their attachment commits, so every upload is queued for deletion
+-- first and the transaction removes the entry again. Entries of rolled back uploads become due at not_before.
+ALTER TABLE attachment_deletions ADD COLUMN not_before TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX attachment_deletions_not_before_idx ON attachment_deletions (not_before);
@@ -0,0 +1,44 @@
+-- Stream position of revisions, assigned at commit like events.change_seq, see 00000000-19-change-commit.sql.
+-- The identity of a revision is taken at insert, so concurrent transactions commit their revisions out of order
+-- and a stream resuming after an ID would miss the ones committed later with a lower ID.
+CREATE SEQUENCE event_revisions_stream_seq;
+
+ALTER TABLE event_revisions ADD COLUMN stream_seq BIGINT;
+UPDATE event_revisions SET stream_seq = id;
+SELECT setval('event_revisions_stream_seq', COALESCE(MAX(stream_seq), 0) + 1, false) FROM event_revisions;
+ALTER TABLE event_revisions ALTER COLUMN stream_seq SET NOT NULL;
+
+CREATE INDEX event_revisions_stream_seq_idx ON event_revisions (stream_seq);
+
+-- the lock of next_event_change_seq orders both sequences, a second lock could deadlock with it
+CREATE OR REPLACE FUNCTION next_event_revision_stream_seq()
+RETURNS BIGINT AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('events_change_seq'));
+    RETURN nextval('event_revisions_stream_seq');
+END;
+$$ LANGUAGE plpgsql;
+
+-- provisional number of inserted rows, never visible to other transactions
+CREATE OR REPLACE FUNCTION insert_event_revision_stream_seq()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.stream_seq = nextval('event_revisions_stream_seq');
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER insert_event_revisions_stream_seq BEFORE INSERT ON event_revisions
+FOR EACH ROW EXECUTE FUNCTION insert_event_revision_stream_seq();
+
+CREATE OR REPLACE FUNCTION sequence_event_revision()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE event_revisions SET stream_seq = next_event_revision_stream_seq() WHERE id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER insert_event_revisions_stream_commit AFTER INSERT ON event_revisions
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION sequence_event_revision();
@@ -21,6 +21,15 @@ BEGIN
     --     EXECUTE 'DROP FUNCTION IF EXISTS ' || quote_ident(r.routine_name) || ' CASCADE';
     -- END LOOP;
     DROP FUNCTION IF EXISTS update_updated_column CASCADE;
+    DROP FUNCTION IF EXISTS next_event_change_seq CASCADE;
+    DROP FUNCTION IF EXISTS update_event_change_seq CASCADE;
+    DROP FUNCTION IF EXISTS sequence_event_change CASCADE;
+    DROP FUNCTION IF EXISTS insert_event_tombstone CASCADE;
+    DROP FUNCTION IF EXISTS touch_event_change_seq CASCADE;
+    DROP FUNCTION IF EXISTS queue_attachment_deletion CASCADE;
+    DROP FUNCTION IF EXISTS next_event_revision_stream_seq CASCADE;
+    DROP FUNCTION IF EXISTS insert_event_revision_stream_seq CASCADE;
+    DROP FUNCTION IF EXISTS sequence_event_revision CASCADE;
 
     -- Drop all types
     FOR r IN (SELECT pg_type.typname FROM pg_type JOIN pg_namespace ON pg_namespace.oid = pg_type.typnamespace WHERE pg_namespace.nspname = current_schema() AND pg_type.typtype = 'c') LOOP
@@ -0,0 +1,535 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("exif: unsupported file format, expected JPEG or HEIC")
+	ErrNotFound          = errors.New("exif: no EXIF data found")
+)
+
+// maxExifSize bounds the EXIF block read from HEIC files, JPEG segments are limited to 64 KiB by the format
+const maxExifSize = 1 << 20
+
+// Metadata holds the EXIF tags used to place a photo in time and space, missing tags are nil
+type Metadata struct {
+	// DateTimeOriginal, with OffsetTimeOriginal when present
+	Taken     *time.Time
+	Latitude  *float64
+	Longitude *float64
+	// GPSHPositioningError in meters
+	Accuracy *float64
+}
+
+// Decode reads the EXIF metadata of a JPEG or HEIC file.
+// The time the photo was taken is interpreted in location when the file has no offset.
+func Decode(r io.ReadSeeker, location *time.Location) (*Metadata, error) {
+	head := make([]byte, 12)
+	_, err := io.ReadFull(r, head)
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	var tiff []byte
+	switch {
+	case head[0] == 0xFF && head[1] == 0xD8:
+		tiff, err = jpegExif(r)
+	case string(head[4:8]) == "ftyp":
+		tiff, err = heicExif(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTIFF(tiff, location)
+}
+
+// jpegExif returns the TIFF structure of the APP1 Exif segment
+func jpegExif(r io.Reader) ([]byte, error) {
+	reader := &errReader{r: r}
+	reader.read(2) // SOI
+
+	for reader.err == nil {
+		marker := reader.read(2)
+		if reader.err != nil {
+			break
+		}
+
+		if marker[0] != 0xFF {
+			return nil, errors.New("exif: invalid JPEG marker")
+		}
+
+		// start of scan or end of image, the metadata segments come before
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			break
+		}
+
+		length := int(binary.BigEndian.Uint16(reader.read(2)))
+		if length < 2 {
+			return nil, errors.New("exif: invalid JPEG segment")
+		}
+
+		segment := reader.read(length - 2)
+		if marker[1] == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+
+	if reader.err != nil && reader.err != io.EOF && reader.err != io.ErrUnexpectedEOF {
+		return nil, reader.err
+	}
+
+	return nil, ErrNotFound
+}
+
+// heicExif returns the TIFF structure of the Exif item of a HEIF container
+func heicExif(r io.ReadSeeker) ([]byte, error) {
+	meta, err := findBox(r, "meta", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// meta is a full box, version and flags come before the children
+	if len(meta) < 4 {
+		return nil, ErrNotFound
+	}
+	children := meta[4:]
+
+	iinf := childBox(children, "iinf")
+	iloc := childBox(children, "iloc")
+	if iinf == nil || iloc == nil {
+		return nil, ErrNotFound
+	}
+
+	itemID, ok := exifItemID(iinf)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	offset, length, ok := itemLocation(iloc, itemID)
+	if !ok || length < 4 || length > maxExifSize {
+		return nil, ErrNotFound
+	}
+
+	_, err = r.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// the item starts with the offset of the TIFF header, usually skipping "Exif\0\0"
+	start := 4 + uint64(binary.BigEndian.Uint32(data))
+	if start >= uint64(len(data)) {
+		return nil, ErrNotFound
+	}
+
+	return data[start:], nil
+}
+
+// findBox returns the content of the first top level box of the type
+func findBox(r io.ReadSeeker, name string, limit int64) ([]byte, error) {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return nil, ErrNotFound
+		}
+
+		size := int64(binary.BigEndian.Uint32(header))
+		headerSize := int64(8)
+		if size == 1 {
+			large := make([]byte, 8)
+			_, err = io.ReadFull(r, large)
+			if err != nil {
+				return nil, ErrNotFound
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+
+		if size != 0 && size < headerSize {
+			return nil, errors.New("exif: invalid HEIF box")
+		}
+
+		if string(header[4:8]) == name {
+			if size == 0 || size-headerSize > maxExifSize {
+				return nil, errors.New("exif: HEIF box too large")
+			}
+
+			content := make([]byte, size-headerSize)
+			_, err = io.ReadFull(r, content)
+			return content, err
+		}
+
+		// the last box reaches the end of the file
+		if size == 0 {
+			return nil, ErrNotFound
+		}
+
+		_, err = r.Seek(size-headerSize, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// childBox returns the content of the first box of the type in data
+func childBox(data []byte, name string) []byte {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data))
+		if size < 8 || size > len(data) {
+			return nil
+		}
+
+		if string(data[4:8]) == name {
+			return data[8:size]
+		}
+
+		data = data[size:]
+	}
+
+	return nil
+}
+
+// exifItemID returns the ID of the item of type "Exif" listed in the iinf box
+func exifItemID(iinf []byte) (uint32, bool) {
+	if len(iinf) < 6 {
+		return 0, false
+	}
+
+	entries := iinf[6:]
+	if iinf[0] > 0 {
+		if len(iinf) < 8 {
+			return 0, false
+		}
+		entries = iinf[8:]
+	}
+
+	for len(entries) >= 8 {
+		size := int(binary.BigEndian.Uint32(entries))
+		if size < 8 || size > len(entries) {
+			return 0, false
+		}
+
+		if string(entries[4:8]) == "infe" {
+			infe := entries[8:size]
+			// item infos before version 2 carry no item type
+			if len(infe) >= 4 && infe[0] >= 2 {
+				version := infe[0]
+				body := infe[4:]
+
+				var id uint32
+				if version == 2 && len(body) >= 8 {
+					id = uint32(binary.BigEndian.Uint16(body))
+					body = body[2:]
+				} else if version == 3 && len(body) >= 10 {
+					id = binary.BigEndian.Uint32(body)
+					body = body[4:]
+				} else {
+					body = nil
+				}
+
+				// skip the protection index
+				if len(body) >= 6 && string(body[2:6]) == "Exif" {
+					return id, true
+				}
+			}
+		}
+
+		entries = entries[size:]
+	}
+
+	return 0, false
+}
+
+// itemLocation returns the file offset and length of the first extent of the item in the iloc box
+func itemLocation(iloc []byte, itemID uint32) (uint64, uint64, bool) {
+	reader := &sliceReader{data: iloc}
+
+	version := reader.uint(1)
+	reader.uint(3) // flags
+	sizes := reader.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = reader.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	count := reader.uint(2)
+	if version == 2 {
+		count = reader.uint(4)
+	}
+
+	for i := uint64(0); i < count && reader.ok(); i++ {
+		id := reader.uint(2)
+		if version == 2 {
+			id = reader.uint(4)
+		}
+
+		constructionMethod := uint64(0)
+		if version == 1 || version == 2 {
+			constructionMethod = reader.uint(2) & 0x0F
+		}
+		reader.uint(2) // data reference index
+		baseOffset := reader.uint(baseOffsetSize)
+
+		extents := reader.uint(2)
+		for e := uint64(0); e < extents && reader.ok(); e++ {
+			reader.uint(indexSize)
+			offset := reader.uint(offsetSize)
+			length := reader.uint(lengthSize)
+
+			// only items stored in the file itself are supported
+			if e == 0 && uint32(id) == itemID && constructionMethod == 0 && reader.ok() {
+				return baseOffset + offset, length, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+const (
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTime           = 0x0132
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+	tagGPSHPositioningErr = 0x001F
+)
+
+type tiffEntry struct {
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+func parseTIFF(data []byte, location *time.Location) (*Metadata, error) {
+	if len(data) < 8 {
+		return nil, ErrNotFound
+	}
+
+	reader := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		reader.order = binary.LittleEndian
+	case "MM":
+		reader.order = binary.BigEndian
+	default:
+		return nil, errors.New("exif: invalid TIFF header")
+	}
+
+	ifd0 := reader.ifd(reader.order.Uint32(data[4:]))
+	metadata := &Metadata{}
+
+	taken := reader.ascii(ifd0[tagDateTime])
+	offset := ""
+	if entry, ok := ifd0[tagExifIFD]; ok {
+		exif := reader.ifd(reader.long(entry))
+		if original := reader.ascii(exif[tagDateTimeOriginal]); len(original) > 0 {
+			taken = original
+		}
+		offset = reader.ascii(exif[tagOffsetTimeOriginal])
+	}
+
+	if len(taken) > 0 {
+		var parsed time.Time
+		var err error
+		if len(offset) > 0 {
+			parsed, err = time.Parse("2006:01:02 15:04:05-07:00", taken+offset)
+		} else {
+			parsed, err = time.ParseInLocation("2006:01:02 15:04:05", taken, location)
+		}
+		if err == nil {
+			metadata.Taken = &parsed
+		}
+	}
+
+	if entry, ok := ifd0[tagGPSIFD]; ok {
+		gps := reader.ifd(reader.long(entry))
+
+		latitude, latOk := reader.coordinate(gps[tagGPSLatitude], reader.ascii(gps[tagGPSLatitudeRef]), "S")
+		longitude, lonOk := reader.coordinate(gps[tagGPSLongitude], reader.ascii(gps[tagGPSLongitudeRef]), "W")
+		if latOk && lonOk {
+			metadata.Latitude = &latitude
+			metadata.Longitude = &longitude
+		}
+
+		if accuracy := reader.rationals(gps[tagGPSHPositioningErr]); len(accuracy) == 1 {
+			metadata.Accuracy = &accuracy[0]
+		}
+	}
+
+	return metadata, nil
+}
+
+var tiffTypeSizes = map[uint16]uint32{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// ifd reads the entries of the image file directory at the offset, invalid entries are dropped
+func (t *tiffReader) ifd(offset uint32) map[uint16]tiffEntry {
+	entries := make(map[uint16]tiffEntry)
+	if uint64(offset)+2 > uint64(len(t.data)) {
+		return entries
+	}
+
+	count := uint32(t.order.Uint16(t.data[offset:]))
+	for i := uint32(0); i < count; i++ {
+		start := uint64(offset) + 2 + uint64(i)*12
+		if start+12 > uint64(len(t.data)) {
+			break
+		}
+
+		raw := t.data[start : start+12]
+		entry := tiffEntry{
+			kind:  t.order.Uint16(raw[2:]),
+			count: t.order.Uint32(raw[4:]),
+		}
+
+		size, ok := tiffTypeSizes[entry.kind]
+		if !ok {
+			continue
+		}
+
+		total := uint64(size) * uint64(entry.count)
+		if total <= 4 {
+			entry.value = raw[8 : 8+total]
+		} else {
+			valueOffset := uint64(t.order.Uint32(raw[8:]))
+			if valueOffset+total > uint64(len(t.data)) {
+				continue
+			}
+			entry.value = t.data[valueOffset : valueOffset+total]
+		}
+
+		entries[t.order.Uint16(raw)] = entry
+	}
+
+	return entries
+}
+
+func (t *tiffReader) long(entry tiffEntry) uint32 {
+	switch {
+	case entry.kind == 4 && len(entry.value) >= 4:
+		return t.order.Uint32(entry.value)
+	case entry.kind == 3 && len(entry.value) >= 2:
+		return uint32(t.order.Uint16(entry.value))
+	}
+
+	return math.MaxUint32
+}
+
+func (t *tiffReader) ascii(entry tiffEntry) string {
+	if entry.kind != 2 {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(entry.value), "\x00"))
+}
+
+func (t *tiffReader) rationals(entry tiffEntry) []float64 {
+	if entry.kind != 5 {
+		return nil
+	}
+
+	result := make([]float64, 0, entry.count)
+	for i := 0; i+8 <= len(entry.value); i += 8 {
+		numerator := t.order.Uint32(entry.value[i:])
+		denominator := t.order.Uint32(entry.value[i+4:])
+		if denominator == 0 {
+			return nil
+		}
+		result = append(result, float64(numerator)/float64(denominator))
+	}
+
+	return result
+}
+
+// coordinate converts degrees, minutes and seconds to decimal degrees, negative for the given reference
+func (t *tiffReader) coordinate(entry tiffEntry, reference, negative string) (float64, bool) {
+	parts := t.rationals(entry)
+	if len(parts) != 3 {
+		return 0, false
+	}
+
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+	if strings.EqualFold(reference, negative) {
+		value = -value
+	}
+
+	return value, true
+}
+
+// errReader keeps the first error, reads after it return empty slices
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) read(n int) []byte {
+	buffer := make([]byte, n)
+	if e.err != nil {
+		return buffer
+	}
+
+	_, e.err = io.ReadFull(e.r, buffer)
+	return buffer
+}
+
+// sliceReader reads big endian unsigned integers of 0 to 8 bytes, reading past the end marks it as failed
+type sliceReader struct {
+	data   []byte
+	failed bool
+}
+
+func (s *sliceReader) uint(size int) uint64 {
+	if size == 0 {
+		return 0
+	}
+
+	if size > 8 || size > len(s.data) {
+		s.failed = true
+		s.data = nil
+		return 0
+	}
+
+	var value uint64
+	for _, b := range s.data[:size] {
+		value = value<<8 | uint64(b)
+	}
+	s.data = s.data[size:]
+
+	return value
+}
+
+func (s *sliceReader) ok() bool {
+	return !s.failed
+}
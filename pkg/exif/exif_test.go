@@ -0,0 +1,219 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	value []byte
+}
+
+// byteOrder both puts and appends, like binary.LittleEndian and binary.BigEndian
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiffBuilder writes a TIFF structure in the byte order, directories are appended with their values behind them
+type tiffBuilder struct {
+	order byteOrder
+	data  []byte
+}
+
+func newTIFFBuilder(order byteOrder) *tiffBuilder {
+	header := []byte("II*\x00\x00\x00\x00\x00")
+	if order == byteOrder(binary.BigEndian) {
+		header = []byte("MM\x00*\x00\x00\x00\x00")
+	}
+
+	return &tiffBuilder{order: order, data: header}
+}
+
+// ifd appends the directory and returns its offset
+func (b *tiffBuilder) ifd(entries []testEntry) uint32 {
+	offset := uint32(len(b.data))
+	values := uint32(len(b.data)) + 2 + uint32(len(entries))*12 + 4
+
+	directory := b.order.AppendUint16(nil, uint16(len(entries)))
+	external := make([]byte, 0)
+	for _, entry := range entries {
+		directory = b.order.AppendUint16(directory, entry.tag)
+		directory = b.order.AppendUint16(directory, entry.kind)
+		directory = b.order.AppendUint32(directory, entry.count)
+
+		if len(entry.value) <= 4 {
+			directory = append(directory, entry.value...)
+			directory = append(directory, make([]byte, 4-len(entry.value))...)
+			continue
+		}
+
+		directory = b.order.AppendUint32(directory, values+uint32(len(external)))
+		external = append(external, entry.value...)
+	}
+	directory = b.order.AppendUint32(directory, 0)
+
+	b.data = append(append(b.data, directory...), external...)
+	return offset
+}
+
+// root appends the first directory and points the header to it
+func (b *tiffBuilder) root(entries []testEntry) []byte {
+	offset := b.ifd(entries)
+	b.order.PutUint32(b.data[4:], offset)
+	return b.data
+}
+
+func (b *tiffBuilder) ascii(tag uint16, value string) testEntry {
+	return testEntry{tag: tag, kind: 2, count: uint32(len(value) + 1), value: append([]byte(value), 0)}
+}
+
+func (b *tiffBuilder) long(tag uint16, value uint32) testEntry {
+	return testEntry{tag: tag, kind: 4, count: 1, value: b.order.AppendUint32(nil, value)}
+}
+
+// rationals takes pairs of numerator and denominator
+func (b *tiffBuilder) rationals(tag uint16, values ...uint32) testEntry {
+	data := make([]byte, 0)
+	for _, value := range values {
+		data = b.order.AppendUint32(data, value)
+	}
+
+	return testEntry{tag: tag, kind: 5, count: uint32(len(values) / 2), value: data}
+}
+
+// photoTIFF has the time with offset in the Exif directory and a position in the GPS directory
+func photoTIFF(order byteOrder) []byte {
+	b := newTIFFBuilder(order)
+
+	exif := b.ifd([]testEntry{
+		b.ascii(tagDateTimeOriginal, "2024:06:01 14:30:00"),
+		b.ascii(tagOffsetTimeOriginal, "+02:00"),
+	})
+
+	gps := b.ifd([]testEntry{
+		b.ascii(tagGPSLatitudeRef, "N"),
+		b.rationals(tagGPSLatitude, 52, 1, 31, 1, 120288, 10000),
+		b.ascii(tagGPSLongitudeRef, "W"),
+		b.rationals(tagGPSLongitude, 13, 1, 2428, 100, 50, 1),
+		b.rationals(tagGPSHPositioningErr, 47, 10),
+	})
+
+	return b.root([]testEntry{
+		b.ascii(tagDateTime, "2024:06:02 08:00:00"),
+		b.long(tagExifIFD, exif),
+		b.long(tagGPSIFD, gps),
+	})
+}
+
+// jpeg wraps the TIFF structure into an APP1 segment behind an APP0 segment
+func jpeg(tiff []byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	data = append(data, 0xFF, 0xE0, 0x00, 0x07, 'J', 'F', 'I', 'F', 0x00)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data = append(data, 0xFF, 0xE1)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(segment)+2))
+	data = append(data, segment...)
+
+	return append(data, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func TestDecodeByteOrders(t *testing.T) {
+	for name, order := range map[string]byteOrder{"II": binary.LittleEndian, "MM": binary.BigEndian} {
+		t.Run(name, func(t *testing.T) {
+			metadata, err := Decode(bytes.NewReader(jpeg(photoTIFF(order))), time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the original time wins over the modification time, the offset over the location
+			expected := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+			if metadata.Taken == nil || !metadata.Taken.Equal(expected) {
+				t.Fatalf("taken %v, expected %v", metadata.Taken, expected)
+			}
+
+			if metadata.Latitude == nil || math.Abs(*metadata.Latitude-52.520008) > 1e-9 {
+				t.Fatalf("latitude %v", metadata.Latitude)
+			}
+
+			// 13° 24.28' 50", the seconds add to the fractional minutes
+			if metadata.Longitude == nil || math.Abs(*metadata.Longitude-(-(13+24.28/60+50.0/3600))) > 1e-9 {
+				t.Fatalf("longitude %v", metadata.Longitude)
+			}
+
+			if metadata.Accuracy == nil || *metadata.Accuracy != 4.7 {
+				t.Fatalf("accuracy %v", metadata.Accuracy)
+			}
+		})
+	}
+}
+
+func TestDecodeWithoutOffset(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := newTIFFBuilder(binary.BigEndian)
+	tiff := b.root([]testEntry{b.ascii(tagDateTime, "2024:01:15 09:00:00")})
+
+	metadata, err := Decode(bytes.NewReader(jpeg(tiff)), berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)
+	if metadata.Taken == nil || !metadata.Taken.Equal(expected) {
+		t.Fatalf("taken %v, expected %v", metadata.Taken, expected)
+	}
+
+	if metadata.Latitude != nil || metadata.Longitude != nil || metadata.Accuracy != nil {
+		t.Fatal("position without GPS directory")
+	}
+}
+
+func TestDecodeInvalidRationals(t *testing.T) {
+	b := newTIFFBuilder(binary.LittleEndian)
+	gps := b.ifd([]testEntry{
+		b.ascii(tagGPSLatitudeRef, "S"),
+		b.rationals(tagGPSLatitude, 10, 1, 0, 0, 0, 1),
+		b.ascii(tagGPSLongitudeRef, "E"),
+		b.rationals(tagGPSLongitude, 20, 1, 30, 1, 0, 1),
+	})
+	tiff := b.root([]testEntry{b.long(tagGPSIFD, gps)})
+
+	metadata, err := Decode(bytes.NewReader(jpeg(tiff)), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a zero denominator drops the whole position
+	if metadata.Latitude != nil || metadata.Longitude != nil {
+		t.Fatalf("position %v, %v", metadata.Latitude, metadata.Longitude)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")), time.UTC)
+	if err != ErrUnsupportedFormat {
+		t.Errorf("PNG: error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader([]byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00, 0xFF, 0xDA, 0x00, 0x02}), time.UTC)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("JPEG without Exif: error %v", err)
+	}
+
+	_, err = Decode(bytes.NewReader(jpeg([]byte("XX*\x00\x08\x00\x00\x00"))), time.UTC)
+	if err == nil {
+		t.Error("invalid TIFF header accepted")
+	}
+}
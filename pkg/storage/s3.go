@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type S3Options struct {
+	// e.g. "https://s3.eu-central-1.amazonaws.com" or "http://localhost:9000" for MinIO
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// address the bucket in the path instead of the host name, required by MinIO
+	PathStyle bool
+}
+
+// S3Storage keeps the blobs in a bucket of an S3 compatible service, requests are signed with AWS signature version 4
+type S3Storage struct {
+	options  S3Options
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3Storage(options S3Options) (*S3Storage, error) {
+	endpoint, err := url.Parse(options.Endpoint)
+	if err != nil || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("NewS3Storage: invalid endpoint %s", options.Endpoint)
+	}
+
+	if len(options.Bucket) == 0 {
+		return nil, fmt.Errorf("NewS3Storage: missing bucket")
+	}
+
+	if len(options.Region) == 0 {
+		options.Region = "us-east-1"
+	}
+
+	return &S3Storage{
+		options:  options,
+		endpoint: endpoint,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	response, err := s.do(ctx, http.MethodPut, key, body, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return s.responseError("Put", key, response)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	response, err := s.do(ctx, http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrNotFound
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return nil, s.responseError("Get", key, response)
+	}
+
+	return response.Body, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	response, err := s.do(ctx, http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+		return s.responseError("Delete", key, response)
+	}
+
+	return nil
+}
+
+func (s *S3Storage) responseError(operation, key string, response *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("S3Storage.%s: %s, status %d, %s", operation, key, response.StatusCode, strings.TrimSpace(string(message)))
+}
+
+// objectURL returns the URL of the object, the keys are generated and contain only unreserved characters and slashes
+func (s *S3Storage) objectURL(key string) *url.URL {
+	object := *s.endpoint
+	if s.options.PathStyle {
+		object.Path = strings.TrimSuffix(object.Path, "/") + "/" + s.options.Bucket + "/" + key
+	} else {
+		object.Host = s.options.Bucket + "." + object.Host
+		object.Path = strings.TrimSuffix(object.Path, "/") + "/" + key
+	}
+
+	return &object
+}
+
+func (s *S3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	// an empty body of unknown type would be sent chunked, which S3 rejects
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, s.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		request.ContentLength = size
+	}
+	if len(contentType) > 0 {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	s.sign(request, time.Now().UTC())
+
+	return s.client.Do(request)
+}
+
+// sign adds the authorization header of AWS signature version 4, the payload is not signed so that it can be streamed
+func (s *S3Storage) sign(request *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + request.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.options.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.options.SecretKey), date)
+	key = hmacSHA256(key, s.options.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.options.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
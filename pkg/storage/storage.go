@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("storage: object not found")
+
+// Storage keeps blobs addressed by slash separated keys, e.g. "events/42/3f1c..."
+type Storage interface {
+	// Put writes the blob, size is the exact number of bytes of body
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the blob, returns ErrNotFound when it does not exist
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob, missing blobs are not an error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,192 @@
+package track
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+type geoJSONFeature struct {
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]json.RawMessage `json:"properties"`
+}
+
+// readGeoJSON reads the features of a FeatureCollection one at a time.
+// Point features take their time from the "time" or "timestamp" property and their accuracy from "accuracy",
+// points without either property are skipped.
+// LineString and MultiLineString features take the times of their coordinates from "coordTimes".
+func readGeoJSON(r io.Reader, reader *pointReader) error {
+	decoder := json.NewDecoder(r)
+
+	token, err := decoder.Token()
+	if err != nil || token != json.Delim('{') {
+		return fmt.Errorf("%w, GeoJSON expected an object", ErrMalformed)
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w, GeoJSON %v", ErrMalformed, err)
+		}
+
+		switch token {
+		case "type":
+			var kind string
+			err = decoder.Decode(&kind)
+			if err != nil {
+				return fmt.Errorf("%w, GeoJSON %v", ErrMalformed, err)
+			}
+
+			if kind != "FeatureCollection" {
+				return fmt.Errorf("%w, GeoJSON expected a FeatureCollection", ErrMalformed)
+			}
+		case "features":
+			token, err = decoder.Token()
+			if err != nil || token != json.Delim('[') {
+				return fmt.Errorf("%w, GeoJSON features must be an array", ErrMalformed)
+			}
+
+			for decoder.More() {
+				var feature geoJSONFeature
+				err = decoder.Decode(&feature)
+				if err != nil {
+					return fmt.Errorf("%w, GeoJSON %v", ErrMalformed, err)
+				}
+
+				err = emitGeoJSONFeature(reader, &feature)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = decoder.Token()
+			if err != nil {
+				return fmt.Errorf("%w, GeoJSON %v", ErrMalformed, err)
+			}
+		default:
+			var skip json.RawMessage
+			err = decoder.Decode(&skip)
+			if err != nil {
+				return fmt.Errorf("%w, GeoJSON %v", ErrMalformed, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func emitGeoJSONFeature(reader *pointReader, feature *geoJSONFeature) error {
+	if feature.Geometry == nil {
+		return reader.invalid(errors.New("feature without geometry"))
+	}
+
+	switch feature.Geometry.Type {
+	case "Point":
+		var position []float64
+		err := json.Unmarshal(feature.Geometry.Coordinates, &position)
+		if err != nil || len(position) < 2 {
+			return reader.invalid(errors.New("invalid coordinates"))
+		}
+
+		timestamp := geoJSONTime(feature.Properties["time"])
+		if len(timestamp) == 0 {
+			timestamp = geoJSONTime(feature.Properties["timestamp"])
+		}
+
+		// points without any time are places rather than history, like the ones of an export
+		_, hasTime := feature.Properties["time"]
+		_, hasTimestamp := feature.Properties["timestamp"]
+		if !hasTime && !hasTimestamp {
+			return nil
+		}
+
+		accuracy := 0.0
+		if value, ok := feature.Properties["accuracy"]; ok {
+			err = json.Unmarshal(value, &accuracy)
+			if err != nil {
+				accuracy = math.NaN()
+			}
+		}
+
+		return reader.emit(timestamp, position[1], position[0], accuracy)
+	case "LineString":
+		var positions [][]float64
+		var times []json.RawMessage
+		err := json.Unmarshal(feature.Geometry.Coordinates, &positions)
+		if err != nil {
+			return reader.invalid(errors.New("invalid coordinates"))
+		}
+		json.Unmarshal(feature.Properties["coordTimes"], &times)
+
+		return emitGeoJSONLine(reader, positions, times)
+	case "MultiLineString":
+		var lines [][][]float64
+		var times [][]json.RawMessage
+		err := json.Unmarshal(feature.Geometry.Coordinates, &lines)
+		if err != nil {
+			return reader.invalid(errors.New("invalid coordinates"))
+		}
+		json.Unmarshal(feature.Properties["coordTimes"], &times)
+
+		for i, line := range lines {
+			var lineTimes []json.RawMessage
+			if i < len(times) {
+				lineTimes = times[i]
+			}
+
+			err = emitGeoJSONLine(reader, line, lineTimes)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return reader.invalid(errors.New("unsupported geometry " + feature.Geometry.Type))
+}
+
+func emitGeoJSONLine(reader *pointReader, positions [][]float64, times []json.RawMessage) error {
+	for i, position := range positions {
+		if len(position) < 2 {
+			err := reader.invalid(errors.New("invalid coordinates"))
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		timestamp := ""
+		if i < len(times) {
+			timestamp = geoJSONTime(times[i])
+		}
+
+		err := reader.emit(timestamp, position[1], position[0], 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// geoJSONTime returns the RFC 3339 time of a string or of a number of milliseconds since the epoch
+func geoJSONTime(value json.RawMessage) string {
+	var text string
+	if json.Unmarshal(value, &text) == nil {
+		return text
+	}
+
+	var milliseconds float64
+	if json.Unmarshal(value, &milliseconds) == nil {
+		return time.UnixMilli(int64(milliseconds)).UTC().Format(time.RFC3339Nano)
+	}
+
+	return ""
+}
@@ -0,0 +1,138 @@
+package track
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatGPX     Format = "gpx"
+	FormatKML     Format = "kml"
+	FormatGeoJSON Format = "geojson"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("track: unsupported format, expected gpx, kml or geojson")
+	// the file cannot be parsed further, invalid points are reported as PointError instead
+	ErrMalformed = errors.New("track: malformed file")
+)
+
+// FormatFromContentType returns the format of the media type, e.g. "application/gpx+xml"
+func FormatFromContentType(contentType string) (Format, bool) {
+	contentType, _, _ = strings.Cut(contentType, ";")
+
+	switch strings.TrimSpace(strings.ToLower(contentType)) {
+	case "application/gpx+xml":
+		return FormatGPX, true
+	case "application/vnd.google-earth.kml+xml":
+		return FormatKML, true
+	case "application/geo+json", "application/json":
+		return FormatGeoJSON, true
+	}
+
+	return "", false
+}
+
+type Point struct {
+	Time      time.Time
+	Latitude  float64
+	Longitude float64
+	// meters, 0 when the file has none
+	Accuracy float64
+}
+
+// PointError is an invalid point of the file, the following points are still read
+type PointError struct {
+	// position of the point in the file, starting at 1
+	Index int
+	Err   error
+}
+
+func (e *PointError) Error() string {
+	return fmt.Sprintf("point %d: %v", e.Index, e.Err)
+}
+
+// Handler receives every point in file order, err is a *PointError for invalid points.
+// Returning an error stops the reading, Read then returns it.
+type Handler func(point *Point, err error) error
+
+// Read streams the points of the file, the whole file is never held in memory.
+// It fails with ErrMalformed on malformed files, points read before the failure were already handed to fn.
+func Read(format Format, r io.Reader, fn Handler) error {
+	reader := &pointReader{fn: fn}
+
+	switch format {
+	case FormatGPX:
+		return readGPX(r, reader)
+	case FormatKML:
+		return readKML(r, reader)
+	case FormatGeoJSON:
+		return readGeoJSON(r, reader)
+	}
+
+	return ErrUnsupportedFormat
+}
+
+// pointReader validates and numbers the points before handing them to the handler
+type pointReader struct {
+	fn    Handler
+	index int
+}
+
+func (p *pointReader) emit(timestamp string, latitude, longitude float64, accuracy float64) error {
+	p.index++
+
+	point, err := newPoint(timestamp, latitude, longitude, accuracy)
+	if err != nil {
+		return p.fn(nil, &PointError{Index: p.index, Err: err})
+	}
+
+	return p.fn(point, nil)
+}
+
+func (p *pointReader) invalid(err error) error {
+	p.index++
+	return p.fn(nil, &PointError{Index: p.index, Err: err})
+}
+
+func newPoint(timestamp string, latitude, longitude, accuracy float64) (*Point, error) {
+	if len(timestamp) == 0 {
+		return nil, errors.New("missing time")
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(timestamp))
+	if err != nil {
+		return nil, errors.New("invalid time " + timestamp)
+	}
+
+	if math.IsNaN(latitude) || latitude < -90 || latitude > 90 {
+		return nil, errors.New("invalid latitude")
+	}
+
+	if math.IsNaN(longitude) || longitude < -180 || longitude > 180 {
+		return nil, errors.New("invalid longitude")
+	}
+
+	if math.IsNaN(accuracy) || accuracy < 0 {
+		return nil, errors.New("invalid accuracy")
+	}
+
+	return &Point{Time: parsed, Latitude: latitude, Longitude: longitude, Accuracy: accuracy}, nil
+}
+
+// parseCoordinate parses a number of a coordinate string, invalid numbers become NaN and fail the validation
+func parseCoordinate(value string) float64 {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return math.NaN()
+	}
+
+	return number
+}
@@ -0,0 +1,182 @@
+package track
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll returns the points of the file and the indexes of the invalid ones
+func readAll(t *testing.T, format Format, data string) ([]Point, []int, error) {
+	t.Helper()
+
+	points := make([]Point, 0)
+	invalid := make([]int, 0)
+	err := Read(format, strings.NewReader(data), func(point *Point, err error) error {
+		if err != nil {
+			var pointError *PointError
+			if !errors.As(err, &pointError) {
+				t.Fatalf("unexpected error %v", err)
+			}
+			invalid = append(invalid, pointError.Index)
+			return nil
+		}
+
+		points = append(points, *point)
+		return nil
+	})
+
+	return points, invalid, err
+}
+
+func comparePoints(t *testing.T, points, expected []Point) {
+	t.Helper()
+
+	if len(points) != len(expected) {
+		t.Fatalf("points %v, expected %v", points, expected)
+	}
+
+	for i := range points {
+		if !points[i].Time.Equal(expected[i].Time) || points[i].Latitude != expected[i].Latitude ||
+			points[i].Longitude != expected[i].Longitude || points[i].Accuracy != expected[i].Accuracy {
+			t.Fatalf("point %d is %v, expected %v", i, points[i], expected[i])
+		}
+	}
+}
+
+func TestReadGPX(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<gpx version="1.0" xmlns="http://www.topografix.com/GPX/1/0">
+	<wpt lat="1" lon="2"><name>Home</name></wpt>
+	<wpt lat="1.5" lon="2.5"><time>2024-01-01T09:00:00Z</time></wpt>
+	<rte><rtept lat="3" lon="4"><time>2024-01-01T10:00:00Z</time></rtept></rte>
+	<trk><trkseg>
+		<trkpt lat="52.5" lon="13.4"><time>2024-01-01T11:00:00+01:00</time></trkpt>
+		<trkpt lat="91" lon="13.4"><time>2024-01-01T11:01:00Z</time></trkpt>
+		<trkpt lat="52.6" lon="13.5"></trkpt>
+		<trkpt lat="52.7" lon="13.6"><time>2024-01-01T11:02:00.5Z</time></trkpt>
+	</trkseg></trk>
+</gpx>`
+
+	points, invalid, err := readAll(t, FormatGPX, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comparePoints(t, points, []Point{
+		{Time: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Latitude: 1.5, Longitude: 2.5},
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Latitude: 3, Longitude: 4},
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Latitude: 52.5, Longitude: 13.4},
+		{Time: time.Date(2024, 1, 1, 11, 2, 0, 500000000, time.UTC), Latitude: 52.7, Longitude: 13.6},
+	})
+
+	// the way point without time is no point, the invalid latitude and the missing time are
+	if len(invalid) != 2 || invalid[0] != 4 || invalid[1] != 5 {
+		t.Fatalf("invalid points %v", invalid)
+	}
+}
+
+func TestReadKML(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+<Document>
+	<Placemark><name>Home</name><Point><coordinates>2,1</coordinates></Point></Placemark>
+	<Placemark>
+		<TimeStamp><when>2024-01-01T09:00:00Z</when></TimeStamp>
+		<Point><coordinates> 13.4,52.5,34 </coordinates></Point>
+	</Placemark>
+	<Placemark><gx:Track>
+		<when>2024-01-01T10:00:00Z</when>
+		<when>2024-01-01T10:01:00Z</when>
+		<gx:coord>13.5 52.6 0</gx:coord>
+		<gx:coord>13.6 52.7 0</gx:coord>
+	</gx:Track></Placemark>
+	<Placemark><gx:Track>
+		<when>2024-01-01T11:00:00Z</when>
+		<when>2024-01-01T11:01:00Z</when>
+		<gx:coord>13.7 52.8 0</gx:coord>
+	</gx:Track></Placemark>
+</Document>
+</kml>`
+
+	points, invalid, err := readAll(t, FormatKML, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comparePoints(t, points, []Point{
+		{Time: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Latitude: 52.5, Longitude: 13.4},
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Latitude: 52.6, Longitude: 13.5},
+		{Time: time.Date(2024, 1, 1, 10, 1, 0, 0, time.UTC), Latitude: 52.7, Longitude: 13.6},
+		{Time: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), Latitude: 52.8, Longitude: 13.7},
+	})
+
+	// the track with a time more than coordinates
+	if len(invalid) != 1 || invalid[0] != 5 {
+		t.Fatalf("invalid points %v", invalid)
+	}
+}
+
+func TestReadGeoJSON(t *testing.T) {
+	data := `{
+		"type": "FeatureCollection",
+		"name": "history",
+		"features": [
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [2, 1]}, "properties": {"name": "Home"}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [13.4, 52.5]}, "properties": {"time": "2024-01-01T09:00:00Z", "accuracy": 12.5}},
+			{"type": "Feature", "geometry": {"type": "Point", "coordinates": [13.5, 52.6]}, "properties": {"timestamp": 1704103200000}},
+			{"type": "Feature", "geometry": {"type": "MultiLineString", "coordinates": [[[13.6, 52.7], [13.7, 52.8]], [[13.8, 52.9]]]},
+				"properties": {"coordTimes": [["2024-01-01T11:00:00Z", "2024-01-01T11:01:00Z"], ["2024-01-01T12:00:00Z"]]}},
+			{"type": "Feature", "geometry": {"type": "Polygon", "coordinates": []}, "properties": {}}
+		]
+	}`
+
+	points, invalid, err := readAll(t, FormatGeoJSON, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comparePoints(t, points, []Point{
+		{Time: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC), Latitude: 52.5, Longitude: 13.4, Accuracy: 12.5},
+		{Time: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), Latitude: 52.6, Longitude: 13.5},
+		{Time: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC), Latitude: 52.7, Longitude: 13.6},
+		{Time: time.Date(2024, 1, 1, 11, 1, 0, 0, time.UTC), Latitude: 52.8, Longitude: 13.7},
+		{Time: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), Latitude: 52.9, Longitude: 13.8},
+	})
+
+	// the unsupported polygon
+	if len(invalid) != 1 || invalid[0] != 6 {
+		t.Fatalf("invalid points %v", invalid)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	tests := map[Format]string{
+		FormatGPX:     `<gpx><trk><trkseg><trkpt lat="1" lon="2"><time>2024-01-01T09:00:00Z</time></trkpt><trkpt`,
+		FormatKML:     `<kml><Document><Placemark><TimeStamp><when>2024-01-01T09:00:00Z</when></TimeStamp><Point><coordinates>2,1</coordinates></Point></Placemark><Placemark`,
+		FormatGeoJSON: `{"type": "FeatureCollection", "features": [{"type": "Feature", "geometry": {"type": "Point", "coordinates": [2, 1]}, "properties": {"time": "2024-01-01T09:00:00Z"}}, {`,
+	}
+
+	for format, data := range tests {
+		points, _, err := readAll(t, format, data)
+		if !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: error %v, expected ErrMalformed", format, err)
+		}
+
+		// the points before the failure were handed over
+		if len(points) != 1 {
+			t.Errorf("%s: points %v", format, points)
+		}
+	}
+
+	_, _, err := readAll(t, FormatGeoJSON, `{"type": "Feature"}`)
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("feature: error %v, expected ErrMalformed", err)
+	}
+
+	err = Read("csv", strings.NewReader(""), nil)
+	if err != ErrUnsupportedFormat {
+		t.Errorf("csv: error %v, expected ErrUnsupportedFormat", err)
+	}
+}
@@ -0,0 +1,442 @@
+package track
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Waypoint is a named position without time, e.g. a place
+type Waypoint struct {
+	Name        string
+	Description string
+	Latitude    float64
+	Longitude   float64
+}
+
+// Writer encodes a file of waypoints followed by track segments, the points of a segment are written one at a time.
+// All waypoints have to be written before the first segment. Close ends the file and releases the temporary file
+// of the writer, it has to be called also when writing failed.
+type Writer interface {
+	WriteWaypoint(waypoint *Waypoint) error
+	BeginSegment() error
+	WritePoint(point *Point) error
+	EndSegment() error
+	Close() error
+}
+
+// NewWriter starts a file of the format with the name as its title, creator names the application writing GPX files
+func NewWriter(format Format, w io.Writer, creator, name string) (Writer, error) {
+	switch format {
+	case FormatGPX:
+		return newGPXWriter(w, creator, name)
+	case FormatKML:
+		return newKMLWriter(w, name)
+	case FormatGeoJSON:
+		return newGeoJSONWriter(w)
+	}
+
+	return nil, ErrUnsupportedFormat
+}
+
+// ContentType returns the media type of the format
+func ContentType(format Format) string {
+	switch format {
+	case FormatGPX:
+		return "application/gpx+xml"
+	case FormatKML:
+		return "application/vnd.google-earth.kml+xml"
+	case FormatGeoJSON:
+		return "application/geo+json"
+	}
+
+	return "application/octet-stream"
+}
+
+// Segmenter groups points in time order into segments, a new segment starts after a gap longer than gap
+type Segmenter struct {
+	writer Writer
+	gap    time.Duration
+	open   bool
+	last   time.Time
+}
+
+func NewSegmenter(writer Writer, gap time.Duration) *Segmenter {
+	return &Segmenter{writer: writer, gap: gap}
+}
+
+func (s *Segmenter) Add(point Point) error {
+	if s.open && point.Time.Sub(s.last) > s.gap {
+		err := s.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	if !s.open {
+		err := s.writer.BeginSegment()
+		if err != nil {
+			return err
+		}
+		s.open = true
+	}
+
+	s.last = point.Time
+	return s.writer.WritePoint(&point)
+}
+
+// Flush ends the current segment
+func (s *Segmenter) Flush() error {
+	if !s.open {
+		return nil
+	}
+
+	s.open = false
+	return s.writer.EndSegment()
+}
+
+// spool keeps the second list of a segment for formats listing every time apart from every position.
+// It is a temporary file, so segments of any length take constant memory.
+type spool struct {
+	file   *os.File
+	buffer *bufio.Writer
+}
+
+func (s *spool) WriteString(value string) (int, error) {
+	if s.file == nil {
+		file, err := os.CreateTemp("", "track-*")
+		if err != nil {
+			return 0, err
+		}
+
+		s.file = file
+		s.buffer = bufio.NewWriter(file)
+	}
+
+	return s.buffer.WriteString(value)
+}
+
+// copyTo writes the spooled content to w and empties the spool
+func (s *spool) copyTo(w io.Writer) error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, s.file)
+	if err != nil {
+		return err
+	}
+
+	err = s.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.file.Seek(0, io.SeekStart)
+	return err
+}
+
+func (s *spool) close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	s.file.Close()
+	err := os.Remove(s.file.Name())
+	s.file = nil
+	return err
+}
+
+func escapeXML(value string) string {
+	var buffer bytes.Buffer
+	xml.EscapeText(&buffer, []byte(value))
+	return buffer.String()
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatTime(value time.Time) string {
+	return value.UTC().Format(time.RFC3339Nano)
+}
+
+type gpxWriter struct {
+	w         io.Writer
+	trackOpen bool
+}
+
+func newGPXWriter(w io.Writer, creator, name string) (*gpxWriter, error) {
+	_, err := fmt.Fprintf(w, "%s<gpx version=\"1.1\" creator=\"%s\" xmlns=\"http://www.topografix.com/GPX/1/1\">\n<metadata><name>%s</name></metadata>\n",
+		xml.Header, escapeXML(creator), escapeXML(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &gpxWriter{w: w}, nil
+}
+
+func (g *gpxWriter) WriteWaypoint(waypoint *Waypoint) error {
+	_, err := fmt.Fprintf(g.w, "<wpt lat=\"%s\" lon=\"%s\"><name>%s</name><desc>%s</desc></wpt>\n",
+		formatFloat(waypoint.Latitude), formatFloat(waypoint.Longitude), escapeXML(waypoint.Name), escapeXML(waypoint.Description))
+	return err
+}
+
+func (g *gpxWriter) BeginSegment() error {
+	if !g.trackOpen {
+		_, err := io.WriteString(g.w, "<trk>\n")
+		if err != nil {
+			return err
+		}
+		g.trackOpen = true
+	}
+
+	_, err := io.WriteString(g.w, "<trkseg>\n")
+	return err
+}
+
+func (g *gpxWriter) WritePoint(point *Point) error {
+	_, err := fmt.Fprintf(g.w, "<trkpt lat=\"%s\" lon=\"%s\"><time>%s</time></trkpt>\n",
+		formatFloat(point.Latitude), formatFloat(point.Longitude), formatTime(point.Time))
+	return err
+}
+
+func (g *gpxWriter) EndSegment() error {
+	_, err := io.WriteString(g.w, "</trkseg>\n")
+	return err
+}
+
+func (g *gpxWriter) Close() error {
+	if g.trackOpen {
+		_, err := io.WriteString(g.w, "</trk>\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(g.w, "</gpx>\n")
+	return err
+}
+
+type kmlWriter struct {
+	w        io.Writer
+	segments int
+	// coordinates of the open segment, written after its times
+	coords spool
+}
+
+func newKMLWriter(w io.Writer, name string) (*kmlWriter, error) {
+	_, err := fmt.Fprintf(w, "%s<kml xmlns=\"http://www.opengis.net/kml/2.2\" xmlns:gx=\"http://www.google.com/kml/ext/2.2\">\n<Document><name>%s</name>\n",
+		xml.Header, escapeXML(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &kmlWriter{w: w}, nil
+}
+
+func (k *kmlWriter) WriteWaypoint(waypoint *Waypoint) error {
+	_, err := fmt.Fprintf(k.w, "<Placemark><name>%s</name><description>%s</description><Point><coordinates>%s,%s</coordinates></Point></Placemark>\n",
+		escapeXML(waypoint.Name), escapeXML(waypoint.Description), formatFloat(waypoint.Longitude), formatFloat(waypoint.Latitude))
+	return err
+}
+
+// BeginSegment starts a gx:Track, which lists every time before the coordinates
+func (k *kmlWriter) BeginSegment() error {
+	k.segments++
+	_, err := fmt.Fprintf(k.w, "<Placemark><name>Segment %d</name><gx:Track>\n", k.segments)
+	return err
+}
+
+func (k *kmlWriter) WritePoint(point *Point) error {
+	_, err := fmt.Fprintf(k.w, "<when>%s</when>\n", formatTime(point.Time))
+	if err != nil {
+		return err
+	}
+
+	_, err = k.coords.WriteString("<gx:coord>" + formatFloat(point.Longitude) + " " + formatFloat(point.Latitude) + " 0</gx:coord>\n")
+	return err
+}
+
+func (k *kmlWriter) EndSegment() error {
+	err := k.coords.copyTo(k.w)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(k.w, "</gx:Track></Placemark>\n")
+	return err
+}
+
+func (k *kmlWriter) Close() error {
+	err := k.coords.close()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(k.w, "</Document>\n</kml>\n")
+	return err
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type geoJSONOutput struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONWriter struct {
+	w     io.Writer
+	first bool
+	// points of the open segment, the first is held back until it is known whether the segment is a single Point
+	points int
+	start  Point
+	// times of the open segment, written after its coordinates
+	times spool
+}
+
+func newGeoJSONWriter(w io.Writer) (*geoJSONWriter, error) {
+	_, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`)
+	if err != nil {
+		return nil, err
+	}
+
+	return &geoJSONWriter{w: w, first: true}, nil
+}
+
+// separate starts the next feature of the collection
+func (g *geoJSONWriter) separate() error {
+	separator := ",\n"
+	if g.first {
+		separator = "\n"
+	}
+	g.first = false
+
+	_, err := io.WriteString(g.w, separator)
+	return err
+}
+
+func (g *geoJSONWriter) writeFeature(geometryType string, coordinates any, properties map[string]any) error {
+	data, err := json.Marshal(geoJSONOutput{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: geometryType, Coordinates: coordinates},
+		Properties: properties,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = g.separate()
+	if err != nil {
+		return err
+	}
+
+	_, err = g.w.Write(data)
+	return err
+}
+
+func (g *geoJSONWriter) WriteWaypoint(waypoint *Waypoint) error {
+	return g.writeFeature("Point", []float64{waypoint.Longitude, waypoint.Latitude}, map[string]any{
+		"name":        waypoint.Name,
+		"description": waypoint.Description,
+	})
+}
+
+func (g *geoJSONWriter) BeginSegment() error {
+	g.points = 0
+	return nil
+}
+
+// WritePoint adds the point to a LineString with the times in the "coordTimes" property
+func (g *geoJSONWriter) WritePoint(point *Point) error {
+	g.points++
+	if g.points == 1 {
+		g.start = *point
+		return nil
+	}
+
+	if g.points == 2 {
+		err := g.separate()
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(g.w, `{"type":"Feature","geometry":{"type":"LineString","coordinates":[`+geoJSONPosition(&g.start))
+		if err != nil {
+			return err
+		}
+
+		_, err = g.times.WriteString(`"` + formatTime(g.start.Time) + `"`)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(g.w, ","+geoJSONPosition(point))
+	if err != nil {
+		return err
+	}
+
+	_, err = g.times.WriteString(`,"` + formatTime(point.Time) + `"`)
+	return err
+}
+
+// EndSegment writes a segment of a single point as a Point, since a LineString needs two positions
+func (g *geoJSONWriter) EndSegment() error {
+	switch g.points {
+	case 0:
+		return nil
+	case 1:
+		return g.writeFeature("Point", []float64{g.start.Longitude, g.start.Latitude}, map[string]any{
+			"time":     formatTime(g.start.Time),
+			"accuracy": g.start.Accuracy,
+		})
+	}
+
+	_, err := io.WriteString(g.w, `]},"properties":{"coordTimes":[`)
+	if err != nil {
+		return err
+	}
+
+	err = g.times.copyTo(g.w)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(g.w, "]}}")
+	return err
+}
+
+func geoJSONPosition(point *Point) string {
+	return "[" + formatFloat(point.Longitude) + "," + formatFloat(point.Latitude) + "]"
+}
+
+func (g *geoJSONWriter) Close() error {
+	err := g.times.close()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(g.w, "\n]}\n")
+	return err
+}
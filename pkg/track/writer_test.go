@@ -0,0 +1,244 @@
+package track
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeSegment(writer Writer, points []Point) error {
+	err := writer.BeginSegment()
+	if err != nil {
+		return err
+	}
+
+	for i := range points {
+		err = writer.WritePoint(&points[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.EndSegment()
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	segments := [][]Point{
+		{
+			{Time: start, Latitude: 52.520008, Longitude: 13.404954},
+			{Time: start.Add(time.Minute), Latitude: 52.521, Longitude: 13.405},
+			{Time: start.Add(2*time.Minute + 500*time.Millisecond), Latitude: -33.8688, Longitude: 151.2093},
+		},
+		{
+			{Time: start.Add(time.Hour), Latitude: 48.8566, Longitude: 2.3522},
+		},
+		{
+			{Time: start.Add(2 * time.Hour), Latitude: 40.7128, Longitude: -74.006},
+			{Time: start.Add(2*time.Hour + time.Minute), Latitude: 40.713, Longitude: -74.0061},
+		},
+	}
+
+	for _, format := range []Format{FormatGPX, FormatKML, FormatGeoJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var output bytes.Buffer
+			writer, err := NewWriter(format, &output, "backend", `History <"&">`)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = writer.WriteWaypoint(&Waypoint{Name: "Home & <work>", Description: `"quoted"`, Latitude: 1, Longitude: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, segment := range segments {
+				err = writeSegment(writer, segment)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = writer.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the file is well-formed
+			if format == FormatGeoJSON {
+				if !json.Valid(output.Bytes()) {
+					t.Fatalf("invalid JSON %s", output.String())
+				}
+			} else {
+				decoder := xml.NewDecoder(bytes.NewReader(output.Bytes()))
+				for {
+					_, err = decoder.Token()
+					if err != nil {
+						break
+					}
+				}
+				if err != io.EOF {
+					t.Fatalf("invalid XML, %v", err)
+				}
+			}
+
+			// the way point has no time and is not read back
+			points, invalid, err := readAll(t, format, output.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(invalid) > 0 {
+				t.Fatalf("invalid points %v", invalid)
+			}
+
+			expected := make([]Point, 0)
+			for _, segment := range segments {
+				expected = append(expected, segment...)
+			}
+			comparePoints(t, points, expected)
+		})
+	}
+}
+
+func TestGPXWriterCreator(t *testing.T) {
+	var output bytes.Buffer
+	writer, err := NewWriter(FormatGPX, &output, "backend", "Location history")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gpx struct {
+		Creator string `xml:"creator,attr"`
+		Name    string `xml:"metadata>name"`
+	}
+	err = xml.Unmarshal(output.Bytes(), &gpx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gpx.Creator != "backend" || gpx.Name != "Location history" {
+		t.Fatalf("creator %q and name %q", gpx.Creator, gpx.Name)
+	}
+}
+
+// segmentRecorder keeps the written segments
+type segmentRecorder struct {
+	segments [][]Point
+	open     bool
+}
+
+func (s *segmentRecorder) WriteWaypoint(waypoint *Waypoint) error {
+	return nil
+}
+
+func (s *segmentRecorder) BeginSegment() error {
+	if s.open {
+		return errors.New("segment already open")
+	}
+
+	s.open = true
+	s.segments = append(s.segments, []Point{})
+	return nil
+}
+
+func (s *segmentRecorder) WritePoint(point *Point) error {
+	if !s.open {
+		return errors.New("point outside of a segment")
+	}
+
+	s.segments[len(s.segments)-1] = append(s.segments[len(s.segments)-1], *point)
+	return nil
+}
+
+func (s *segmentRecorder) EndSegment() error {
+	if !s.open {
+		return errors.New("no open segment")
+	}
+
+	s.open = false
+	return nil
+}
+
+func (s *segmentRecorder) Close() error {
+	return nil
+}
+
+func TestSegmenter(t *testing.T) {
+	recorder := &segmentRecorder{}
+	segmenter := NewSegmenter(recorder, 10*time.Minute)
+
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, offset := range []time.Duration{0, 5 * time.Minute, 15 * time.Minute, 26 * time.Minute, 36 * time.Minute} {
+		err := segmenter.Add(Point{Time: start.Add(offset)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := segmenter.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a gap of exactly the limit stays in the segment
+	sizes := make([]int, len(recorder.segments))
+	for i, segment := range recorder.segments {
+		sizes[i] = len(segment)
+	}
+	if len(sizes) != 2 || sizes[0] != 3 || sizes[1] != 2 {
+		t.Fatalf("segment sizes %v, expected [3 2]", sizes)
+	}
+
+	if !recorder.segments[1][0].Time.Equal(start.Add(26 * time.Minute)) {
+		t.Fatalf("second segment starts at %v", recorder.segments[1][0].Time)
+	}
+
+	// flushing again writes nothing
+	err = segmenter.Flush()
+	if err != nil || len(recorder.segments) != 2 {
+		t.Fatalf("second flush wrote %d segments, %v", len(recorder.segments), err)
+	}
+}
+
+func TestSegmenterLongSegment(t *testing.T) {
+	recorder := &segmentRecorder{}
+	segmenter := NewSegmenter(recorder, time.Minute)
+
+	// without a gap the segment is never split, whatever its length
+	start := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 50000; i++ {
+		err := segmenter.Add(Point{Time: start.Add(time.Duration(i) * time.Second)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := segmenter.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recorder.segments) != 1 || len(recorder.segments[0]) != 50000 {
+		t.Fatalf("%d segments", len(recorder.segments))
+	}
+}
+
+func TestContentTypeRoundTrip(t *testing.T) {
+	for _, format := range []Format{FormatGPX, FormatKML, FormatGeoJSON} {
+		detected, ok := FormatFromContentType(strings.ToUpper(ContentType(format)) + "; charset=utf-8")
+		if !ok || detected != format {
+			t.Errorf("%s: detected %s", format, detected)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package track
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type gpxPoint struct {
+	Latitude  string `xml:"lat,attr"`
+	Longitude string `xml:"lon,attr"`
+	Time      string `xml:"time"`
+}
+
+// readGPX reads the track, route and way points of a GPX 1.0 or 1.1 file
+func readGPX(r io.Reader, reader *pointReader) error {
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w, GPX %v", ErrMalformed, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "trkpt", "rtept", "wpt":
+			var point gpxPoint
+			err = decoder.DecodeElement(&point, &start)
+			if err != nil {
+				return fmt.Errorf("%w, GPX %v", ErrMalformed, err)
+			}
+
+			// way points without time are places rather than history, like the ones of an export
+			if start.Name.Local == "wpt" && len(point.Time) == 0 {
+				continue
+			}
+
+			err = reader.emit(point.Time, parseCoordinate(point.Latitude), parseCoordinate(point.Longitude), 0)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readKML reads the placemarks with a point and a time stamp, and the points of gx:Track elements
+func readKML(r io.Reader, reader *pointReader) error {
+	decoder := xml.NewDecoder(r)
+
+	// names of the open elements, the innermost last
+	path := make([]string, 0)
+	parent := func() string {
+		if len(path) == 0 {
+			return ""
+		}
+		return path[len(path)-1]
+	}
+
+	// a gx:Track lists every time and then every coordinate, they are paired in order
+	whens := make([]string, 0)
+	coords := make([]string, 0)
+
+	var placemarkWhen, placemarkCoordinates string
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w, KML %v", ErrMalformed, err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			name := element.Name.Local
+			if (name == "when" && (parent() == "TimeStamp" || parent() == "Track")) ||
+				(name == "coord" && parent() == "Track") ||
+				(name == "coordinates" && parent() == "Point") {
+				var value string
+				err = decoder.DecodeElement(&value, &element)
+				if err != nil {
+					return fmt.Errorf("%w, KML %v", ErrMalformed, err)
+				}
+
+				switch {
+				case name == "when" && parent() == "Track":
+					whens = append(whens, value)
+				case name == "when":
+					placemarkWhen = value
+				case name == "coord":
+					coords = append(coords, value)
+				default:
+					placemarkCoordinates = value
+				}
+
+				for len(whens) > 0 && len(coords) > 0 {
+					err = emitKMLCoordinate(reader, whens[0], strings.Fields(coords[0]))
+					if err != nil {
+						return err
+					}
+					whens, coords = whens[1:], coords[1:]
+				}
+				continue
+			}
+
+			if name == "Placemark" {
+				placemarkWhen, placemarkCoordinates = "", ""
+			}
+			path = append(path, name)
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+
+			switch element.Name.Local {
+			case "Track":
+				if len(whens) != len(coords) {
+					err = reader.invalid(errors.New("gx:Track with a different number of times and coordinates"))
+					if err != nil {
+						return err
+					}
+				}
+				whens, coords = whens[:0], coords[:0]
+			case "Placemark":
+				// placemarks without time are places rather than history
+				if len(placemarkWhen) > 0 && len(placemarkCoordinates) > 0 {
+					err = emitKMLCoordinate(reader, placemarkWhen, strings.Split(strings.TrimSpace(placemarkCoordinates), ","))
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
+
+// emitKMLCoordinate hands over a coordinate of longitude, latitude and optional altitude
+func emitKMLCoordinate(reader *pointReader, when string, parts []string) error {
+	if len(parts) < 2 {
+		return reader.invalid(errors.New("invalid coordinates"))
+	}
+
+	return reader.emit(when, parseCoordinate(parts[1]), parseCoordinate(parts[0]), 0)
+}